@@ -24,3 +24,13 @@ type ValueError struct {
 func (e *ValueError) Error() string {
     return fmt.Sprintf("Value Error\nField: %v\nValue: %v\n%v", e.Field, e.Value, e.Message)
 }
+
+type ConvergenceError struct {
+    Field string
+    Iterations int
+    Message string
+}
+
+func (e *ConvergenceError) Error() string {
+    return fmt.Sprintf("Convergence Error\nField: %v\nIterations: %v\n%v", e.Field, e.Iterations, e.Message)
+}
@@ -6,6 +6,9 @@
 // [X] PrincipalPayment (monthly)
 // [X] InterestPayment (monthly)
 // [X] PresentValue
+// [X] NetPresentValue
+// [X] InternalRateOfReturn
+// [X] BlackCaplet
 // If everything is already in years, this is not needed
 // [X] YearlyIOPayment
 // [X] YearlyPayment
@@ -24,6 +27,17 @@ const (
     PayBegin = 1
 )
 
+// Limits of the search done by InternalRateOfReturn. The rates are scanned
+// from irrLowerBound up to irrUpperBound looking for sign changes of the NPV,
+// and each bracket found is then narrowed until irrTolerance is reached.
+const (
+    irrLowerBound = -0.9999
+    irrUpperBound = 100.0
+    irrScanSteps = 2000
+    irrTolerance = 1e-10
+    irrMaxIterations = 200
+)
+
 
 // IOPayment returns the interest only payment for a cash flow with a constant
 // interest rate.
@@ -186,3 +200,159 @@ func PresentValue(
     }
     return utils.Round2(pv), nil
 }
+
+// NetPresentValue returns the net present value of a cash flow, where the
+// first value of the slice happens at period 0 and is not discounted.
+func NetPresentValue(
+    rate float64,
+    cashFlows []float64,
+) (
+    npv float64,
+) {
+    for i, cf := range cashFlows {
+        npv += cf / math.Pow(1+rate, float64(i))
+    }
+    return npv
+}
+
+// is_not_finite returns true if the value is NaN or infinite.
+func is_not_finite(value float64) bool {
+    return math.IsNaN(value) || math.IsInf(value, 0)
+}
+
+// irr_bisection narrows down the bracket [low, high], on which the NPV of the
+// cash flows changes its sign, until the root is found.
+func irr_bisection(
+    cashFlows []float64,
+    low float64,
+    high float64,
+) (
+    float64,
+    error,
+) {
+    npv_low := NetPresentValue(low, cashFlows)
+    for i := 0; i < irrMaxIterations; i++ {
+        mid := (low + high) / 2
+        npv_mid := NetPresentValue(mid, cashFlows)
+        if npv_mid == 0 || (high - low) / 2 < irrTolerance {
+            return mid, nil
+        }
+        if math.Signbit(npv_mid) == math.Signbit(npv_low) {
+            low, npv_low = mid, npv_mid
+        } else {
+            high = mid
+        }
+    }
+    return 0.0, &ConvergenceError{"cashFlows", irrMaxIterations, "The IRR bisection did not reach the tolerance"}
+}
+
+// InternalRateOfReturn returns the rate on which the net present value of the
+// cash flow is zero. The cash flow needs at least one negative and one
+// positive value. When the cash flow changes sign more than once there can be
+// more than one rate that zeroes the NPV, in that case the rate closest to
+// zero is returned. If no rate is found a ConvergenceError is returned.
+func InternalRateOfReturn(
+    cashFlows []float64,
+) (
+    irr float64,
+    err error,
+) {
+    if len(cashFlows) < 2 {
+        return 0.0, &ValidationError{"cashFlows", cashFlows, "The cash flow must have at least 2 values"}
+    }
+    has_positive, has_negative := false, false
+    for _, cf := range cashFlows {
+        if cf > 0 {
+            has_positive = true
+        }
+        if cf < 0 {
+            has_negative = true
+        }
+    }
+    if !has_positive || !has_negative {
+        return 0.0, &ValidationError{"cashFlows", cashFlows, "The cash flow must have at least one positive and one negative value"}
+    }
+
+    // The NPV is scanned on a logarithmic grid of (1 + rate), this gives the
+    // same resolution around the usual rates and the extreme ones. Every sign
+    // change found is a bracket that contains a root.
+    found := false
+    step := (math.Log(1+irrUpperBound) - math.Log(1+irrLowerBound)) / irrScanSteps
+    low := irrLowerBound
+    npv_low := NetPresentValue(low, cashFlows)
+    for i := 1; i <= irrScanSteps; i++ {
+        high := math.Exp(math.Log(1+irrLowerBound) + float64(i)*step) - 1
+        npv_high := NetPresentValue(high, cashFlows)
+        // Long cash flows overflow close to the lower bound, those points
+        // cannot be compared and are skipped.
+        if is_not_finite(npv_low) || is_not_finite(npv_high) {
+            low, npv_low = high, npv_high
+            continue
+        }
+        if npv_low == 0 || math.Signbit(npv_low) != math.Signbit(npv_high) {
+            root := low
+            if npv_low != 0 {
+                root, err = irr_bisection(cashFlows, low, high)
+                if err != nil {
+                    return 0.0, err
+                }
+            }
+            if !found || math.Abs(root) < math.Abs(irr) {
+                irr = root
+            }
+            found = true
+        }
+        low, npv_low = high, npv_high
+    }
+    if !found {
+        return 0.0, &ConvergenceError{"cashFlows", irrScanSteps, "No rate that zeroes the NPV was found"}
+    }
+    return utils.Round4(irr), nil
+}
+
+// normal_cdf returns the cumulative distribution function of the standard
+// normal distribution.
+func normal_cdf(x float64) float64 {
+    return 0.5 * (1 + math.Erf(x / math.Sqrt2))
+}
+
+// BlackCaplet returns the price of a caplet with the Black model. The forward
+// rate and the strike are annual rates, the expiry is the time in years up to
+// the fixing of the rate, the accrual is the fraction of the year that the
+// caplet covers and the discountFactor brings the payment of the caplet to
+// today. A caplet that is already fixed (expiry 0) is worth its intrinsic
+// value, as well as one on a forward rate that is not positive.
+func BlackCaplet(
+    forward float64,
+    strike float64,
+    volatility float64,
+    expiry float64,
+    accrual float64,
+    discountFactor float64,
+    notional float64,
+) (
+    price float64,
+    err error,
+) {
+    if strike <= 0 {
+        return 0.0, &ValidationError{"strike", strike, "The value must be greater than 0"}
+    }
+    if volatility < 0 {
+        return 0.0, &ValidationError{"volatility", volatility, "The value cannot be lower than 0"}
+    }
+    if expiry < 0 {
+        return 0.0, &ValidationError{"expiry", expiry, "The value cannot be lower than 0"}
+    }
+    if accrual <= 0 {
+        return 0.0, &ValidationError{"accrual", accrual, "The value must be greater than 0"}
+    }
+    if expiry == 0 || volatility == 0 || forward <= 0 {
+        price = notional * accrual * discountFactor * math.Max(forward - strike, 0)
+        return utils.Round2(price), nil
+    }
+    std_dev := volatility * math.Sqrt(expiry)
+    d1 := (math.Log(forward/strike) + 0.5*std_dev*std_dev) / std_dev
+    d2 := d1 - std_dev
+    price = notional * accrual * discountFactor * (forward*normal_cdf(d1) - strike*normal_cdf(d2))
+    return utils.Round2(price), nil
+}
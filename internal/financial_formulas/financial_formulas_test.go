@@ -278,3 +278,197 @@ func TestPresentValue(t *testing.T){
         })
     }
 }
+
+
+func TestNetPresentValue(t *testing.T){
+    var testCases = []struct {
+        name string
+        rate float64
+        cashFlows []float64
+        want float64
+    }{
+        {
+            name: "Zero rate",
+            rate: 0,
+            cashFlows: []float64{-1000, 300, 400, 500},
+            want: 200,
+        },
+        {
+            name: "Totally valid case",
+            rate: 0.08,
+            cashFlows: []float64{-1000, 300, 400, 500},
+            want: 17.63,
+        },
+    }
+
+    for _, test := range testCases {
+        t.Run(test.name, func(t *testing.T) {
+            if got := NetPresentValue(test.rate, test.cashFlows); !utils.Tolerance(got, test.want, TOL) {
+                t.Errorf("got: %g, wanted: %g", got, test.want)
+            }
+        })
+    }
+}
+
+
+func TestInternalRateOfReturn(t *testing.T){
+    var testCases = []struct {
+        name string
+        cashFlows []float64
+        want float64
+        wantErr bool
+    }{
+        {
+            name: "Single period",
+            cashFlows: []float64{-100, 110},
+            want: 0.1,
+        },
+        {
+            name: "Totally valid case",
+            cashFlows: []float64{-1000, 300, 400, 500},
+            want: 0.089,
+        },
+        {
+            name: "Negative rate",
+            cashFlows: []float64{-1000, 300, 300, 300},
+            want: -0.0509,
+        },
+        {
+            name: "Multiple sign changes, closest to zero",
+            cashFlows: []float64{-100, 230, -132},
+            want: 0.1,
+        },
+        {
+            name: "Invalid, only one value",
+            cashFlows: []float64{-100},
+            wantErr: true,
+        },
+        {
+            name: "Invalid, no positive values",
+            cashFlows: []float64{-100, -10, 0},
+            wantErr: true,
+        },
+        {
+            name: "No real root",
+            cashFlows: []float64{1, -3, 3},
+            wantErr: true,
+        },
+    }
+
+    for _, test := range testCases {
+        t.Run(test.name, func(t *testing.T) {
+            got, err := InternalRateOfReturn(test.cashFlows)
+            if test.wantErr {
+                if err == nil {
+                    t.Errorf("expected an error, got: %g", got)
+                }
+                return
+            }
+            if err != nil {
+                t.Errorf("InternalRateOfReturn internal error: %v", err)
+                return
+            }
+            if !utils.Tolerance(got, test.want, 0.0001) {
+                t.Errorf("got: %g, wanted: %g", got, test.want)
+            }
+        })
+    }
+}
+
+func TestInternalRateOfReturnConvergenceError(t *testing.T){
+    _, err := InternalRateOfReturn([]float64{1, -3, 3})
+    if _, ok := err.(*ConvergenceError); !ok {
+        t.Errorf("expected a ConvergenceError, got: %v", err)
+    }
+}
+
+
+func TestBlackCaplet(t *testing.T){
+    var testCases = []struct {
+        name string
+        forward float64
+        strike float64
+        volatility float64
+        expiry float64
+        accrual float64
+        discountFactor float64
+        notional float64
+        want float64
+        wantErr bool
+    }{
+        {
+            name: "At the money",
+            forward: 0.05,
+            strike: 0.05,
+            volatility: 0.2,
+            expiry: 1,
+            accrual: 1,
+            discountFactor: 1,
+            notional: 1000000,
+            want: 3982.78,
+        },
+        {
+            name: "In the money, quarterly",
+            forward: 0.06,
+            strike: 0.05,
+            volatility: 0.3,
+            expiry: 2,
+            accrual: 0.25,
+            discountFactor: 0.9,
+            notional: 1000000,
+            want: 3389.55,
+        },
+        {
+            name: "Out of the money",
+            forward: 0.04,
+            strike: 0.05,
+            volatility: 0.3,
+            expiry: 0.5,
+            accrual: 1,
+            discountFactor: 0.95,
+            notional: 1000000,
+            want: 677.08,
+        },
+        {
+            name: "Already fixed, intrinsic value",
+            forward: 0.06,
+            strike: 0.05,
+            volatility: 0.3,
+            expiry: 0,
+            accrual: 1,
+            discountFactor: 1,
+            notional: 1000000,
+            want: 10000,
+        },
+        {
+            name: "Invalid strike",
+            forward: 0.06,
+            strike: 0,
+            volatility: 0.3,
+            expiry: 1,
+            accrual: 1,
+            discountFactor: 1,
+            notional: 1000000,
+            wantErr: true,
+        },
+    }
+
+    for _, test := range testCases {
+        t.Run(test.name, func(t *testing.T) {
+            got, err := BlackCaplet(test.forward, test.strike, test.volatility, test.expiry, test.accrual, test.discountFactor, test.notional)
+            if test.wantErr {
+                if err == nil {
+                    t.Errorf("expected an error, got: %g", got)
+                }
+                return
+            }
+            if err != nil {
+                t.Errorf("BlackCaplet internal error: %v", err)
+                return
+            }
+            if !utils.Tolerance(got, test.want, TOL) {
+                t.Errorf("got: %g, wanted: %g", got, test.want)
+            }
+        })
+    }
+}
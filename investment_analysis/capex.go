@@ -0,0 +1,267 @@
+// Capital expenditure schedule of the deal. The capex budget of a value add
+// deal is spent on the years of its items, separate from the reserves, funded
+// by the equity, by a holdback of the loan or by draws on the loan, and the
+// improvements are depreciated in the income tax of every year.
+
+package investment_analysis
+
+import (
+    "fmt";
+    "math";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+    ls "github.com/jacobitosuperstar/go-cre-loan-calculations/loan_sizer";
+)
+
+// CapexFunding is the source of the money of a capex item.
+type CapexFunding string
+
+const (
+    // EquityFunding pays the item with the cash flow of its year.
+    EquityFunding       CapexFunding = "equity"
+    // HoldbackFunding pays the item with a part of the loan held back by the
+    // lender at the adquisition and released when the item is spent.
+    HoldbackFunding     CapexFunding = "holdback"
+    // LoanFunding pays the item with a draw on the loan, the draws pay
+    // interest only at the rate of the loan and are paid off with it.
+    LoanFunding         CapexFunding = "loan"
+)
+
+// CapexItem is a line of the capital expenditure budget, like a new roof or
+// the renovation of the units.
+//
+// The Amount (negative) is spent on the given Year of the projection and is
+// capitalized, depreciated in straight line over the DepreciationYears from
+// that year on. The Funding is where the money comes from.
+type CapexItem struct {
+    Name                string
+    Year                int
+    Amount              float64
+    DepreciationYears   int
+    Funding             CapexFunding
+}
+
+// NewCapexItem returns a CapexItem struct if the values given are valid. If
+// not, returns a default struct with the error.
+func NewCapexItem(
+    name string,
+    year int,
+    amount float64,
+    depreciationYears int,
+    funding CapexFunding,
+) (
+    CapexItem,
+    error,
+) {
+    // Data Validation
+    if year <= 0 {
+        return CapexItem{}, fmt.Errorf("The year of the capex %v must be greater than 0.", name)
+    }
+    if amount > 0 {
+        return CapexItem{}, fmt.Errorf("The amount of the capex %v cannot be greater than 0.", name)
+    }
+    if depreciationYears <= 0 {
+        return CapexItem{}, fmt.Errorf("The depreciationYears of the capex %v must be greater than 0.", name)
+    }
+    switch funding {
+    case EquityFunding, HoldbackFunding, LoanFunding:
+    default:
+        return CapexItem{}, fmt.Errorf("The funding of the capex %v must be EquityFunding, HoldbackFunding or LoanFunding.", name)
+    }
+    // Struct Creation
+    item := CapexItem{
+        Name: name,
+        Year: year,
+        Amount: amount,
+        DepreciationYears: depreciationYears,
+        Funding: funding,
+    }
+    return item, nil
+}
+
+// CapexSchedule is the list of the capex items of the deal.
+type CapexSchedule struct {
+    Items       []CapexItem
+}
+
+// NewCapexSchedule returns a CapexSchedule struct if the items given are
+// valid.
+func NewCapexSchedule(items ...CapexItem) (CapexSchedule, error) {
+    validated := make([]CapexItem, len(items))
+    for i, item := range items {
+        v, err := NewCapexItem(
+            item.Name,
+            item.Year,
+            item.Amount,
+            item.DepreciationYears,
+            item.Funding,
+        )
+        if err != nil {
+            return CapexSchedule{}, fmt.Errorf("NewCapexItem internal error: %v", err)
+        }
+        validated[i] = v
+    }
+    return CapexSchedule{Items: validated}, nil
+}
+
+// capex_years has the capex of every year of the projection up to the sale.
+// The capital expenditures and the depreciation are negative, the holdback
+// released and the draws on the loan positive. The basis is the capex spent
+// up to the sale (positive).
+type capex_years struct {
+    capital_expenditures    []float64
+    holdback                []float64
+    draws                   []float64
+    depreciation            []float64
+    basis                   float64
+}
+
+// capex_years returns the capex of the deal for every year up to the given
+// year of sale. The items after the sale are never spent.
+func (cs CapexSchedule) capex_years (saleYear int) capex_years {
+    years := capex_years{
+        capital_expenditures: make([]float64, saleYear),
+        holdback: make([]float64, saleYear),
+        draws: make([]float64, saleYear),
+        depreciation: make([]float64, saleYear),
+    }
+    for _, item := range cs.Items {
+        if item.Year > saleYear {
+            continue
+        }
+        i := item.Year - 1
+        years.capital_expenditures[i] += item.Amount
+        switch item.Funding {
+        case HoldbackFunding:
+            years.holdback[i] -= item.Amount
+        case LoanFunding:
+            years.draws[i] -= item.Amount
+        }
+        years.basis -= item.Amount
+        depreciation := utils.Round2(item.Amount / float64(item.DepreciationYears))
+        for year := i; year < i + item.DepreciationYears && year < saleYear; year++ {
+            years.depreciation[year] += depreciation
+        }
+    }
+    for i := 0; i < saleYear; i++ {
+        years.capital_expenditures[i] = utils.Round2(years.capital_expenditures[i])
+        years.holdback[i] = utils.Round2(years.holdback[i])
+        years.draws[i] = utils.Round2(years.draws[i])
+        years.depreciation[i] = utils.Round2(years.depreciation[i])
+    }
+    years.basis = utils.Round2(years.basis)
+    return years
+}
+
+// capex_years returns the capex of every year of the deal up to the sale,
+// with no capex if the deal has no CapexSchedule.
+func (roi ReturnOfInvestment) capex_years () capex_years {
+    var cs CapexSchedule
+    if roi.capexSchedule != nil {
+        cs = *roi.capexSchedule
+    }
+    return cs.capex_years(roi.saleMetrics.SaleYear)
+}
+
+// CapexHoldback returns the part of the loan held back by the lender at the
+// adquisition to pay the capex items funded by the holdback up to the sale
+// (positive).
+func (roi ReturnOfInvestment) CapexHoldback () float64 {
+    holdback := 0.0
+    for _, released := range roi.capex_years().holdback {
+        holdback += released
+    }
+    return utils.Round2(holdback)
+}
+
+// CapexDraws returns the draws on the loan to pay the capex items funded by
+// the loan up to the sale (positive), the future funding of the loan
+// committed at the adquisition on top of the maximum loan amount.
+func (roi ReturnOfInvestment) CapexDraws () float64 {
+    draws := 0.0
+    for _, draw := range roi.capex_years().draws {
+        draws += draw
+    }
+    return utils.Round2(draws)
+}
+
+// future_funding checks that the loan fully funded, the maximum loan amount
+// plus the draws for the capex, is within the sizing constraints of the loan.
+// The loan to value and the loan to cost of the loan fully funded are taken
+// on the value and the cost of the project with the capex spent up to the
+// sale. The draws are taken on the loan of the adquisition, the ones after
+// a refinance are not allowed.
+func (roi ReturnOfInvestment) future_funding (mla float64) error {
+    if roi.refinance != nil {
+        capex := roi.capex_years()
+        for i := roi.refinance.Year; i < roi.saleMetrics.SaleYear; i++ {
+            if capex.draws[i] != 0 {
+                return fmt.Errorf("The capex funded by the loan must be spent up to the refinance.")
+            }
+        }
+    }
+    draws := roi.CapexDraws()
+    if draws == 0 {
+        return nil
+    }
+    capex := int(math.Ceil(roi.capex_years().basis))
+    loan := roi.loanMetrics
+    if loan.ProjectCost == 0 {
+        loan.ProjectCost = loan.PropertyValue
+    }
+    loan.PropertyValue += capex
+    loan.ProjectCost += capex
+    loan.RequestedLoanAmount = int(math.Ceil(mla + draws))
+    funded, err := loan.MaximumLoanAmount()
+    if err != nil {
+        return fmt.Errorf("MaximumLoanAmount internal error: %v", err)
+    }
+    if funded < mla + draws {
+        return fmt.Errorf("The capex funded by the loan cannot take the loan over its sizing constraints.")
+    }
+    return nil
+}
+
+// loan_origination_fees returns the origination fees of the loan, charged at
+// the adquisition on the maximum loan amount and the draws for the capex
+// (positive).
+func (roi ReturnOfInvestment) loan_origination_fees (mla float64) float64 {
+    return utils.Round2(roi.loanMetrics.LoanOriginationFees * (mla + roi.CapexDraws()))
+}
+
+// annual_rate returns the average rate that the loan pays on the given year of
+// its term, starting at 0. After the term the rate of the last year is used.
+func annual_rate (loan ls.LoanSizer, year int) float64 {
+    rates := loan.PeriodRates()
+    periods := len(rates) / loan.Term
+    start := year * periods
+    if start > len(rates) - periods {
+        start = len(rates) - periods
+    }
+    rate := 0.0
+    for _, period_rate := range rates[start:start + periods] {
+        rate += period_rate
+    }
+    return rate / float64(periods)
+}
+
+// WithCapex returns a copy of the ReturnOfInvestment with the given capital
+// expenditure schedule. The capex of every year is paid from the cash flow
+// below the NOI, with the holdback released and the draws on the loan
+// funding the items that use them. The draws are a future funding of the
+// loan of the adquisition, spent up to the refinance if the deal has one, the
+// loan fully funded must be within its sizing constraints and the
+// origination fees are charged on the draws at the adquisition. The
+// improvements are depreciated in the income tax, their depreciation is
+// recaptured at the sale and the capex spent is added to the cost of the
+// project for the capital gains.
+func (roi ReturnOfInvestment) WithCapex (cs CapexSchedule) (ReturnOfInvestment, error) {
+    validated, err := NewCapexSchedule(cs.Items...)
+    if err != nil {
+        return roi, fmt.Errorf("NewCapexSchedule internal error: %v", err)
+    }
+    if len(validated.Items) == 0 {
+        return roi, fmt.Errorf("The capex schedule must have at least one item.")
+    }
+    roi.capexSchedule = &validated
+    return roi, nil
+}
@@ -0,0 +1,286 @@
+package investment_analysis
+import (
+    "testing";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+    ls "github.com/jacobitosuperstar/go-cre-loan-calculations/loan_sizer";
+)
+
+// testCapexItems are the capex budget of the test deal, a roof on the year 2
+// paid by the equity, the renovation of the units over the years 1 to 3 paid
+// by the holdback of the loan and a lobby on the year 3 paid by a draw on the
+// loan.
+var testCapexItems = []CapexItem{
+    {"Roof", 2, -150000, 15, EquityFunding},
+    {"Unit renovations", 1, -100000, 27, HoldbackFunding},
+    {"Unit renovations", 2, -100000, 27, HoldbackFunding},
+    {"Unit renovations", 3, -100000, 27, HoldbackFunding},
+    {"Lobby", 3, -200000, 39, LoanFunding},
+}
+
+func TestCapex(t *testing.T) {
+    base, err := newTestROI(baseTestROI)
+    if err != nil {
+        t.Errorf("ReturnOfInvestment internal error: %v", err)
+        return
+    }
+    roi, err := base.WithCapex(CapexSchedule{Items: testCapexItems})
+    if err != nil {
+        t.Errorf("WithCapex internal error: %v", err)
+        return
+    }
+    projection, err := roi.Projection()
+    if err != nil {
+        t.Errorf("Projection internal error: %v", err)
+        return
+    }
+
+    t.Run("Holdback at the adquisition", func(t *testing.T) {
+        // the origination fees are charged on the draw of the lobby too.
+        if projection.Acquisition.CapexHoldback != -300000 ||
+            projection.Acquisition.LoanOriginationFees != -47500 ||
+            projection.Acquisition.NetCashFlow != -2522500 {
+            t.Errorf("Acquisition got: %+v, wanted holdback %v, origination fees %v and net cash flow %v", projection.Acquisition, -300000, -47500, -2522500)
+        }
+    })
+
+    t.Run("Capex of every year", func(t *testing.T) {
+        var testCases = []struct {
+            year int
+            capital_expenditures float64
+            capex_funding float64
+            interest_payment float64
+            depreciation_expense float64
+        }{
+            {1, -100000, 100000, -204750, -172222.22},
+            // the roof is paid by the equity.
+            {2, -250000, 100000, -204750, -185925.92},
+            // the draw of the lobby pays 9000 of interest at 4.5%.
+            {3, -300000, 300000, -213750, -194757.83},
+            {4, 0, 0, -210393.83, -194757.83},
+        }
+        for _, test := range testCases {
+            got := projection.Years[test.year - 1]
+            if !utils.Tolerance(got.CapitalExpenditures, test.capital_expenditures, 0.01) ||
+                !utils.Tolerance(got.CapexFunding, test.capex_funding, 0.01) ||
+                !utils.Tolerance(got.InterestPayment, test.interest_payment, 0.01) ||
+                !utils.Tolerance(got.DepreciationExpense, test.depreciation_expense, 0.01) {
+                t.Errorf("Year %v got: %+v, wanted: %+v", test.year, got, test)
+            }
+        }
+    })
+
+    t.Run("Cash flows and sale", func(t *testing.T) {
+        want := []float64{-2522500.0, 113036.53, -20928.16, 137634.89, 150415.34, 163691.84, 177482.81, 191807.32, 206685.13, 222136.72, 4283412.7}
+        for year, cash_flow := range projection.CashFlows() {
+            if !utils.Tolerance(cash_flow, want[year], 0.01) {
+                t.Errorf("Year %v net_cash_flow got: %v, wanted: %v", year, cash_flow, want[year])
+            }
+        }
+        // the draw is paid off with the loan, the depreciation of the capex
+        // is recaptured and the capex is part of the cost of the project.
+        sale := projection.Sale
+        if !utils.Tolerance(sale.BalloonPayment, -4050424.34, 0.01) ||
+            !utils.Tolerance(sale.DepreciationRecaptureTax, -479052.7, 0.01) ||
+            !utils.Tolerance(sale.CapitalGainsTax, -211712.9, 0.01) {
+            t.Errorf("SaleEvent got: %+v, wanted balloon %v, recapture %v and capital gains %v", sale, -4050424.34, -479052.7, -211712.9)
+        }
+        metrics, err := return_metrics(projection)
+        if err != nil {
+            t.Errorf("return_metrics internal error: %v", err)
+            return
+        }
+        if !utils.Tolerance(metrics.LeveredIRR, 0.0954, 0.0001) {
+            t.Errorf("LeveredIRR got: %v, wanted: %v", metrics.LeveredIRR, 0.0954)
+        }
+    })
+
+    t.Run("Draws paid off at the maturity of the loan", func(t *testing.T) {
+        without_draws, err := base.WithCapex(CapexSchedule{Items: testCapexItems[:4]})
+        if err != nil {
+            t.Errorf("WithCapex internal error: %v", err)
+            return
+        }
+        late_sale, err := roi.WithHoldPastMaturity().with_sale_year(12)
+        if err != nil {
+            t.Errorf("with_sale_year internal error: %v", err)
+            return
+        }
+        without_draws, err = without_draws.WithHoldPastMaturity().with_sale_year(12)
+        if err != nil {
+            t.Errorf("with_sale_year internal error: %v", err)
+            return
+        }
+        got, err := late_sale.Projection()
+        if err != nil {
+            t.Errorf("Projection internal error: %v", err)
+            return
+        }
+        want, err := without_draws.Projection()
+        if err != nil {
+            t.Errorf("Projection internal error: %v", err)
+            return
+        }
+        if !utils.Tolerance(got.Years[9].LoanPayoff - want.Years[9].LoanPayoff, -200000, 0.01) {
+            t.Errorf("LoanPayoff of the year 10 got: %v, wanted: %v", got.Years[9].LoanPayoff, want.Years[9].LoanPayoff - 200000)
+        }
+        if got.Years[9].PrincipalPayment != want.Years[9].PrincipalPayment {
+            t.Errorf("PrincipalPayment of the year 10 got: %v, wanted: %v", got.Years[9].PrincipalPayment, want.Years[9].PrincipalPayment)
+        }
+        if got.Years[10].InterestPayment != 0 || got.Sale.BalloonPayment != 0 {
+            t.Errorf("InterestPayment and BalloonPayment after the maturity got: %v %v, wanted: 0 0", got.Years[10].InterestPayment, got.Sale.BalloonPayment)
+        }
+    })
+
+    t.Run("Refinance", func(t *testing.T) {
+        loan, err := ls.NewLoanSizer(0.75, 1.25, 30, 7, 0, 0.05, 0, 0, 0, 0.01)
+        if err != nil {
+            t.Errorf("NewLoanSizer internal error: %v", err)
+            return
+        }
+        refinance, err := NewRefinance(3, 0.065, loan, 25000)
+        if err != nil {
+            t.Errorf("NewRefinance internal error: %v", err)
+            return
+        }
+        with_draws, err := roi.WithRefinance(refinance)
+        if err != nil {
+            t.Errorf("WithRefinance internal error: %v", err)
+            return
+        }
+        without_draws, err := base.WithCapex(CapexSchedule{Items: testCapexItems[:4]})
+        if err != nil {
+            t.Errorf("WithCapex internal error: %v", err)
+            return
+        }
+        without_draws, err = without_draws.WithRefinance(refinance)
+        if err != nil {
+            t.Errorf("WithRefinance internal error: %v", err)
+            return
+        }
+        got, err := with_draws.Projection()
+        if err != nil {
+            t.Errorf("Projection internal error: %v", err)
+            return
+        }
+        want, err := without_draws.Projection()
+        if err != nil {
+            t.Errorf("Projection internal error: %v", err)
+            return
+        }
+        // the draw is paid off with the old loan.
+        if !utils.Tolerance(got.Refinance.LoanPayoff - want.Refinance.LoanPayoff, -200000, 0.01) {
+            t.Errorf("LoanPayoff got: %v, wanted: %v", got.Refinance.LoanPayoff, want.Refinance.LoanPayoff - 200000)
+        }
+        if got.Sale.BalloonPayment != want.Sale.BalloonPayment {
+            t.Errorf("BalloonPayment got: %v, wanted: %v", got.Sale.BalloonPayment, want.Sale.BalloonPayment)
+        }
+
+        late_holdback, err := base.WithCapex(CapexSchedule{Items: []CapexItem{{"Unit renovations", 4, -100000, 27, HoldbackFunding}}})
+        if err != nil {
+            t.Errorf("WithCapex internal error: %v", err)
+            return
+        }
+        late_holdback, err = late_holdback.WithRefinance(refinance)
+        if err != nil {
+            t.Errorf("WithRefinance internal error: %v", err)
+            return
+        }
+        if _, err := late_holdback.Projection(); err == nil {
+            t.Errorf("Projection got no error, wanted an error for a holdback spent after the refinance")
+        }
+
+        // a draw after the refinance would be on the new loan, not sized
+        // with the loan of the adquisition.
+        items := append(append([]CapexItem(nil), testCapexItems[:4]...), CapexItem{"Lobby", 4, -200000, 39, LoanFunding})
+        late_draw, err := base.WithCapex(CapexSchedule{Items: items})
+        if err != nil {
+            t.Errorf("WithCapex internal error: %v", err)
+            return
+        }
+        if _, err := late_draw.Projection(); err != nil {
+            t.Errorf("Projection internal error without the refinance: %v", err)
+        }
+        late_draw, err = late_draw.WithRefinance(refinance)
+        if err != nil {
+            t.Errorf("WithRefinance internal error: %v", err)
+            return
+        }
+        if _, err := late_draw.Projection(); err == nil {
+            t.Errorf("Projection got no error, wanted an error for a draw after the refinance")
+        }
+        if _, err := late_draw.AdquisitionCost(); err == nil {
+            t.Errorf("AdquisitionCost got no error, wanted an error for a draw after the refinance")
+        }
+    })
+}
+
+func TestNewCapexSchedule(t *testing.T) {
+    var testCases = []struct {
+        name string
+        items []CapexItem
+    }{
+        {"Year 0", []CapexItem{{"Roof", 0, -150000, 15, EquityFunding}}},
+        {"Positive amount", []CapexItem{{"Roof", 2, 150000, 15, EquityFunding}}},
+        {"No depreciation years", []CapexItem{{"Roof", 2, -150000, 0, EquityFunding}}},
+        {"Unknown funding", []CapexItem{{"Roof", 2, -150000, 15, "grant"}}},
+    }
+    for _, test := range testCases {
+        t.Run(test.name, func(t *testing.T) {
+            if _, err := NewCapexSchedule(test.items...); err == nil {
+                t.Errorf("NewCapexSchedule got no error, wanted an error")
+            }
+        })
+    }
+
+    t.Run("Holdback greater than the loan", func(t *testing.T) {
+        roi, err := newTestROI(baseTestROI)
+        if err != nil {
+            t.Errorf("ReturnOfInvestment internal error: %v", err)
+            return
+        }
+        roi, err = roi.WithCapex(CapexSchedule{Items: []CapexItem{{"Redevelopment", 1, -9000000, 39, HoldbackFunding}}})
+        if err != nil {
+            t.Errorf("WithCapex internal error: %v", err)
+            return
+        }
+        if _, err := roi.Projection(); err == nil {
+            t.Errorf("Projection got no error, wanted an error")
+        }
+    })
+    t.Run("Draws over the loan to cost", func(t *testing.T) {
+        roi, err := newTestROI(baseTestROI)
+        if err != nil {
+            t.Errorf("ReturnOfInvestment internal error: %v", err)
+            return
+        }
+        // the loan is sized at 60% of the 6725000 of cost, 4035000.
+        roi, err = roi.WithMaxLTC(0.60)
+        if err != nil {
+            t.Errorf("WithMaxLTC internal error: %v", err)
+            return
+        }
+        var testCases = []struct {
+            name string
+            lobby float64
+            fails bool
+        }{
+            // 4235000 fully funded, below the 60% of the 7375000 of cost
+            // with the capex.
+            {"Within the loan to cost", -200000, false},
+            // 4835000 fully funded, above the 60% of the 7975000 of cost
+            // with the capex, with a DSCR of 1.31 and a LTV of 62%.
+            {"Over the loan to cost", -800000, true},
+        }
+        for _, test := range testCases {
+            items := append(append([]CapexItem(nil), testCapexItems[:4]...), CapexItem{"Lobby", 3, test.lobby, 39, LoanFunding})
+            with_draws, err := roi.WithCapex(CapexSchedule{Items: items})
+            if err != nil {
+                t.Errorf("WithCapex internal error: %v", err)
+                return
+            }
+            if _, err := with_draws.Projection(); (err != nil) != test.fails {
+                t.Errorf("%v Projection got error: %v, wanted an error: %v", test.name, err, test.fails)
+            }
+        }
+    })
+}
@@ -0,0 +1,241 @@
+// Capital stack of the deal. Above the senior loan of the ReturnOfInvestment
+// there can be mezzanine loans and preferred equity, each one paid in order of
+// priority after the senior loan, on every year and at the sale.
+
+package investment_analysis
+
+import (
+    "fmt";
+    "math";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+)
+
+// TrancheType is the kind of a tranche of the capital stack.
+type TrancheType string
+
+const (
+    MezzanineTranche        TrancheType = "mezzanine"
+    PreferredEquityTranche  TrancheType = "preferred_equity"
+)
+
+// Tranche is a layer of the capital stack above the senior loan.
+//
+// The Rate is the annual interest of a mezzanine loan or the annual preferred
+// return of a preferred equity, and CurrentPay is the share of it that is paid
+// every year, the rest accrues on the balance of the tranche (PIK). The
+// interest of a mezzanine loan is deductible from the income tax, the
+// preferred return is not. MaxCombinedLTV and MinCombinedDSCR are the tests of
+// the tranche over the whole stack up to it, a zero value means that the test
+// is not applied.
+type Tranche struct {
+    Name                string
+    Type                TrancheType
+    Amount              float64
+    Rate                float64
+    CurrentPay          float64
+    MaxCombinedLTV      float64
+    MinCombinedDSCR     float64
+}
+
+// NewTranche returns a Tranche struct if the values given are valid. If not,
+// returns a default struct with the error.
+func NewTranche(
+    name string,
+    trancheType TrancheType,
+    amount float64,
+    rate float64,
+    currentPay float64,
+    maxCombinedLTV float64,
+    minCombinedDSCR float64,
+) (
+    Tranche,
+    error,
+) {
+    // Data Validation
+    if trancheType != MezzanineTranche && trancheType != PreferredEquityTranche {
+        return Tranche{}, fmt.Errorf("The trancheType must be MezzanineTranche or PreferredEquityTranche.")
+    }
+    if amount <= 0 {
+        return Tranche{}, fmt.Errorf("The amount of the tranche must be greater than 0.")
+    }
+    if rate < 0 || rate > 1 {
+        return Tranche{}, fmt.Errorf("The rate of the tranche must be between 0 and 1.")
+    }
+    if currentPay < 0 || currentPay > 1 {
+        return Tranche{}, fmt.Errorf("The currentPay of the tranche must be between 0 and 1.")
+    }
+    if maxCombinedLTV < 0 || maxCombinedLTV > 1 {
+        return Tranche{}, fmt.Errorf("The maxCombinedLTV must be between 0 and 1.")
+    }
+    if minCombinedDSCR < 0 {
+        return Tranche{}, fmt.Errorf("The minCombinedDSCR cannot be lower than 0.")
+    }
+    // Struct Creation
+    tranche := Tranche{
+        Name: name,
+        Type: trancheType,
+        Amount: amount,
+        Rate: rate,
+        CurrentPay: currentPay,
+        MaxCombinedLTV: maxCombinedLTV,
+        MinCombinedDSCR: minCombinedDSCR,
+    }
+    return tranche, nil
+}
+
+// CapitalStack is the ordered list of tranches above the senior loan, from the
+// most senior to the most junior.
+type CapitalStack struct {
+    Tranches    []Tranche
+}
+
+// NewCapitalStack returns a CapitalStack struct if the tranches given are
+// valid. A mezzanine loan cannot be junior to a preferred equity.
+func NewCapitalStack(tranches ...Tranche) (CapitalStack, error) {
+    validated := make([]Tranche, len(tranches))
+    preferred_equity := false
+    for i, tranche := range tranches {
+        t, err := NewTranche(
+            tranche.Name,
+            tranche.Type,
+            tranche.Amount,
+            tranche.Rate,
+            tranche.CurrentPay,
+            tranche.MaxCombinedLTV,
+            tranche.MinCombinedDSCR,
+        )
+        if err != nil {
+            return CapitalStack{}, fmt.Errorf("NewTranche internal error: %v", err)
+        }
+        if t.Type == MezzanineTranche && preferred_equity {
+            return CapitalStack{}, fmt.Errorf("A mezzanine loan cannot be junior to a preferred equity.")
+        }
+        if t.Type == PreferredEquityTranche {
+            preferred_equity = true
+        }
+        validated[i] = t
+    }
+    return CapitalStack{Tranches: validated}, nil
+}
+
+// Amount returns the money put by all the tranches of the stack.
+func (cs CapitalStack) Amount () float64 {
+    amount := 0.0
+    for _, tranche := range cs.Tranches {
+        amount += tranche.Amount
+    }
+    return utils.Round2(amount)
+}
+
+// WithCapitalStack returns a copy of the ReturnOfInvestment with the given
+// tranches above the senior loan. The tranches fund part of the adquisition,
+// are paid in order after the senior loan every year and are paid off at the
+// sale.
+func (roi ReturnOfInvestment) WithCapitalStack (cs CapitalStack) (ReturnOfInvestment, error) {
+    validated, err := NewCapitalStack(cs.Tranches...)
+    if err != nil {
+        return roi, fmt.Errorf("NewCapitalStack internal error: %v", err)
+    }
+    roi.capitalStack = validated
+    return roi, nil
+}
+
+// TrancheTest is the result of the tests of a tranche, with the combined LTV
+// and DSCR of the senior loan and every tranche up to it.
+type TrancheTest struct {
+    Name            string
+    CombinedLTV     float64
+    CombinedDSCR    float64
+    Passes          bool
+}
+
+// CapitalStackTests returns the TrancheTest of every tranche of the stack.
+// The combined DSCR is the year one NOI over the yearly payment of the senior
+// loan plus the current pay of the tranches up to the tested one.
+func (roi ReturnOfInvestment) CapitalStackTests () ([]TrancheTest, error) {
+    tests := make([]TrancheTest, len(roi.capitalStack.Tranches))
+    mla, err := roi.loanMetrics.MaximumLoanAmount()
+    if err != nil {
+        return tests, fmt.Errorf("MaximumLoanAmount internal error: %v", err)
+    }
+    annual_loan_payment, err := roi.loanMetrics.AnnualLoanPayment()
+    if err != nil {
+        return tests, fmt.Errorf("AnnualLoanPayment internal error: %v", err)
+    }
+    noi := roi.year_one_noi()
+    value := float64(roi.dealMetrics.PurchasePrice)
+
+    combined_amount := mla
+    combined_debt_service := - annual_loan_payment
+    for i, tranche := range roi.capitalStack.Tranches {
+        combined_amount += tranche.Amount
+        combined_debt_service += tranche.Amount * tranche.Rate * tranche.CurrentPay
+        test := TrancheTest{Name: tranche.Name, Passes: true}
+        if value > 0 {
+            test.CombinedLTV = utils.Round4(combined_amount / value)
+        }
+        if combined_debt_service > 0 {
+            test.CombinedDSCR = utils.Round4(noi / combined_debt_service)
+        }
+        if tranche.MaxCombinedLTV > 0 && test.CombinedLTV > tranche.MaxCombinedLTV {
+            test.Passes = false
+        }
+        if tranche.MinCombinedDSCR > 0 && combined_debt_service > 0 && test.CombinedDSCR < tranche.MinCombinedDSCR {
+            test.Passes = false
+        }
+        tests[i] = test
+    }
+    return tests, nil
+}
+
+// tranche_ledger keeps the balance of every tranche of the capital stack
+// along the projection.
+type tranche_ledger struct {
+    tranches    []Tranche
+    balances    []float64
+}
+
+// new_tranche_ledger returns the ledger of the capital stack at the
+// adquisition.
+func (cs CapitalStack) new_tranche_ledger () *tranche_ledger {
+    balances := make([]float64, len(cs.Tranches))
+    for i, tranche := range cs.Tranches {
+        balances[i] = tranche.Amount
+    }
+    return &tranche_ledger{tranches: cs.Tranches, balances: balances}
+}
+
+// pay_year pays the current pay of every tranche, in order of priority, with
+// the available cash of the year. What is not paid, the accrual of the rate
+// and any shortfall of cash, is added to the balance of the tranche. Returns
+// the money paid to the tranches (negative) and the interest of the
+// mezzanine loans, deductible from the income tax (negative).
+func (tl *tranche_ledger) pay_year (available float64) (payments float64, deductible_interest float64) {
+    available = math.Max(available, 0)
+    for i, tranche := range tl.tranches {
+        due := utils.Round2(tl.balances[i] * tranche.Rate)
+        current_pay := utils.Round2(math.Min(due * tranche.CurrentPay, available))
+        available -= current_pay
+        payments -= current_pay
+        tl.balances[i] = utils.Round2(tl.balances[i] + due - current_pay)
+        if tranche.Type == MezzanineTranche {
+            deductible_interest -= due
+        }
+    }
+    return utils.Round2(payments), utils.Round2(deductible_interest)
+}
+
+// repay pays off the balance of every tranche, in order of priority, with the
+// available proceeds of the sale. Returns the money paid to the tranches
+// (negative).
+func (tl *tranche_ledger) repay (available float64) float64 {
+    available = math.Max(available, 0)
+    repayment := 0.0
+    for i := range tl.tranches {
+        payoff := math.Min(tl.balances[i], available)
+        available -= payoff
+        repayment -= payoff
+        tl.balances[i] = utils.Round2(tl.balances[i] - payoff)
+    }
+    return utils.Round2(repayment)
+}
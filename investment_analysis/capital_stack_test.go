@@ -0,0 +1,122 @@
+package investment_analysis
+import (
+    "testing";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+)
+
+// testCapitalStack has a mezzanine loan paid current and a preferred equity
+// that accrues half of its preferred return.
+var testCapitalStack = CapitalStack{
+    Tranches: []Tranche{
+        {
+            Name: "mezzanine",
+            Type: MezzanineTranche,
+            Amount: 650000,
+            Rate: 0.10,
+            CurrentPay: 1,
+            MaxCombinedLTV: 0.80,
+        },
+        {
+            Name: "preferred equity",
+            Type: PreferredEquityTranche,
+            Amount: 325000,
+            Rate: 0.12,
+            CurrentPay: 0.5,
+            MaxCombinedLTV: 0.85,
+            MinCombinedDSCR: 1.10,
+        },
+    },
+}
+
+func TestCapitalStackTests(t *testing.T) {
+    roi, err := newTestROI(baseTestROI)
+    if err != nil {
+        t.Errorf("ReturnOfInvestment internal error: %v", err)
+        return
+    }
+    roi, err = roi.WithCapitalStack(testCapitalStack)
+    if err != nil {
+        t.Errorf("WithCapitalStack internal error: %v", err)
+        return
+    }
+    got, err := roi.CapitalStackTests()
+    if err != nil {
+        t.Errorf("CapitalStackTests internal error: %v", err)
+        return
+    }
+    want := []TrancheTest{
+        {Name: "mezzanine", CombinedLTV: 0.80, CombinedDSCR: 1.1254, Passes: true},
+        {Name: "preferred equity", CombinedLTV: 0.85, CombinedDSCR: 1.0651, Passes: false},
+    }
+    for i := range want {
+        if got[i].Name != want[i].Name || got[i].Passes != want[i].Passes ||
+            !utils.Tolerance(got[i].CombinedLTV, want[i].CombinedLTV, 0.0001) ||
+            !utils.Tolerance(got[i].CombinedDSCR, want[i].CombinedDSCR, 0.0001) {
+            t.Errorf("CapitalStackTests got: %+v, wanted: %+v", got[i], want[i])
+        }
+    }
+}
+
+func TestCapitalStackProjection(t *testing.T) {
+    roi, err := newTestROI(baseTestROI)
+    if err != nil {
+        t.Errorf("ReturnOfInvestment internal error: %v", err)
+        return
+    }
+    base, err := roi.Projection()
+    if err != nil {
+        t.Errorf("Projection internal error: %v", err)
+        return
+    }
+    roi, err = roi.WithCapitalStack(testCapitalStack)
+    if err != nil {
+        t.Errorf("WithCapitalStack internal error: %v", err)
+        return
+    }
+    got, err := roi.Projection()
+    if err != nil {
+        t.Errorf("Projection internal error: %v", err)
+        return
+    }
+
+    var testCases = []struct {
+        name string
+        got float64
+        want float64
+    }{
+        {"Acquisition TrancheFunding", got.Acquisition.TrancheFunding, 975000},
+        {"Acquisition NetCashFlow", got.Acquisition.NetCashFlow, base.Acquisition.NetCashFlow + 975000},
+        // 650000 * 10% paid current and half of 325000 * 12%.
+        {"Year 1 TranchePayments", got.Years[0].TranchePayments, -84500},
+        {"Year 1 CashFlowAfterDebtService", got.Years[0].CashFlowAfterDebtService, base.Years[0].CashFlowAfterDebtService - 84500},
+        // only the mezzanine interest is deductible.
+        {"Year 1 IncomeTax", got.Years[0].IncomeTax, base.Years[0].IncomeTax + 65000 * 0.25},
+        // the mezzanine and the accrued preferred equity.
+        {"Sale TrancheRepayment", got.Sale.TrancheRepayment, -(650000 + 582025.49)},
+        {"Sale NetCashFlow", got.Sale.NetCashFlow, base.Sale.NetCashFlow - (650000 + 582025.49)},
+    }
+    for _, test := range testCases {
+        t.Run(test.name, func(t *testing.T) {
+            if !utils.Tolerance(test.got, test.want, 0.01) {
+                t.Errorf("%s got: %g, wanted: %g", test.name, test.got, test.want)
+            }
+        })
+    }
+}
+
+func TestNewCapitalStack(t *testing.T) {
+    invalid := [][]Tranche{
+        {{Name: "no amount", Type: MezzanineTranche, Rate: 0.10, CurrentPay: 1}},
+        {{Name: "bad type", Type: "common", Amount: 100, Rate: 0.10}},
+        {{Name: "bad current pay", Type: MezzanineTranche, Amount: 100, Rate: 0.10, CurrentPay: 1.5}},
+        {
+            {Name: "pref", Type: PreferredEquityTranche, Amount: 100, Rate: 0.10},
+            {Name: "mezz", Type: MezzanineTranche, Amount: 100, Rate: 0.10},
+        },
+    }
+    for _, tranches := range invalid {
+        if _, err := NewCapitalStack(tranches...); err == nil {
+            t.Errorf("expected an error for: %+v", tranches)
+        }
+    }
+}
@@ -0,0 +1,128 @@
+// Hold period analysis. The projection of the deal is run again for every
+// candidate year of sale, to find the year on which selling the property
+// gives the best return.
+
+package investment_analysis
+
+import (
+    "fmt";
+    "sort";
+)
+
+// ProfitTarget is the profit of the deal, only used to rank the hold periods.
+const ProfitTarget ReturnTarget = "profit"
+
+// HoldPeriodResult is a struct with the return metrics of the deal when the
+// property is sold on the SaleYear.
+type HoldPeriodResult struct {
+    SaleYear    int
+    Metrics     ReturnMetrics
+}
+
+// HoldPeriodAnalysis is a struct with the HoldPeriodResult of every candidate
+// year of sale, ranked from the best to the worst by the Metric. The Optimal
+// is the first of the Ranked results.
+type HoldPeriodAnalysis struct {
+    Metric      ReturnTarget
+    Ranked      []HoldPeriodResult
+    Optimal     HoldPeriodResult
+}
+
+// metric_value returns the value of the given metric of the ReturnMetrics.
+func metric_value (metrics ReturnMetrics, metric ReturnTarget) float64 {
+    switch metric {
+    case LeveredIRRTarget:
+        return metrics.LeveredIRR
+    case EquityMultipleTarget:
+        return metrics.EquityMultiple
+    case ProfitTarget:
+        return metrics.Profit
+    }
+    return 0
+}
+
+// WithHoldPastMaturity returns a copy of the ReturnOfInvestment on which the
+// property can be held past the maturity of its loan by the hold period
+// analysis. The balance of the loan, with the draws for the capex, is paid off
+// by the equity at the maturity, on the LoanPayoff of that year, and the
+// property runs unlevered up to the sale.
+func (roi ReturnOfInvestment) WithHoldPastMaturity () ReturnOfInvestment {
+    roi.holdPastMaturity = true
+    return roi
+}
+
+// maturity_year returns the year of the projection on which the loan
+// outstanding at the given year of sale matures, the new loan if the deal is
+// refinanced before the sale.
+func (roi ReturnOfInvestment) maturity_year (saleYear int) int {
+    if roi.refinance != nil && roi.refinance.Year < saleYear {
+        return roi.refinance.Year + roi.refinance.Loan.Term
+    }
+    return roi.loanMetrics.Term
+}
+
+// with_sale_year returns a copy of the ReturnOfInvestment with the property
+// sold on the given year. The year of sale can only be after the maturity of
+// the loan if the deal is held past it, on which case the loan is paid off at
+// its maturity. If the sale happens before the refinance, the refinance is
+// dropped.
+func (roi ReturnOfInvestment) with_sale_year (saleYear int) (ReturnOfInvestment, error) {
+    saleTerms, err := NewSaleTerms(
+        roi.saleMetrics.ExitCapRate,
+        roi.saleMetrics.CostOfSale,
+        saleYear,
+    )
+    if err != nil {
+        return roi, fmt.Errorf("NewSaleTerms internal error: %v", err)
+    }
+    if saleYear > roi.maturity_year(saleYear) && !roi.holdPastMaturity {
+        return roi, fmt.Errorf("The year of sale cannot be greater than the year on which the term of the loan ends.")
+    }
+    roi.saleMetrics = saleTerms
+    if roi.refinance != nil && roi.refinance.Year >= saleYear {
+        roi.refinance = nil
+    }
+    return roi, nil
+}
+
+// HoldPeriods returns the HoldPeriodAnalysis of the deal for every year of
+// sale from 1 to the maxSaleYear, ranked by the given metric (LeveredIRR,
+// EquityMultiple or Profit). A zero maxSaleYear goes up to the term of the
+// loan, or up to the term of the new loan if the deal is refinanced. The
+// maxSaleYear can only be after that term if the deal is held past the
+// maturity of the loan, see WithHoldPastMaturity. On a tie, the earlier year
+// of sale is ranked first.
+func (roi ReturnOfInvestment) HoldPeriods (metric ReturnTarget, maxSaleYear int) (HoldPeriodAnalysis, error) {
+    analysis := HoldPeriodAnalysis{Metric: metric}
+
+    if metric != LeveredIRRTarget && metric != EquityMultipleTarget && metric != ProfitTarget {
+        return analysis, fmt.Errorf("The metric must be LeveredIRRTarget, EquityMultipleTarget or ProfitTarget.")
+    }
+    if maxSaleYear < 0 {
+        return analysis, fmt.Errorf("The maxSaleYear cannot be lower than 0.")
+    }
+    maturity_year := roi.maturity_year(roi.saleMetrics.SaleYear)
+    if maxSaleYear == 0 {
+        maxSaleYear = maturity_year
+    }
+    if maxSaleYear > maturity_year && !roi.holdPastMaturity {
+        return analysis, fmt.Errorf("The maxSaleYear cannot be greater than the year on which the term of the loan ends.")
+    }
+
+    for sale_year := 1; sale_year <= maxSaleYear; sale_year++ {
+        candidate, err := roi.with_sale_year(sale_year)
+        if err != nil {
+            return analysis, fmt.Errorf("with_sale_year internal error: %v", err)
+        }
+        metrics, err := candidate.ReturnMetrics()
+        if err != nil {
+            return analysis, fmt.Errorf("ReturnMetrics of the year %d internal error: %v", sale_year, err)
+        }
+        analysis.Ranked = append(analysis.Ranked, HoldPeriodResult{SaleYear: sale_year, Metrics: metrics})
+    }
+    sort.SliceStable(analysis.Ranked, func (i, j int) bool {
+        return metric_value(analysis.Ranked[i].Metrics, metric) > metric_value(analysis.Ranked[j].Metrics, metric)
+    })
+    analysis.Optimal = analysis.Ranked[0]
+    return analysis, nil
+}
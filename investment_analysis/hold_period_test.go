@@ -0,0 +1,151 @@
+package investment_analysis
+import (
+    "testing";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+    ls "github.com/jacobitosuperstar/go-cre-loan-calculations/loan_sizer";
+)
+
+func TestHoldPeriods(t *testing.T) {
+    roi, err := newTestROI(baseTestROI)
+    if err != nil {
+        t.Errorf("ReturnOfInvestment internal error: %v", err)
+        return
+    }
+    past_maturity := roi.WithHoldPastMaturity()
+
+    var testCases = []struct {
+        name string
+        metric ReturnTarget
+        maxSaleYear int
+        pastMaturity bool
+        wantYears int
+        wantRanking []int
+    }{
+        {"Levered IRR up to the term", LeveredIRRTarget, 0, false, 10, []int{10, 9, 8}},
+        {"Levered IRR after the term", LeveredIRRTarget, 12, true, 12, []int{10, 9, 11}},
+        {"Equity multiple after the term", EquityMultipleTarget, 12, true, 12, []int{12, 11, 10}},
+        {"Profit up to the term", ProfitTarget, 0, false, 10, []int{10, 9, 8}},
+    }
+    for _, test := range testCases {
+        t.Run(test.name, func(t *testing.T) {
+            deal := roi
+            if test.pastMaturity {
+                deal = past_maturity
+            }
+            got, err := deal.HoldPeriods(test.metric, test.maxSaleYear)
+            if err != nil {
+                t.Errorf("HoldPeriods internal error: %v", err)
+                return
+            }
+            if len(got.Ranked) != test.wantYears {
+                t.Errorf("Ranked got: %v years, wanted: %v", len(got.Ranked), test.wantYears)
+                return
+            }
+            for i, year := range test.wantRanking {
+                if got.Ranked[i].SaleYear != year {
+                    t.Errorf("Ranked %v got: year %v, wanted: year %v", i, got.Ranked[i].SaleYear, year)
+                }
+            }
+            if got.Optimal != got.Ranked[0] {
+                t.Errorf("Optimal got: %+v, wanted: %+v", got.Optimal, got.Ranked[0])
+            }
+        })
+    }
+
+    t.Run("Same metrics as the deal", func(t *testing.T) {
+        got, err := roi.HoldPeriods(LeveredIRRTarget, 0)
+        if err != nil {
+            t.Errorf("HoldPeriods internal error: %v", err)
+            return
+        }
+        want, err := roi.ReturnMetrics()
+        if err != nil {
+            t.Errorf("ReturnMetrics internal error: %v", err)
+            return
+        }
+        if got.Optimal.SaleYear != 10 || got.Optimal.Metrics != want {
+            t.Errorf("Optimal got: %+v, wanted: year 10 %+v", got.Optimal, want)
+        }
+    })
+
+    t.Run("Sale after the term without holding past maturity", func(t *testing.T) {
+        if _, err := roi.HoldPeriods(LeveredIRRTarget, 11); err == nil {
+            t.Errorf("HoldPeriods got no error, wanted an error")
+        }
+        if _, err := roi.with_sale_year(11); err == nil {
+            t.Errorf("with_sale_year got no error, wanted an error")
+        }
+    })
+
+    t.Run("Loan paid off at maturity", func(t *testing.T) {
+        candidate, err := past_maturity.with_sale_year(11)
+        if err != nil {
+            t.Errorf("with_sale_year internal error: %v", err)
+            return
+        }
+        projection, err := candidate.Projection()
+        if err != nil {
+            t.Errorf("Projection internal error: %v", err)
+            return
+        }
+        // the regular principal payment of the year 10, and the balance of
+        // the loan at the end of the term on its own line.
+        year := projection.Years[9]
+        if !utils.Tolerance(year.PrincipalPayment, -101495.14, 0.1) || !utils.Tolerance(year.LoanPayoff, -3850424.34, 0.1) {
+            t.Errorf("Year 10 PrincipalPayment and LoanPayoff got: %v %v, wanted: %v %v", year.PrincipalPayment, year.LoanPayoff, -101495.14, -3850424.34)
+        }
+        cfads := utils.Round2(year.NOI + year.Reserve + year.PrincipalPayment + year.InterestPayment + year.LoanPayoff)
+        if !utils.Tolerance(year.CashFlowAfterDebtService, cfads, 0.02) {
+            t.Errorf("Year 10 CashFlowAfterDebtService got: %v, wanted: %v", year.CashFlowAfterDebtService, cfads)
+        }
+        if projection.Years[10].PrincipalPayment != 0 || projection.Years[10].InterestPayment != 0 || projection.Years[10].LoanPayoff != 0 {
+            t.Errorf("Year 11 got payments: %+v", projection.Years[10])
+        }
+        if projection.Sale.BalloonPayment != 0 {
+            t.Errorf("Sale BalloonPayment got: %v, wanted: 0", projection.Sale.BalloonPayment)
+        }
+        // the payoff leaves the year 10 with a negative cash flow, that
+        // lowers the average cash on cash and is not its peak.
+        metrics, err := return_metrics(projection)
+        if err != nil {
+            t.Errorf("return_metrics internal error: %v", err)
+            return
+        }
+        if year.NetCashFlow >= 0 || metrics.AverageCashOnCash >= 0 || metrics.PeakCashOnCash != projection.cash_on_cash(projection.Years[10]) {
+            t.Errorf("Year 10 NetCashFlow, AverageCashOnCash and PeakCashOnCash got: %v %v %v", year.NetCashFlow, metrics.AverageCashOnCash, metrics.PeakCashOnCash)
+        }
+    })
+
+    t.Run("Refinanced deal", func(t *testing.T) {
+        loan, err := ls.NewLoanSizer(0.75, 1.25, 30, 7, 0, 0.05, 0, 0, 0, 0.01)
+        if err != nil {
+            t.Errorf("NewLoanSizer internal error: %v", err)
+            return
+        }
+        refinanced, err := roi.WithRefinance(Refinance{Year: 3, CapRate: 0.065, Loan: loan})
+        if err != nil {
+            t.Errorf("WithRefinance internal error: %v", err)
+            return
+        }
+        got, err := refinanced.HoldPeriods(LeveredIRRTarget, 0)
+        if err != nil {
+            t.Errorf("HoldPeriods internal error: %v", err)
+            return
+        }
+        // up to the end of the term of the new loan.
+        if len(got.Ranked) != 10 {
+            t.Errorf("Ranked got: %v years, wanted: 10", len(got.Ranked))
+        }
+        // a sale before the refinance drops it.
+        candidate, err := refinanced.with_sale_year(2)
+        if err != nil || candidate.refinance != nil {
+            t.Errorf("with_sale_year got a refinance before the sale, error: %v", err)
+        }
+    })
+
+    t.Run("Invalid metric", func(t *testing.T) {
+        if _, err := roi.HoldPeriods(CashOnCashTarget, 0); err == nil {
+            t.Errorf("expected an error for the year one cash on cash")
+        }
+    })
+}
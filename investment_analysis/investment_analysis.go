@@ -7,15 +7,29 @@
 // [X] LoanTerms
 // [X] SaleMetrics
 // [X] NetCashFlowProjection
-// [ ] ReturnOfInvestment
-// [ ] Target ROI
-// [ ] Objective Search
+// [X] ReturnOfInvestment
+// [X] ReturnMetrics
+// [X] Target ROI
+// [X] Objective Search
+// [X] Capital Stack
+// [X] Waterfall
+// [X] Refinance
+// [X] Hold Period
+// [X] Sensitivity
+// [X] Monte Carlo
+// [X] Growth Vectors
+// [X] Rent Roll
+// [X] Rollover
+// [X] Operating Statement
+// [X] Operating Expenses
+// [X] Capex Schedule
 
 package investment_analysis
 
 import (
     "fmt";
     "math";
+    ff "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/financial_formulas";
     utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
     ls "github.com/jacobitosuperstar/go-cre-loan-calculations/loan_sizer";
 )
@@ -72,6 +86,13 @@ func NewTaxAssumptions (
 
 // DealInformation is a struct that has all the information regarding the
 // buying of the commercial property.
+//
+// The ProjRevenueGrowth, ProjOperatingExpensesGrowth and
+// ProjCapitalReservesGrowth are the growth rates of every year. The
+// RevenueGrowth, OperatingExpensesGrowth and CapitalReservesGrowth vectors,
+// when set, have the growth rate of every year instead, the first rate is the
+// growth from the year 1 to the year 2. Past the end of a vector the constant
+// rate is used.
 type DealInformation struct {
     PurchasePrice                   int
     ClosingAndRenovations           int
@@ -82,6 +103,9 @@ type DealInformation struct {
     ProjRevenueGrowth               float64
     ProjOperatingExpensesGrowth     float64
     ProjCapitalReservesGrowth       float64
+    RevenueGrowth                   []float64
+    OperatingExpensesGrowth         []float64
+    CapitalReservesGrowth           []float64
 }
 
 // NewDealInformation returns a DealInformation struct with the passed in
@@ -110,9 +134,6 @@ func NewDealInformation (
     // if purchasePrice < 0 {
     //     return DealInformation{}, fmt.Errorf("purchasePrice of the property cannot be lower than 0.")
     // }
-    // if closingAndRenovations > 0 {
-    //     return DealInformation{}, fmt.Errorf("closingAndRenovations of the property cannot be greater than 0.")
-    // }
     // if initialRevenue < 0 {
     //     return DealInformation{}, fmt.Errorf("initialRevenue cannot be lower than 0.")
     // }
@@ -123,14 +144,22 @@ func NewDealInformation (
     //     return DealInformation{}, fmt.Errorf("initialCapitalReserves cannot be greater than 0.")
     // }
 
-    if projectedRevenueGrowth < 0 {
-        return DealInformation{}, fmt.Errorf("ProjRevenueGrowth must be greater than 0")
+    // The closing and renovations are a cost, paid at the adquisition like
+    // the purchase price.
+    if closingAndRenovations > 0 {
+        return DealInformation{}, fmt.Errorf("closingAndRenovations of the property cannot be greater than 0.")
+    }
+
+    // The growth can be negative, but a value cannot shrink by a 100% or
+    // more in a year.
+    if projectedRevenueGrowth <= -1 {
+        return DealInformation{}, fmt.Errorf("ProjRevenueGrowth must be greater than -1")
     }
-    if projectedExpensesGrowth < 0 {
-        return DealInformation{}, fmt.Errorf("ProjOperatingExpensesGrowth must be greater than 0")
+    if projectedExpensesGrowth <= -1 {
+        return DealInformation{}, fmt.Errorf("ProjOperatingExpensesGrowth must be greater than -1")
     }
-    if projectedCapitalReservesGrowth < 0 {
-        return DealInformation{}, fmt.Errorf("ProjRevenueGrowth must be greater than 0")
+    if projectedCapitalReservesGrowth <= -1 {
+        return DealInformation{}, fmt.Errorf("ProjCapitalReservesGrowth must be greater than -1")
     }
 
     if purchasePrice == 0 {
@@ -153,6 +182,65 @@ func NewDealInformation (
     return dealInformation, nil
 }
 
+// ProjectCost returns the total cost of the project, the purchase price plus
+// the closing and renovations budget (negative).
+func (di DealInformation) ProjectCost () int {
+    return di.PurchasePrice - di.ClosingAndRenovations
+}
+
+// WithGrowthVectors returns a copy of the DealInformation with the given
+// growth rate of every year for the revenue, the operating expenses and the
+// capital reserves. A nil vector keeps the constant rate of that value.
+func (di DealInformation) WithGrowthVectors (
+    revenueGrowth []float64,
+    expensesGrowth []float64,
+    reservesGrowth []float64,
+) (
+    DealInformation,
+    error,
+) {
+    // Data Validation
+    vectors := []struct {
+        name string
+        rates []float64
+    }{
+        {"revenueGrowth", revenueGrowth},
+        {"expensesGrowth", expensesGrowth},
+        {"reservesGrowth", reservesGrowth},
+    }
+    for _, vector := range vectors {
+        if vector.rates != nil && len(vector.rates) == 0 {
+            return di, fmt.Errorf("The %v vector must have at least one rate.", vector.name)
+        }
+        for year, rate := range vector.rates {
+            if rate <= -1 {
+                return di, fmt.Errorf("The %v of the year %d must be greater than -1.", vector.name, year + 1)
+            }
+        }
+    }
+    // Struct Creation
+    di.RevenueGrowth = append([]float64(nil), revenueGrowth...)
+    di.OperatingExpensesGrowth = append([]float64(nil), expensesGrowth...)
+    di.CapitalReservesGrowth = append([]float64(nil), reservesGrowth...)
+    return di, nil
+}
+
+// growth returns the growth rates of the revenue, the operating expenses and
+// the capital reserves at the end of the given year of the projection,
+// starting at 0.
+func (di DealInformation) growth (year int) (revenue float64, expenses float64, reserves float64) {
+    rate := func (vector []float64, constant float64) float64 {
+        if year < len(vector) {
+            return vector[year]
+        }
+        return constant
+    }
+    revenue = rate(di.RevenueGrowth, di.ProjRevenueGrowth)
+    expenses = rate(di.OperatingExpensesGrowth, di.ProjOperatingExpensesGrowth)
+    reserves = rate(di.CapitalReservesGrowth, di.ProjCapitalReservesGrowth)
+    return revenue, expenses, reserves
+}
+
 
 // SaleTerms is a struc that has all the sale information regarding the sale of
 // the sale of the property.
@@ -199,10 +287,17 @@ func NewSaleTerms(
 
 // ROI of the totallity of the deal.
 type ReturnOfInvestment struct {
-    taxMetrics      TaxAssumptions
-    dealMetrics     DealInformation
-    loanMetrics     ls.LoanSizer
-    saleMetrics     SaleTerms
+    taxMetrics          TaxAssumptions
+    dealMetrics         DealInformation
+    loanMetrics         ls.LoanSizer
+    saleMetrics         SaleTerms
+    capitalStack        CapitalStack
+    refinance           *Refinance
+    rentRoll            *RentRoll
+    operatingStatement  *OperatingStatement
+    operatingExpenses   *OperatingExpenses
+    capexSchedule       *CapexSchedule
+    holdPastMaturity    bool
 }
 
 // Constructor
@@ -274,6 +369,12 @@ func NewReturnOfInvestment(
     if err != nil {
         return ReturnOfInvestment{}, fmt.Errorf("NewLoanSizer Internal error: %v", err)
     }
+    // the loan to cost of the loan is sized on the purchase price plus the
+    // closing and renovations.
+    loanSizer, err = loanSizer.WithProjectCost(dealMetrics.ProjectCost())
+    if err != nil {
+        return ReturnOfInvestment{}, fmt.Errorf("WithProjectCost Internal error: %v", err)
+    }
     taxAssumptions, err := NewTaxAssumptions(
         lanBuildingValue,
         fixDepreciationTimeLine,
@@ -306,6 +407,111 @@ func NewReturnOfInvestment(
     return roi, nil
 }
 
+// WithPaymentFrequency returns a copy of the ReturnOfInvestment with the loan
+// paid with the given frequency. The projection stays yearly, with the loan
+// payments of every year rolled up.
+func (roi ReturnOfInvestment) WithPaymentFrequency (frequency ls.PaymentFrequency) (ReturnOfInvestment, error) {
+    loanSizer, err := roi.loanMetrics.WithPaymentFrequency(frequency)
+    if err != nil {
+        return roi, fmt.Errorf("WithPaymentFrequency internal error: %v", err)
+    }
+    roi.loanMetrics = loanSizer
+    return roi, nil
+}
+
+// WithMinDebtYield returns a copy of the ReturnOfInvestment with the loan
+// sized with the given minimum debt yield as an extra constraint.
+func (roi ReturnOfInvestment) WithMinDebtYield (minDebtYield float64) (ReturnOfInvestment, error) {
+    loanSizer, err := roi.loanMetrics.WithMinDebtYield(minDebtYield)
+    if err != nil {
+        return roi, fmt.Errorf("WithMinDebtYield internal error: %v", err)
+    }
+    roi.loanMetrics = loanSizer
+    return roi, nil
+}
+
+// WithMaxLTC returns a copy of the ReturnOfInvestment with the loan sized
+// with the given maximum loan to cost ratio as an extra constraint. The cost
+// is the purchase price plus the closing and renovations of the deal.
+func (roi ReturnOfInvestment) WithMaxLTC (maxLTC float64) (ReturnOfInvestment, error) {
+    loanSizer, err := roi.loanMetrics.WithMaxLTC(maxLTC)
+    if err != nil {
+        return roi, fmt.Errorf("WithMaxLTC internal error: %v", err)
+    }
+    roi.loanMetrics = loanSizer
+    return roi, nil
+}
+
+// WithFloatingRate returns a copy of the ReturnOfInvestment with a floating
+// rate loan. The interest of every period of the loan comes from the forward
+// curve of the FloatingRate.
+func (roi ReturnOfInvestment) WithFloatingRate (fr ls.FloatingRate) (ReturnOfInvestment, error) {
+    loanSizer, err := roi.loanMetrics.WithFloatingRate(fr)
+    if err != nil {
+        return roi, fmt.Errorf("WithFloatingRate internal error: %v", err)
+    }
+    roi.loanMetrics = loanSizer
+    return roi, nil
+}
+
+// WithRateCap returns a copy of the ReturnOfInvestment with a rate cap bought
+// for its floating rate loan. The premium of the cap is paid at the
+// adquisition and its payouts are credited to the yearly cash flows.
+func (roi ReturnOfInvestment) WithRateCap (rc ls.RateCap) (ReturnOfInvestment, error) {
+    loanSizer, err := roi.loanMetrics.WithRateCap(rc)
+    if err != nil {
+        return roi, fmt.Errorf("WithRateCap internal error: %v", err)
+    }
+    roi.loanMetrics = loanSizer
+    return roi, nil
+}
+
+// WithPrepayment returns a copy of the ReturnOfInvestment with the given
+// prepayment structure of the loan. If the property is sold before the end of
+// the term, the prepayment penalty is paid at the sale.
+func (roi ReturnOfInvestment) WithPrepayment (prepayment ls.Prepayment) (ReturnOfInvestment, error) {
+    loanSizer, err := roi.loanMetrics.WithPrepayment(prepayment)
+    if err != nil {
+        return roi, fmt.Errorf("WithPrepayment internal error: %v", err)
+    }
+    roi.loanMetrics = loanSizer
+    return roi, nil
+}
+
+// WithGrowthVectors returns a copy of the ReturnOfInvestment with the given
+// growth rate of every year for the revenue, the operating expenses and the
+// capital reserves. The sale uses the NOI of the year after the sale, so a
+// vector needs a rate for every year up to the year of sale. A nil vector
+// keeps the constant rate of the deal. The revenue of a deal with a rent roll
+// and the expenses of a deal with operating expense items do not grow, so
+// they cannot have a revenue or an expenses vector.
+func (roi ReturnOfInvestment) WithGrowthVectors (
+    revenueGrowth []float64,
+    expensesGrowth []float64,
+    reservesGrowth []float64,
+) (
+    ReturnOfInvestment,
+    error,
+) {
+    dealMetrics, err := roi.dealMetrics.WithGrowthVectors(revenueGrowth, expensesGrowth, reservesGrowth)
+    if err != nil {
+        return roi, fmt.Errorf("WithGrowthVectors internal error: %v", err)
+    }
+    for _, vector := range [][]float64{revenueGrowth, expensesGrowth, reservesGrowth} {
+        if vector != nil && len(vector) < roi.saleMetrics.SaleYear {
+            return roi, fmt.Errorf("The growth vectors must have a rate for every year up to the year of sale, %d rates.", roi.saleMetrics.SaleYear)
+        }
+    }
+    if revenueGrowth != nil && roi.rentRoll != nil {
+        return roi, fmt.Errorf("The revenue growth is not used with a rent roll.")
+    }
+    if expensesGrowth != nil && roi.operatingExpenses != nil {
+        return roi, fmt.Errorf("The expense growth is not used with operating expense items.")
+    }
+    roi.dealMetrics = dealMetrics
+    return roi, nil
+}
+
 // Calculation methods
 
 // Internal
@@ -313,16 +519,34 @@ func NewReturnOfInvestment(
 
 // External
 
-// AdquisitionCost returns the AdquisitionCost of Deal
+// AdquisitionCost returns the AdquisitionCost of Deal, including the premium
+// of the rate cap if the loan has one, the money put by the tranches of the
+// capital stack and the part of the loan held back for the capex. The
+// origination fees are charged on the loan and on the draws for the capex.
 func (roi ReturnOfInvestment) AdquisitionCost () (float64, error)  {
     mla, err := roi.loanMetrics.MaximumLoanAmount()
     if err != nil {
         return 0.0, fmt.Errorf("MaximumLoanAmount internal error: %v", err)
     }
+    capex_holdback := roi.CapexHoldback()
+    if capex_holdback > mla {
+        return 0.0, fmt.Errorf("The capex holdback cannot be greater than the loan amount.")
+    }
+    err = roi.future_funding(mla)
+    if err != nil {
+        return 0.0, fmt.Errorf("future_funding internal error: %v", err)
+    }
+    rate_cap_premium, err := roi.loanMetrics.RateCapPremium()
+    if err != nil {
+        return 0.0, fmt.Errorf("RateCapPremium internal error: %v", err)
+    }
     adquisitionCost := - float64(roi.dealMetrics.PurchasePrice) +
     float64(roi.dealMetrics.ClosingAndRenovations) -
-    (roi.loanMetrics.LoanOriginationFees * mla) +
-    mla
+    roi.loan_origination_fees(mla) -
+    rate_cap_premium +
+    mla -
+    capex_holdback +
+    roi.capitalStack.Amount()
     return utils.Round2(adquisitionCost), nil
 }
 
@@ -336,128 +560,80 @@ func (roi ReturnOfInvestment) CashOnCashReturn (net_cash_flow float64)  (float64
     return utils.Round4(math.Abs(net_cash_flow/adq_cost)), nil
 }
 
-// NetCashFlowProjection returns the net cash flow projection of the Deal.
-// Negative values are payments that need to be done, Positive values are money
-// given.
+// NetCashFlowProjection returns the net cash flow projection of the Deal as a
+// slice of maps. Negative values are payments that need to be done, Positive
+// values are money given. The typed form of the projection is returned by
+// Projection.
 func (roi ReturnOfInvestment) NetCashFlowProjection () ([]map[string]interface{}, error) {
-    var net_cash_flow_projection []map[string]interface{}
-
-    adquisition_cost, err := roi.AdquisitionCost()
+    projection, err := roi.Projection()
     if err != nil {
-        return net_cash_flow_projection, fmt.Errorf("AdquisitionCost internal error: %v", err)
+        return nil, fmt.Errorf("Projection internal error: %v", err)
     }
+    return projection.ToMap(), nil
+}
 
-    net_cash_flow_projection = append(
-        net_cash_flow_projection,
-        map[string]interface{} {
-            "net_cash_flow": adquisition_cost,
-        },
-    )
+// ReturnMetrics is a struct that has the return of investment metrics of the
+// deal, derived from the net cash flow projection.
+type ReturnMetrics struct {
+    LeveredIRR              float64
+    EquityMultiple          float64
+    AverageCashOnCash       float64
+    PeakCashOnCash          float64
+    Profit                  float64
+    PaybackYear             int
+}
 
-    revenue := roi.dealMetrics.InitRevenue
-    expense := roi.dealMetrics.InitOperatingExpenses
-    reserve := roi.dealMetrics.InitCapitalReserves
+// ReturnMetrics returns the levered IRR, the equity multiple, the average and
+// peak cash on cash return, the profit and the year on which the invested
+// equity is paid back. If the equity is never paid back, the PaybackYear is 0.
+func (roi ReturnOfInvestment) ReturnMetrics () (ReturnMetrics, error) {
+    projection, err := roi.Projection()
+    if err != nil {
+        return ReturnMetrics{}, fmt.Errorf("Projection internal error: %v", err)
+    }
+    return return_metrics(projection)
+}
 
-    // getting the building value
-    purchase_price := roi.dealMetrics.PurchasePrice
-    building_value := utils.Round2(float64(purchase_price) * (1.0 - roi.taxMetrics.LanBuildingValue))
+// return_metrics returns the ReturnMetrics of a net cash flow projection.
+func return_metrics (projection Projection) (ReturnMetrics, error) {
+    var metrics ReturnMetrics
 
-    // depreciation of the building
-    building_depreciation := utils.Round2(- building_value/float64(roi.taxMetrics.FixDepreciationTimeLine))
+    cash_flows := projection.CashFlows()
 
-    // payment distribution of the loan
-    ppmt, ipmt, err := roi.loanMetrics.PaymentDistribution()
-    if err != nil {
-        return net_cash_flow_projection, fmt.Errorf("PaymentDistribution internal error: %v", err)
-    }
-    // payments of the loan
-    current_pmt, err := roi.loanMetrics.LoanPayment()
+    irr, err := ff.InternalRateOfReturn(cash_flows)
     if err != nil {
-        return net_cash_flow_projection, fmt.Errorf("LoanPayment internal error: %v", err)
+        return metrics, fmt.Errorf("InternalRateOfReturn internal error: %v", err)
     }
-
-    // BalloonPayment at the year of sale
-    balloonpayment, err := roi.loanMetrics.SaleYearBalloonPayment(roi.saleMetrics.SaleYear)
-    if err != nil {
-        return net_cash_flow_projection, fmt.Errorf("BalloonPayment internal error: %v", err)
-    }
-
-    // Iterating over the term and appending the values to the
-    // NetCashFlowProjection slice.
-    for i := 0; i < roi.saleMetrics.SaleYear; i++ {
-        // this year NOI
-        current_noi := utils.Round2(revenue + expense)
-        // this year interest and principal payments
-        current_ppmt := ppmt[i]
-        current_ipmt := ipmt[i]
-        // cashflow after debt service
-        cfads := utils.Round2(current_noi + reserve + current_pmt)
-        // depreciation expense
-        depreciation_expense := 0.0
-        if i < roi.taxMetrics.FixDepreciationTimeLine {
-            depreciation_expense = building_depreciation
+    metrics.LeveredIRR = irr
+
+    equity := - cash_flows[0]
+    distributions := 0.0
+    cumulative := cash_flows[0]
+    for i := 1; i < len(cash_flows); i++ {
+        distributions += cash_flows[i]
+        cumulative += cash_flows[i]
+        if metrics.PaybackYear == 0 && cumulative >= 0 {
+            metrics.PaybackYear = i
         }
-        // income tax
-        income_tax := utils.Round2(- (current_noi + current_ipmt + depreciation_expense) * roi.taxMetrics.IncomeTaxRate)
-        implied_income_tax := utils.Round4(math.Abs(income_tax/cfads))
-        // net cashflow
-        ncf := utils.Round2(cfads + income_tax)
-        // cash on cash return
-        cocr, err := roi.CashOnCashReturn(ncf)
-        if err != nil {
-            return net_cash_flow_projection, fmt.Errorf("CashOnCashReturn internal error: %v", err)
+    }
+    if equity > 0 {
+        metrics.EquityMultiple = utils.Round4(distributions / equity)
+    }
+    metrics.Profit = utils.Round2(distributions - equity)
+
+    // The cash on cash return of every year is calculated before the sale of
+    // the property, so the sale doesn't inflate the average or the peak. The
+    // years with a negative cash flow lower the average.
+    total_cocr := 0.0
+    for _, year := range projection.Years {
+        cocr := projection.cash_on_cash(year)
+        total_cocr += cocr
+        if cocr > metrics.PeakCashOnCash {
+            metrics.PeakCashOnCash = cocr
         }
-
-        net_cash_flow_projection = append(
-            net_cash_flow_projection,
-            map[string]interface{} {
-                "year": i + 1,
-                "revenue": revenue,
-                "expense": expense,
-                "noi": current_noi,
-                "reserve": reserve,
-                "principal_payment": current_ppmt,
-                "interest_payment": current_ipmt,
-                "cashflow_after_debt_service": cfads,
-                "depreciation_expense": depreciation_expense,
-                "income_tax": income_tax,
-                "implied_income_tax": implied_income_tax,
-                "net_cash_flow": ncf,
-                "cash_on_cash_return": cocr,
-            },
-        )
-        revenue = utils.Round2(revenue + revenue * roi.dealMetrics.ProjRevenueGrowth)
-        expense = utils.Round2(expense + expense * roi.dealMetrics.ProjOperatingExpensesGrowth)
-        reserve = utils.Round2(reserve + reserve * roi.dealMetrics.ProjCapitalReservesGrowth)
-    }
-    after_term_noi := utils.Round2(revenue + expense)
-    // Adding the cashflow after the sell of the property
-    // sale with the projected NOI
-    projected_sale_price := roi.saleMetrics.ProjectedSalePrice(after_term_noi)
-    // capital gains tax
-    cg := projected_sale_price -
-        float64(roi.dealMetrics.PurchasePrice) -
-        float64(roi.dealMetrics.ClosingAndRenovations)
-    cg = utils.Round2(cg)
-    cgt := cg * roi.taxMetrics.CapitalGainsTaxRate
-    // Depreciation Recapture tax
-    drt := building_depreciation *
-        float64(roi.saleMetrics.SaleYear) *
-        roi.taxMetrics.DepreciationRecaptureTaxRate
-    drt = utils.Round2(drt)
-    // Sale calculations
-    sale := net_cash_flow_projection[roi.loanMetrics.Term - 1]
-    sale_net_cash_flow := sale["net_cash_flow"].(float64)
-    sale_net_cash_flow = sale_net_cash_flow +
-        projected_sale_price +
-        drt +
-        cgt +
-        balloonpayment
-    sale_net_cash_flow = utils.Round2(sale_net_cash_flow)
-    sale["net_cash_flow"] = sale_net_cash_flow
-    sale["sale_price"] = projected_sale_price
-    sale["depreciation_recapture_tax"] = drt
-    sale["capital_gains_tax"] = cgt
-    // Setting the value
-    return net_cash_flow_projection, nil
+    }
+    if len(projection.Years) > 0 {
+        metrics.AverageCashOnCash = utils.Round4(total_cocr / float64(len(projection.Years)))
+    }
+    return metrics, nil
 }
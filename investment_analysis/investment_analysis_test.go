@@ -1,7 +1,8 @@
 package investment_analysis
 import (
     "testing";
-    // utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+    ls "github.com/jacobitosuperstar/go-cre-loan-calculations/loan_sizer";
 )
 
 type TestROI struct {
@@ -117,7 +118,8 @@ func TestROIMethods(t *testing.T) {
                   "implied_income_tax": 0.1847,
                   "income_tax": -53994.54,
                   "interest_payment": -177836.38,
-                  "net_cash_flow": 238373.46,
+                  // the operating 238373.46 plus the sale of the property.
+                  "net_cash_flow": 4443859.27,
                   "noi": 562333.04,
                   "principal_payment": -101495.14,
                   "reserve": 9366.48,
@@ -194,3 +196,283 @@ func TestROIMethods(t *testing.T) {
       }
     }
 }
+
+// baseTestROI is the deal used across the tests of the package.
+var baseTestROI = TestROI{
+    // DealInformation
+    purchasePrice: 6500000,
+    closingAndRenovations: -225000,
+    goinginCapRate: 0.0596,
+    initialRevenue: 687500,
+    initialExpenses: -300000,
+    initialCapitalReserves: 7500,
+    projectedRevenueGrowth: 0.0350,
+    projectedExpensesGrowth: 0.0250,
+    projectedCapitalReservesGrowth: 0.0250,
+    // LoanSizer
+    maxLTV: 0.70,
+    minDSCR: 1.25,
+    amortization: 30,
+    term: 10,
+    interestRate: 0.045,
+    ioPeriod: 2,
+    requestedLoanAmount: 6500000,
+    loanOriginationFees: 0.01,
+    // TaxAssumptions
+    lanBuildingValue: 0.30,
+    fixDepreciationTimeLine: 27,
+    incomeTaxRate: 0.25,
+    capitalGainsTaxRate: 0.15,
+    depreciationRecaptureTaxRate: 0.25,
+    // SaleTerms
+    exitCapRate: 0.0650,
+    costOfSale: 0.0250,
+    saleYear: 10,
+}
+
+// newTestROI creates a ReturnOfInvestment struct from the TestROI values.
+func newTestROI(input TestROI) (ReturnOfInvestment, error) {
+    return NewReturnOfInvestment(
+        // DealInformation
+        input.purchasePrice,
+        input.closingAndRenovations,
+        input.goinginCapRate,
+        input.initialRevenue,
+        input.initialExpenses,
+        input.initialCapitalReserves,
+        input.projectedRevenueGrowth,
+        input.projectedExpensesGrowth,
+        input.projectedCapitalReservesGrowth,
+        // LoanSizer
+        input.maxLTV,
+        input.minDSCR,
+        input.amortization,
+        input.term,
+        input.interestRate,
+        input.ioPeriod,
+        input.requestedLoanAmount,
+        input.loanOriginationFees,
+        // TaxAssumptions
+        input.lanBuildingValue,
+        input.fixDepreciationTimeLine,
+        input.incomeTaxRate,
+        input.capitalGainsTaxRate,
+        input.depreciationRecaptureTaxRate,
+        // SaleTerms
+        input.exitCapRate,
+        input.costOfSale,
+        input.saleYear,
+    )
+}
+
+func TestReturnMetrics(t *testing.T) {
+    roi, err := newTestROI(baseTestROI)
+    if err != nil {
+        t.Errorf("ReturnOfInvestment internal error: %v", err)
+        return
+    }
+    got, err := roi.ReturnMetrics()
+    if err != nil {
+        t.Errorf("ReturnMetrics internal error: %v", err)
+        return
+    }
+    want := ReturnMetrics{
+        LeveredIRR: 0.1223,
+        EquityMultiple: 2.6714,
+        AverageCashOnCash: 0.0778,
+        PeakCashOnCash: 0.1074,
+        Profit: 3711375.11,
+        PaybackYear: 10,
+    }
+    if got != want {
+        t.Errorf("ReturnMetrics got: %+v, wanted: %+v", got, want)
+    }
+}
+
+func TestReturnMetricsNegativeYear(t *testing.T) {
+    // the year 2 has a negative cash flow, a CashOnCashReturn of 0.2 without
+    // its sign.
+    projection := Projection{
+        Acquisition: AcquisitionRow{NetCashFlow: -1000000},
+        Years: []CashFlowYear{
+            {Year: 1, NetCashFlow: 100000, CashOnCashReturn: 0.1},
+            {Year: 2, NetCashFlow: -200000, CashOnCashReturn: 0.2},
+            {Year: 3, NetCashFlow: 150000, CashOnCashReturn: 0.15},
+        },
+        Sale: SaleEvent{Year: 3, NetCashFlow: 1200000},
+    }
+    got, err := return_metrics(projection)
+    if err != nil {
+        t.Errorf("return_metrics internal error: %v", err)
+        return
+    }
+    // (0.1 - 0.2 + 0.15) / 3, and the peak of the years with a positive
+    // cash flow.
+    if got.AverageCashOnCash != 0.0167 || got.PeakCashOnCash != 0.15 {
+        t.Errorf("AverageCashOnCash and PeakCashOnCash got: %v %v, wanted: %v %v", got.AverageCashOnCash, got.PeakCashOnCash, 0.0167, 0.15)
+    }
+}
+
+func TestMaxLTC(t *testing.T) {
+    roi, err := newTestROI(baseTestROI)
+    if err != nil {
+        t.Errorf("ReturnOfInvestment internal error: %v", err)
+        return
+    }
+    roi, err = roi.WithMaxLTC(0.60)
+    if err != nil {
+        t.Errorf("WithMaxLTC internal error: %v", err)
+        return
+    }
+    // 0.60 * (6500000 + 225000)
+    mla, err := roi.loanMetrics.MaximumLoanAmount()
+    if err != nil || mla != 4035000 {
+        t.Errorf("MaximumLoanAmount got: %g, wanted: %g, error: %v", mla, 4035000.0, err)
+    }
+    constraint, err := roi.loanMetrics.BindingConstraint()
+    if err != nil || constraint != ls.LTCConstraint {
+        t.Errorf("BindingConstraint got: %v, wanted: %v, error: %v", constraint, ls.LTCConstraint, err)
+    }
+
+    // the project cost follows the purchase price.
+    candidate, err := roi.with_purchase_price(7000000)
+    if err != nil {
+        t.Errorf("with_purchase_price internal error: %v", err)
+        return
+    }
+    mla, err = candidate.loanMetrics.MaximumLoanAmount()
+    if err != nil || mla != 4335000 {
+        t.Errorf("MaximumLoanAmount got: %g, wanted: %g, error: %v", mla, 4335000.0, err)
+    }
+
+    if _, err := roi.WithMaxLTC(-0.1); err == nil {
+        t.Errorf("expected an error for a negative loan to cost")
+    }
+}
+
+func TestGrowthVectors(t *testing.T) {
+    roi, err := newTestROI(baseTestROI)
+    if err != nil {
+        t.Errorf("ReturnOfInvestment internal error: %v", err)
+        return
+    }
+    // lease up on the first years, a recession on the years 4 and 5 and the
+    // constant rate of the deal after it.
+    lease_up := []float64{0.10, 0.08, 0.05, -0.03, -0.02, 0.03, 0.035, 0.035, 0.035, 0.035}
+    declining := baseTestROI
+    declining.projectedRevenueGrowth = -0.01
+
+    var testCases = []struct {
+        name string
+        input TestROI
+        revenueGrowth []float64
+        expensesGrowth []float64
+        wantYears map[int]float64
+        wantSalePrice float64
+    }{
+        {"Constant rates", baseTestROI, nil, nil, map[int]float64{2: 124719.98, 10: 4443859.27}, 8786419.35},
+        {"Vector of the constant rates", baseTestROI, []float64{0.035, 0.035, 0.035, 0.035, 0.035, 0.035, 0.035, 0.035, 0.035, 0.035}, []float64{0.025, 0.025, 0.025, 0.025, 0.025, 0.025, 0.025, 0.025, 0.025, 0.025}, map[int]float64{2: 124719.98, 10: 4443859.27}, 8786419.35},
+        {"Lease up and recession", baseTestROI, lease_up, nil, map[int]float64{2: 158235.61, 5: 196085.38, 10: 4359841.14}, 8692890.15},
+        // the sale doesn't pay off the loan, the net cash flow of the year
+        // of sale is negative.
+        {"Negative constant growth", declining, nil, nil, map[int]float64{2: 101516.86, 10: -277703.01}, 3566059.5},
+    }
+    for _, test := range testCases {
+        t.Run(test.name, func(t *testing.T) {
+            roi, err := newTestROI(test.input)
+            if err != nil {
+                t.Errorf("ReturnOfInvestment internal error: %v", err)
+                return
+            }
+            roi, err = roi.WithGrowthVectors(test.revenueGrowth, test.expensesGrowth, nil)
+            if err != nil {
+                t.Errorf("WithGrowthVectors internal error: %v", err)
+                return
+            }
+            projection, err := roi.Projection()
+            if err != nil {
+                t.Errorf("Projection internal error: %v", err)
+                return
+            }
+            cash_flows := projection.CashFlows()
+            for year, want := range test.wantYears {
+                if !utils.Tolerance(cash_flows[year], want, 0.1) {
+                    t.Errorf("Year %v net_cash_flow got: %v, wanted: %v", year, cash_flows[year], want)
+                }
+            }
+            if !utils.Tolerance(projection.Sale.SalePrice, test.wantSalePrice, 0.1) {
+                t.Errorf("SalePrice got: %v, wanted: %v", projection.Sale.SalePrice, test.wantSalePrice)
+            }
+        })
+    }
+
+    t.Run("Constant rate past the vector", func(t *testing.T) {
+        vectors, err := roi.WithGrowthVectors(lease_up, nil, nil)
+        if err != nil {
+            t.Errorf("WithGrowthVectors internal error: %v", err)
+            return
+        }
+        revenue, expenses, reserves := vectors.dealMetrics.growth(3)
+        if revenue != -0.03 || expenses != 0.025 || reserves != 0.025 {
+            t.Errorf("growth of the year 4 got: %v %v %v, wanted: %v %v %v", revenue, expenses, reserves, -0.03, 0.025, 0.025)
+        }
+        revenue, _, _ = vectors.dealMetrics.growth(11)
+        if revenue != 0.035 {
+            t.Errorf("growth of the year 12 got: %v, wanted: %v", revenue, 0.035)
+        }
+        // the vectors are kept when the purchase price changes.
+        candidate, err := vectors.with_purchase_price(6000000)
+        if err != nil {
+            t.Errorf("with_purchase_price internal error: %v", err)
+            return
+        }
+        if len(candidate.dealMetrics.RevenueGrowth) != len(lease_up) {
+            t.Errorf("RevenueGrowth got: %v, wanted: %v", candidate.dealMetrics.RevenueGrowth, lease_up)
+        }
+    })
+
+    var errorCases = []struct {
+        name string
+        revenueGrowth []float64
+        reservesGrowth []float64
+    }{
+        {"Shorter than the year of sale", lease_up[:9], nil},
+        {"Empty vector", []float64{}, nil},
+        {"Rate of -100%", nil, []float64{0.025, -1, 0.025, 0.025, 0.025, 0.025, 0.025, 0.025, 0.025, 0.025}},
+    }
+    for _, test := range errorCases {
+        t.Run(test.name, func(t *testing.T) {
+            if _, err := roi.WithGrowthVectors(test.revenueGrowth, nil, test.reservesGrowth); err == nil {
+                t.Errorf("WithGrowthVectors got no error, wanted an error")
+            }
+        })
+    }
+
+    t.Run("Constant rate of -100%", func(t *testing.T) {
+        input := baseTestROI
+        input.projectedExpensesGrowth = -1
+        if _, err := newTestROI(input); err == nil {
+            t.Errorf("ReturnOfInvestment got no error, wanted an error")
+        }
+    })
+}
+
+func TestProjectCost(t *testing.T) {
+    roi, err := newTestROI(baseTestROI)
+    if err != nil {
+        t.Errorf("ReturnOfInvestment internal error: %v", err)
+        return
+    }
+    // the closing and renovations are a cost added to the purchase price.
+    if roi.dealMetrics.ProjectCost() != 6725000 {
+        t.Errorf("ProjectCost got: %v, wanted: %v", roi.dealMetrics.ProjectCost(), 6725000)
+    }
+
+    t.Run("Positive closing and renovations", func(t *testing.T) {
+        input := baseTestROI
+        input.closingAndRenovations = 225000
+        if _, err := newTestROI(input); err == nil {
+            t.Errorf("ReturnOfInvestment got no error, wanted an error")
+        }
+    })
+}
@@ -0,0 +1,479 @@
+// Monte Carlo simulation of the deal. The revenue growth, the expenses growth
+// and the exit cap rate are drawn from distributions, possibly correlated,
+// and the projection is run for every draw to get the distribution of the
+// returns of the deal.
+
+package investment_analysis
+
+import (
+    "fmt";
+    "math";
+    "math/rand/v2";
+    "runtime";
+    "sort";
+    "sync";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+    ff "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/financial_formulas";
+)
+
+// DistributionType is the kind of a Distribution.
+type DistributionType string
+
+const (
+    NormalDistribution      DistributionType = "normal"
+    TriangularDistribution  DistributionType = "triangular"
+    UniformDistribution     DistributionType = "uniform"
+)
+
+// Distribution is the distribution of an input of the Monte Carlo
+// simulation. A normal distribution uses the Mean and the StdDev, a
+// triangular distribution the Min, Mode and Max, and a uniform distribution
+// the Min and Max.
+type Distribution struct {
+    Type        DistributionType
+    Mean        float64
+    StdDev      float64
+    Min         float64
+    Mode        float64
+    Max         float64
+}
+
+// NewNormalDistribution returns a normal Distribution if the values given are
+// valid. If not, returns a default struct with the error.
+func NewNormalDistribution(mean float64, stdDev float64) (Distribution, error) {
+    if stdDev < 0 {
+        return Distribution{}, fmt.Errorf("The stdDev of the distribution cannot be lower than 0.")
+    }
+    return Distribution{Type: NormalDistribution, Mean: mean, StdDev: stdDev}, nil
+}
+
+// NewTriangularDistribution returns a triangular Distribution if the values
+// given are valid. If not, returns a default struct with the error.
+func NewTriangularDistribution(min float64, mode float64, max float64) (Distribution, error) {
+    if min >= max {
+        return Distribution{}, fmt.Errorf("The min of the distribution must be lower than the max.")
+    }
+    if mode < min || mode > max {
+        return Distribution{}, fmt.Errorf("The mode of the distribution must be between the min and the max.")
+    }
+    return Distribution{Type: TriangularDistribution, Min: min, Mode: mode, Max: max}, nil
+}
+
+// NewUniformDistribution returns a uniform Distribution if the values given
+// are valid. If not, returns a default struct with the error.
+func NewUniformDistribution(min float64, max float64) (Distribution, error) {
+    if min >= max {
+        return Distribution{}, fmt.Errorf("The min of the distribution must be lower than the max.")
+    }
+    return Distribution{Type: UniformDistribution, Min: min, Max: max}, nil
+}
+
+// validated returns the Distribution checked by its constructor.
+func (d Distribution) validated () (Distribution, error) {
+    switch d.Type {
+    case NormalDistribution:
+        return NewNormalDistribution(d.Mean, d.StdDev)
+    case TriangularDistribution:
+        return NewTriangularDistribution(d.Min, d.Mode, d.Max)
+    case UniformDistribution:
+        return NewUniformDistribution(d.Min, d.Max)
+    }
+    return Distribution{}, fmt.Errorf("The type of the distribution must be NormalDistribution, TriangularDistribution or UniformDistribution.")
+}
+
+// quantile returns the value of the distribution at the same probability as
+// the given draw of a standard normal, so correlated standard normals give
+// correlated values of the distribution.
+func (d Distribution) quantile (z float64) float64 {
+    if d.Type == NormalDistribution {
+        return d.Mean + d.StdDev * z
+    }
+    u := 0.5 * (1 + math.Erf(z / math.Sqrt2))
+    if d.Type == UniformDistribution {
+        return d.Min + u * (d.Max - d.Min)
+    }
+    width := d.Max - d.Min
+    if u < (d.Mode - d.Min) / width {
+        return d.Min + math.Sqrt(u * width * (d.Mode - d.Min))
+    }
+    return d.Max - math.Sqrt((1 - u) * width * (d.Max - d.Mode))
+}
+
+// monteCarloInputs is the number of inputs drawn on every simulation, in the
+// order of the correlation matrix: revenue growth, expenses growth and exit
+// cap rate.
+const monteCarloInputs = 3
+
+// MonteCarlo is a struct with the setup of the Monte Carlo simulation.
+//
+// The RevenueGrowth, ExpensesGrowth and ExitCapRate are the distributions of
+// those inputs of the deal, a nil distribution keeps the value of the deal.
+// A growth rate drawn is used on every year of the projection.
+// The Correlation is the correlation matrix of the inputs, in that order, a
+// nil matrix means that the inputs are independent. The draws of every
+// simulation only depend on the Seed and the number of the simulation, so the
+// result is the same for any number of Workers, a zero number of workers uses
+// one worker per CPU. The CovenantDSCR is the DSCR below which the covenant of
+// the loan is breached, a zero value uses the MinDSCR of the loan.
+type MonteCarlo struct {
+    RevenueGrowth       *Distribution
+    ExpensesGrowth      *Distribution
+    ExitCapRate         *Distribution
+    Correlation         [][]float64
+    Simulations         int
+    Seed                uint64
+    Workers             int
+    CovenantDSCR        float64
+}
+
+// NewMonteCarlo returns a MonteCarlo struct if the values given are valid. If
+// not, returns a default struct with the error.
+func NewMonteCarlo(
+    revenueGrowth *Distribution,
+    expensesGrowth *Distribution,
+    exitCapRate *Distribution,
+    correlation [][]float64,
+    simulations int,
+    seed uint64,
+    workers int,
+    covenantDSCR float64,
+) (
+    MonteCarlo,
+    error,
+) {
+    // Data Validation
+    distributions := []*Distribution{revenueGrowth, expensesGrowth, exitCapRate}
+    for i, d := range distributions {
+        if d == nil {
+            continue
+        }
+        validated, err := d.validated()
+        if err != nil {
+            return MonteCarlo{}, fmt.Errorf("Distribution internal error: %v", err)
+        }
+        distributions[i] = &validated
+    }
+    if correlation != nil {
+        if _, err := cholesky(correlation); err != nil {
+            return MonteCarlo{}, fmt.Errorf("cholesky internal error: %v", err)
+        }
+    }
+    if simulations <= 0 {
+        return MonteCarlo{}, fmt.Errorf("The number of simulations must be greater than 0.")
+    }
+    if workers < 0 {
+        return MonteCarlo{}, fmt.Errorf("The number of workers cannot be lower than 0.")
+    }
+    if covenantDSCR < 0 {
+        return MonteCarlo{}, fmt.Errorf("The covenantDSCR cannot be lower than 0.")
+    }
+    // Struct Creation
+    mc := MonteCarlo{
+        RevenueGrowth: distributions[0],
+        ExpensesGrowth: distributions[1],
+        ExitCapRate: distributions[2],
+        Correlation: correlation,
+        Simulations: simulations,
+        Seed: seed,
+        Workers: workers,
+        CovenantDSCR: covenantDSCR,
+    }
+    return mc, nil
+}
+
+// cholesky returns the lower triangular matrix L of the correlation matrix,
+// with L times its transpose equal to the matrix. Perfectly correlated
+// inputs are allowed.
+func cholesky (correlation [][]float64) ([][]float64, error) {
+    const tolerance = 1e-9
+
+    if len(correlation) != monteCarloInputs {
+        return nil, fmt.Errorf("The correlation matrix must be %dx%d.", monteCarloInputs, monteCarloInputs)
+    }
+    for i := range correlation {
+        if len(correlation[i]) != monteCarloInputs {
+            return nil, fmt.Errorf("The correlation matrix must be %dx%d.", monteCarloInputs, monteCarloInputs)
+        }
+        if correlation[i][i] != 1 {
+            return nil, fmt.Errorf("The diagonal of the correlation matrix must be 1.")
+        }
+        for j := range correlation[i] {
+            if correlation[i][j] < -1 || correlation[i][j] > 1 {
+                return nil, fmt.Errorf("The correlations must be between -1 and 1.")
+            }
+            if correlation[i][j] != correlation[j][i] {
+                return nil, fmt.Errorf("The correlation matrix must be symmetric.")
+            }
+        }
+    }
+
+    lower := make([][]float64, monteCarloInputs)
+    for i := range lower {
+        lower[i] = make([]float64, monteCarloInputs)
+    }
+    for j := 0; j < monteCarloInputs; j++ {
+        pivot := correlation[j][j]
+        for k := 0; k < j; k++ {
+            pivot -= lower[j][k] * lower[j][k]
+        }
+        if pivot < -tolerance {
+            return nil, fmt.Errorf("The correlation matrix must be positive semi-definite.")
+        }
+        pivot = math.Sqrt(math.Max(pivot, 0))
+        lower[j][j] = pivot
+        for i := j + 1; i < monteCarloInputs; i++ {
+            value := correlation[i][j]
+            for k := 0; k < j; k++ {
+                value -= lower[i][k] * lower[j][k]
+            }
+            // on a zero pivot the input is a combination of the previous
+            // ones, and what is left of the column must be zero too.
+            if pivot <= tolerance {
+                if math.Abs(value) > tolerance {
+                    return nil, fmt.Errorf("The correlation matrix must be positive semi-definite.")
+                }
+                continue
+            }
+            lower[i][j] = value / pivot
+        }
+    }
+    return lower, nil
+}
+
+// Percentiles is the distribution of a metric over the simulations.
+type Percentiles struct {
+    Mean    float64
+    P5      float64
+    P25     float64
+    P50     float64
+    P75     float64
+    P95     float64
+}
+
+// percentiles returns the Percentiles of the values, interpolated between
+// the closest values.
+func percentiles (values []float64) Percentiles {
+    if len(values) == 0 {
+        return Percentiles{}
+    }
+    sorted := append([]float64(nil), values...)
+    sort.Float64s(sorted)
+    at := func (p float64) float64 {
+        position := p * float64(len(sorted) - 1)
+        low := int(math.Floor(position))
+        high := int(math.Ceil(position))
+        return utils.Round4(sorted[low] + (position - float64(low)) * (sorted[high] - sorted[low]))
+    }
+    total := 0.0
+    for _, value := range sorted {
+        total += value
+    }
+    return Percentiles{
+        Mean: utils.Round4(total / float64(len(sorted))),
+        P5: at(0.05),
+        P25: at(0.25),
+        P50: at(0.50),
+        P75: at(0.75),
+        P95: at(0.95),
+    }
+}
+
+// MonteCarloResult is the outcome of the Monte Carlo simulation.
+//
+// The Failed simulations are the ones whose draws are not valid inputs of the
+// deal, as an exit cap rate out of the 0 to 1 range or a growth rate of -1 or
+// lower, they are left out of the results. The simulations without an IRR, as
+// a total loss, are counted on the UndefinedIRR and left out of the
+// LeveredIRR percentiles only. The probabilities are over the simulations
+// that did not fail. The covenant is breached on a year whose NOI over its
+// annual debt service, without the payoff of the loan, is below the
+// CovenantDSCR.
+type MonteCarloResult struct {
+    Simulations                 int
+    Failed                      int
+    UndefinedIRR                int
+    LeveredIRR                  Percentiles
+    EquityMultiple              Percentiles
+    ProbabilityOfLoss           float64
+    ProbabilityOfCovenantBreach float64
+}
+
+// simulation is the outcome of a single simulation.
+type simulation struct {
+    failed              bool
+    irr_defined         bool
+    irr                 float64
+    equity_multiple     float64
+    loss                bool
+    breach              bool
+}
+
+// draw returns the inputs of the given simulation, the revenue growth, the
+// expenses growth and the exit cap rate, in that order.
+func (mc MonteCarlo) draw (roi ReturnOfInvestment, lower [][]float64, number int) [monteCarloInputs]float64 {
+    rng := rand.New(rand.NewPCG(mc.Seed, uint64(number)))
+    var z [monteCarloInputs]float64
+    for i := range z {
+        z[i] = rng.NormFloat64()
+    }
+    if lower != nil {
+        var correlated [monteCarloInputs]float64
+        for i := range correlated {
+            for k := 0; k <= i; k++ {
+                correlated[i] += lower[i][k] * z[k]
+            }
+        }
+        z = correlated
+    }
+
+    inputs := [monteCarloInputs]float64{
+        roi.dealMetrics.ProjRevenueGrowth,
+        roi.dealMetrics.ProjOperatingExpensesGrowth,
+        roi.saleMetrics.ExitCapRate,
+    }
+    for i, d := range []*Distribution{mc.RevenueGrowth, mc.ExpensesGrowth, mc.ExitCapRate} {
+        if d != nil {
+            inputs[i] = d.quantile(z[i])
+        }
+    }
+    return inputs
+}
+
+// simulate runs the projection of the deal with the inputs of the given
+// simulation. A growth rate drawn is the rate of every year, it replaces the
+// growth vector of the deal, and it can be negative.
+func (mc MonteCarlo) simulate (roi ReturnOfInvestment, lower [][]float64, covenant float64, number int) simulation {
+    inputs := mc.draw(roi, lower, number)
+    if inputs[0] <= -1 || inputs[1] <= -1 || inputs[2] <= 0 || inputs[2] > 1 {
+        return simulation{failed: true}
+    }
+    if mc.RevenueGrowth != nil {
+        roi.dealMetrics.ProjRevenueGrowth = inputs[0]
+        roi.dealMetrics.RevenueGrowth = nil
+    }
+    if mc.ExpensesGrowth != nil {
+        roi.dealMetrics.ProjOperatingExpensesGrowth = inputs[1]
+        roi.dealMetrics.OperatingExpensesGrowth = nil
+    }
+    saleTerms, err := NewSaleTerms(inputs[2], roi.saleMetrics.CostOfSale, roi.saleMetrics.SaleYear)
+    if err != nil {
+        return simulation{failed: true}
+    }
+    roi.saleMetrics = saleTerms
+
+    projection, err := roi.Projection()
+    if err != nil {
+        return simulation{failed: true}
+    }
+    cash_flows := projection.CashFlows()
+    equity := - cash_flows[0]
+    distributions := 0.0
+    for _, cash_flow := range cash_flows[1:] {
+        distributions += cash_flow
+    }
+
+    // a deal without debt service never breaches the covenant.
+    dscr, has_debt := minimum_dscr(projection)
+    result := simulation{
+        loss: distributions < equity,
+        breach: has_debt && dscr < covenant,
+    }
+    if equity > 0 {
+        result.equity_multiple = utils.Round4(distributions / equity)
+    }
+    irr, err := ff.InternalRateOfReturn(cash_flows)
+    if err == nil {
+        result.irr_defined = true
+        result.irr = irr
+    }
+    return result
+}
+
+// MonteCarlo returns the MonteCarloResult of the deal over the simulations
+// of the given MonteCarlo, run in parallel.
+func (roi ReturnOfInvestment) MonteCarlo (mc MonteCarlo) (MonteCarloResult, error) {
+    result := MonteCarloResult{Simulations: mc.Simulations}
+
+    validated, err := NewMonteCarlo(
+        mc.RevenueGrowth,
+        mc.ExpensesGrowth,
+        mc.ExitCapRate,
+        mc.Correlation,
+        mc.Simulations,
+        mc.Seed,
+        mc.Workers,
+        mc.CovenantDSCR,
+    )
+    if err != nil {
+        return result, fmt.Errorf("NewMonteCarlo internal error: %v", err)
+    }
+    if validated.RevenueGrowth != nil && roi.rentRoll != nil {
+        return result, fmt.Errorf("The revenue growth is not used with a rent roll.")
+    }
+    if validated.ExpensesGrowth != nil && roi.operatingExpenses != nil {
+        return result, fmt.Errorf("The expense growth is not used with operating expense items.")
+    }
+    var lower [][]float64
+    if validated.Correlation != nil {
+        lower, err = cholesky(validated.Correlation)
+        if err != nil {
+            return result, fmt.Errorf("cholesky internal error: %v", err)
+        }
+    }
+    covenant := validated.CovenantDSCR
+    if covenant == 0 {
+        covenant = roi.loanMetrics.MinDSCR
+    }
+    workers := validated.Workers
+    if workers == 0 {
+        workers = runtime.NumCPU()
+    }
+
+    // every worker writes only to the simulations it takes from the jobs
+    // channel.
+    simulations := make([]simulation, validated.Simulations)
+    jobs := make(chan int)
+    var wg sync.WaitGroup
+    for w := 0; w < workers; w++ {
+        wg.Add(1)
+        go func () {
+            defer wg.Done()
+            for number := range jobs {
+                simulations[number] = validated.simulate(roi, lower, covenant, number)
+            }
+        }()
+    }
+    for number := range simulations {
+        jobs <- number
+    }
+    close(jobs)
+    wg.Wait()
+
+    var irrs, equity_multiples []float64
+    losses, breaches := 0, 0
+    for _, s := range simulations {
+        if s.failed {
+            result.Failed++
+            continue
+        }
+        if s.irr_defined {
+            irrs = append(irrs, s.irr)
+        } else {
+            result.UndefinedIRR++
+        }
+        equity_multiples = append(equity_multiples, s.equity_multiple)
+        if s.loss {
+            losses++
+        }
+        if s.breach {
+            breaches++
+        }
+    }
+    result.LeveredIRR = percentiles(irrs)
+    result.EquityMultiple = percentiles(equity_multiples)
+    if evaluated := len(equity_multiples); evaluated > 0 {
+        result.ProbabilityOfLoss = utils.Round4(float64(losses) / float64(evaluated))
+        result.ProbabilityOfCovenantBreach = utils.Round4(float64(breaches) / float64(evaluated))
+    }
+    return result, nil
+}
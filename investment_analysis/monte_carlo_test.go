@@ -0,0 +1,223 @@
+package investment_analysis
+import (
+    "testing";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+)
+
+func TestDistributionQuantile(t *testing.T) {
+    var testCases = []struct {
+        name string
+        distribution Distribution
+        z float64
+        want float64
+    }{
+        {"Normal mean", Distribution{Type: NormalDistribution, Mean: 0.035, StdDev: 0.01}, 0, 0.035},
+        {"Normal one deviation", Distribution{Type: NormalDistribution, Mean: 0.035, StdDev: 0.01}, 1, 0.045},
+        {"Uniform median", Distribution{Type: UniformDistribution, Min: 0.06, Max: 0.07}, 0, 0.065},
+        {"Uniform upper tail", Distribution{Type: UniformDistribution, Min: 0, Max: 1}, 1.6449, 0.95},
+        {"Triangular symmetric median", Distribution{Type: TriangularDistribution, Min: 0, Mode: 0.5, Max: 1}, 0, 0.5},
+        // half of the probability is below 1 - sqrt(0.5) on a triangle with
+        // the mode at the min.
+        {"Triangular skewed median", Distribution{Type: TriangularDistribution, Min: 0, Mode: 0, Max: 1}, 0, 0.2929},
+    }
+    for _, test := range testCases {
+        t.Run(test.name, func(t *testing.T) {
+            got := test.distribution.quantile(test.z)
+            if !utils.Tolerance(got, test.want, 0.0001) {
+                t.Errorf("quantile got: %v, wanted: %v", got, test.want)
+            }
+        })
+    }
+}
+
+func TestMonteCarlo(t *testing.T) {
+    roi, err := newTestROI(baseTestROI)
+    if err != nil {
+        t.Errorf("ReturnOfInvestment internal error: %v", err)
+        return
+    }
+    fixed_growth, _ := NewNormalDistribution(0.035, 0)
+    revenue_growth, _ := NewNormalDistribution(0.035, 0.01)
+    exit_cap_rate, _ := NewNormalDistribution(0.065, 0.005)
+    uniform_cap_rate, _ := NewUniformDistribution(0.06, 0.07)
+
+    t.Run("Without uncertainty", func(t *testing.T) {
+        got, err := roi.MonteCarlo(MonteCarlo{RevenueGrowth: &fixed_growth, Simulations: 50, Seed: 1})
+        if err != nil {
+            t.Errorf("MonteCarlo internal error: %v", err)
+            return
+        }
+        // same metrics as TestReturnMetrics on every percentile.
+        for _, value := range []float64{got.LeveredIRR.P5, got.LeveredIRR.P50, got.LeveredIRR.P95} {
+            if !utils.Tolerance(value, 0.1223, 0.0001) {
+                t.Errorf("LeveredIRR got: %+v, wanted: %v", got.LeveredIRR, 0.1223)
+            }
+        }
+        if !utils.Tolerance(got.EquityMultiple.Mean, 2.6714, 0.0001) {
+            t.Errorf("EquityMultiple got: %+v, wanted: %v", got.EquityMultiple, 2.6714)
+        }
+        if got.ProbabilityOfLoss != 0 || got.ProbabilityOfCovenantBreach != 0 {
+            t.Errorf("Probabilities got: %v %v, wanted: 0 0", got.ProbabilityOfLoss, got.ProbabilityOfCovenantBreach)
+        }
+    })
+
+    t.Run("Covenant breach", func(t *testing.T) {
+        // the lowest DSCR of the deal is 1.3872, on the year 1 of the IO
+        // period, the fixed rate loan charges the amortizing payment on it.
+        late_sale, err := roi.WithHoldPastMaturity().with_sale_year(12)
+        if err != nil {
+            t.Errorf("with_sale_year internal error: %v", err)
+            return
+        }
+        input := baseTestROI
+        input.maxLTV = 0
+        all_equity, err := newTestROI(input)
+        if err != nil {
+            t.Errorf("ReturnOfInvestment internal error: %v", err)
+            return
+        }
+        var testCases = []struct {
+            name string
+            deal ReturnOfInvestment
+            covenant float64
+            want float64
+        }{
+            {"Covenant above the IO years", roi, 1.40, 1},
+            {"Covenant below the IO years", roi, 1.38, 0},
+            // the payoff of the loan at its maturity is not debt service.
+            {"Sale after the maturity of the loan", late_sale, 1.38, 0},
+            // without a loan there is no debt service to breach the
+            // covenant.
+            {"All equity", all_equity, 1.40, 0},
+        }
+        for _, test := range testCases {
+            got, err := test.deal.MonteCarlo(MonteCarlo{Simulations: 10, CovenantDSCR: test.covenant})
+            if err != nil {
+                t.Errorf("MonteCarlo internal error: %v", err)
+                return
+            }
+            if got.Failed != 0 || got.ProbabilityOfCovenantBreach != test.want {
+                t.Errorf("%v Failed and ProbabilityOfCovenantBreach got: %v %v, wanted: 0 %v", test.name, got.Failed, got.ProbabilityOfCovenantBreach, test.want)
+            }
+        }
+    })
+
+    t.Run("Uniform exit cap rate", func(t *testing.T) {
+        got, err := roi.MonteCarlo(MonteCarlo{ExitCapRate: &uniform_cap_rate, Simulations: 1000, Seed: 42})
+        if err != nil {
+            t.Errorf("MonteCarlo internal error: %v", err)
+            return
+        }
+        // the median exit cap rate is the one of the deal.
+        if !utils.Tolerance(got.LeveredIRR.P50, 0.1223, 0.002) {
+            t.Errorf("LeveredIRR P50 got: %v, wanted: %v", got.LeveredIRR.P50, 0.1223)
+        }
+        irr := got.LeveredIRR
+        if !(irr.P5 < irr.P25 && irr.P25 < irr.P50 && irr.P50 < irr.P75 && irr.P75 < irr.P95) {
+            t.Errorf("LeveredIRR got: %+v, wanted increasing percentiles", irr)
+        }
+        // the IRR at the exit cap rates of 0.07 and 0.06.
+        if irr.P5 < 0.1113 || irr.P95 > 0.1340 {
+            t.Errorf("LeveredIRR got: %+v, wanted between %v and %v", irr, 0.1113, 0.1340)
+        }
+    })
+
+    t.Run("Deterministic for any number of workers", func(t *testing.T) {
+        mc := MonteCarlo{RevenueGrowth: &revenue_growth, ExitCapRate: &exit_cap_rate, Simulations: 300, Seed: 7, Workers: 1}
+        serial, err := roi.MonteCarlo(mc)
+        if err != nil {
+            t.Errorf("MonteCarlo internal error: %v", err)
+            return
+        }
+        mc.Workers = 8
+        parallel, err := roi.MonteCarlo(mc)
+        if err != nil {
+            t.Errorf("MonteCarlo internal error: %v", err)
+            return
+        }
+        if serial != parallel {
+            t.Errorf("MonteCarlo got: %+v, wanted: %+v", parallel, serial)
+        }
+        mc.Seed = 8
+        other, err := roi.MonteCarlo(mc)
+        if err != nil {
+            t.Errorf("MonteCarlo internal error: %v", err)
+            return
+        }
+        if other == serial {
+            t.Errorf("MonteCarlo got the same result with another seed")
+        }
+    })
+
+    t.Run("Correlation", func(t *testing.T) {
+        spread := func (correlation float64) float64 {
+            got, err := roi.MonteCarlo(MonteCarlo{
+                RevenueGrowth: &revenue_growth,
+                ExitCapRate: &exit_cap_rate,
+                Correlation: [][]float64{{1, 0, correlation}, {0, 1, 0}, {correlation, 0, 1}},
+                Simulations: 500,
+                Seed: 7,
+            })
+            if err != nil {
+                t.Errorf("MonteCarlo internal error: %v", err)
+            }
+            return got.LeveredIRR.P95 - got.LeveredIRR.P5
+        }
+        // a higher growth with a higher exit cap rate offset each other, a
+        // higher growth with a lower exit cap rate add up.
+        if offset, add_up := spread(1), spread(-1); offset >= add_up {
+            t.Errorf("LeveredIRR spread got: %v with correlation 1 and %v with -1, wanted a lower spread with correlation 1", offset, add_up)
+        }
+    })
+
+    t.Run("Invalid draws", func(t *testing.T) {
+        wide_cap_rate, _ := NewNormalDistribution(0.065, 0.05)
+        got, err := roi.MonteCarlo(MonteCarlo{ExitCapRate: &wide_cap_rate, Simulations: 200, Seed: 3})
+        if err != nil {
+            t.Errorf("MonteCarlo internal error: %v", err)
+            return
+        }
+        if got.Failed == 0 || got.Failed == got.Simulations {
+            t.Errorf("Failed got: %v, wanted some of the %v simulations", got.Failed, got.Simulations)
+        }
+    })
+
+    t.Run("Loss", func(t *testing.T) {
+        recession, _ := NewNormalDistribution(-0.02, 0.02)
+        high_cap_rate, _ := NewUniformDistribution(0.10, 0.30)
+        got, err := roi.MonteCarlo(MonteCarlo{RevenueGrowth: &recession, ExitCapRate: &high_cap_rate, Simulations: 100, Seed: 3})
+        if err != nil {
+            t.Errorf("MonteCarlo internal error: %v", err)
+            return
+        }
+        if got.ProbabilityOfLoss < 0.95 || got.ProbabilityOfCovenantBreach < 0.5 {
+            t.Errorf("Probabilities got: %v %v, wanted most of the simulations", got.ProbabilityOfLoss, got.ProbabilityOfCovenantBreach)
+        }
+        if got.UndefinedIRR == 0 {
+            t.Errorf("UndefinedIRR got: 0, wanted the simulations losing all the equity")
+        }
+    })
+
+    var errorCases = []struct {
+        name string
+        mc MonteCarlo
+    }{
+        {"No simulations", MonteCarlo{}},
+        {"Negative workers", MonteCarlo{Simulations: 10, Workers: -1}},
+        {"Negative covenant", MonteCarlo{Simulations: 10, CovenantDSCR: -1}},
+        {"Invalid distribution", MonteCarlo{ExitCapRate: &Distribution{Type: UniformDistribution, Min: 0.07, Max: 0.06}, Simulations: 10}},
+        {"Unknown distribution", MonteCarlo{ExitCapRate: &Distribution{Type: "lognormal"}, Simulations: 10}},
+        {"Correlation size", MonteCarlo{Correlation: [][]float64{{1, 0}, {0, 1}}, Simulations: 10}},
+        {"Correlation diagonal", MonteCarlo{Correlation: [][]float64{{1, 0, 0}, {0, 0.5, 0}, {0, 0, 1}}, Simulations: 10}},
+        {"Correlation not symmetric", MonteCarlo{Correlation: [][]float64{{1, 0.5, 0}, {0, 1, 0}, {0, 0, 1}}, Simulations: 10}},
+        {"Correlation out of range", MonteCarlo{Correlation: [][]float64{{1, 2, 0}, {2, 1, 0}, {0, 0, 1}}, Simulations: 10}},
+        {"Correlation not positive semi-definite", MonteCarlo{Correlation: [][]float64{{1, 0.9, -0.9}, {0.9, 1, 0.9}, {-0.9, 0.9, 1}}, Simulations: 10}},
+    }
+    for _, test := range errorCases {
+        t.Run(test.name, func(t *testing.T) {
+            if _, err := roi.MonteCarlo(test.mc); err == nil {
+                t.Errorf("MonteCarlo got no error, wanted an error")
+            }
+        })
+    }
+}
@@ -0,0 +1,236 @@
+// Objective searches over the deal. Holding everything but one input fixed, we
+// look for the limit value of that input that still meets a set of targets.
+
+package investment_analysis
+
+import (
+    "fmt";
+    ls "github.com/jacobitosuperstar/go-cre-loan-calculations/loan_sizer";
+)
+
+// ReturnTarget is the name of a return of investment metric that can be used
+// as a target in the objective search.
+type ReturnTarget string
+
+const (
+    LeveredIRRTarget        ReturnTarget = "levered_irr"
+    EquityMultipleTarget    ReturnTarget = "equity_multiple"
+    CashOnCashTarget        ReturnTarget = "year_one_cash_on_cash"
+)
+
+// Upper limit of the purchase price search. If the targets are still met at
+// this price, the search stops with an error.
+const maxSearchPurchasePrice = 1 << 40
+
+// ReturnTargets is a struct that has the minimum values of the return of
+// investment metrics that a deal must meet. A zero value means that the
+// target is not applied.
+type ReturnTargets struct {
+    MinLeveredIRR           float64
+    MinEquityMultiple       float64
+    MinYearOneCashOnCash    float64
+}
+
+// PurchasePriceResult is a struct with the outcome of the maximum purchase
+// price search.
+type PurchasePriceResult struct {
+    PurchasePrice           int
+    LoanAmount              float64
+    Metrics                 ReturnMetrics
+    YearOneCashOnCash       float64
+    BindingTarget           ReturnTarget
+}
+
+// failed_target returns the first target that is not met by the metrics. If
+// every target is met, an empty ReturnTarget is returned.
+func (targets ReturnTargets) failed_target (
+    metrics ReturnMetrics,
+    yearOneCashOnCash float64,
+) ReturnTarget {
+    if targets.MinLeveredIRR != 0 && metrics.LeveredIRR < targets.MinLeveredIRR {
+        return LeveredIRRTarget
+    }
+    if targets.MinEquityMultiple != 0 && metrics.EquityMultiple < targets.MinEquityMultiple {
+        return EquityMultipleTarget
+    }
+    if targets.MinYearOneCashOnCash != 0 && yearOneCashOnCash < targets.MinYearOneCashOnCash {
+        return CashOnCashTarget
+    }
+    return ""
+}
+
+// with_purchase_price returns a copy of the ReturnOfInvestment with a new
+// purchase price. The loan is sized again through NewLoanSizer with the new
+// property value and project cost, everything else is held fixed.
+func (roi ReturnOfInvestment) with_purchase_price (purchasePrice int) (ReturnOfInvestment, error) {
+    deal := roi.dealMetrics
+    dealMetrics, err := NewDealInformation(
+        purchasePrice,
+        deal.ClosingAndRenovations,
+        deal.GoingInCapRate,
+        deal.InitRevenue,
+        deal.InitOperatingExpenses,
+        deal.InitCapitalReserves,
+        deal.ProjRevenueGrowth,
+        deal.ProjOperatingExpensesGrowth,
+        deal.ProjCapitalReservesGrowth,
+    )
+    if err != nil {
+        return roi, fmt.Errorf("NewDealInformation internal error: %v", err)
+    }
+    dealMetrics.RevenueGrowth = deal.RevenueGrowth
+    dealMetrics.OperatingExpensesGrowth = deal.OperatingExpensesGrowth
+    dealMetrics.CapitalReservesGrowth = deal.CapitalReservesGrowth
+
+    // A requested loan amount that is equal or greater than the property
+    // value can never be the binding amount, so it follows the new price.
+    loan := roi.loanMetrics
+    requestedLoanAmount := loan.RequestedLoanAmount
+    if requestedLoanAmount >= loan.PropertyValue {
+        requestedLoanAmount = 0
+    }
+    loanSizer, err := ls.NewLoanSizer(
+        loan.MaxLTV,
+        loan.MinDSCR,
+        loan.Amortization,
+        loan.Term,
+        loan.IOPeriod,
+        loan.Rate,
+        dealMetrics.PurchasePrice,
+        loan.NOI,
+        requestedLoanAmount,
+        loan.LoanOriginationFees,
+    )
+    if err != nil {
+        return roi, fmt.Errorf("NewLoanSizer internal error: %v", err)
+    }
+    loan.PropertyValue = loanSizer.PropertyValue
+    loan.RequestedLoanAmount = loanSizer.RequestedLoanAmount
+    loan.ProjectCost = dealMetrics.ProjectCost()
+
+    roi.dealMetrics = dealMetrics
+    roi.loanMetrics = loan
+    return roi, nil
+}
+
+// evaluate_purchase_price returns the result of the deal at the given
+// purchase price, with the first target that is not met.
+func (roi ReturnOfInvestment) evaluate_purchase_price (
+    purchasePrice int,
+    targets ReturnTargets,
+) (
+    PurchasePriceResult,
+    error,
+) {
+    var result PurchasePriceResult
+
+    candidate, err := roi.with_purchase_price(purchasePrice)
+    if err != nil {
+        return result, fmt.Errorf("with_purchase_price internal error: %v", err)
+    }
+    projection, err := candidate.Projection()
+    if err != nil {
+        return result, fmt.Errorf("Projection internal error: %v", err)
+    }
+    metrics, err := return_metrics(projection)
+    if err != nil {
+        return result, fmt.Errorf("return_metrics internal error: %v", err)
+    }
+    mla, err := candidate.loanMetrics.MaximumLoanAmount()
+    if err != nil {
+        return result, fmt.Errorf("MaximumLoanAmount internal error: %v", err)
+    }
+    // a negative cash flow on the year 1 never meets the target.
+    year_one_cocr := projection.cash_on_cash(projection.Years[0])
+
+    result = PurchasePriceResult{
+        PurchasePrice: purchasePrice,
+        LoanAmount: mla,
+        Metrics: metrics,
+        YearOneCashOnCash: year_one_cocr,
+        BindingTarget: targets.failed_target(metrics, year_one_cocr),
+    }
+    return result, nil
+}
+
+// MaximumPurchasePrice returns the highest purchase price at which the deal
+// still meets the return targets. The growth of the deal, the loan terms, the
+// tax assumptions and the sale terms are held fixed, while the loan is sized
+// again at every candidate price. The BindingTarget of the result is the
+// target that fails one dollar above the returned price.
+func (roi ReturnOfInvestment) MaximumPurchasePrice (targets ReturnTargets) (PurchasePriceResult, error) {
+    if targets == (ReturnTargets{}) {
+        return PurchasePriceResult{}, fmt.Errorf("At least one return target must be given.")
+    }
+
+    meets_targets := func (purchasePrice int) (PurchasePriceResult, bool, error) {
+        result, err := roi.evaluate_purchase_price(purchasePrice, targets)
+        if err != nil {
+            return result, false, fmt.Errorf("evaluate_purchase_price internal error: %v", err)
+        }
+        return result, result.BindingTarget == "", nil
+    }
+
+    // Bracketing of the maximum purchase price, starting from the current
+    // purchase price of the deal.
+    low := roi.dealMetrics.PurchasePrice
+    if low < 1 {
+        low = 1
+    }
+    best, ok, err := meets_targets(low)
+    if err != nil {
+        return best, err
+    }
+    high := low
+    var above PurchasePriceResult
+    if ok {
+        for {
+            high *= 2
+            if high > maxSearchPurchasePrice {
+                return best, fmt.Errorf("The return targets are met for any purchase price.")
+            }
+            result, ok, err := meets_targets(high)
+            if err != nil {
+                return best, err
+            }
+            if !ok {
+                above = result
+                break
+            }
+            low, best = high, result
+        }
+    } else {
+        above = best
+        for {
+            low /= 2
+            if low < 1 {
+                return PurchasePriceResult{}, fmt.Errorf("No purchase price meets the return targets.")
+            }
+            result, ok, err := meets_targets(low)
+            if err != nil {
+                return PurchasePriceResult{}, err
+            }
+            if ok {
+                best = result
+                break
+            }
+            high, above = low, result
+        }
+    }
+
+    // Bisection of the bracket down to a dollar.
+    for high - low > 1 {
+        mid := low + (high - low) / 2
+        result, ok, err := meets_targets(mid)
+        if err != nil {
+            return best, err
+        }
+        if ok {
+            low, best = mid, result
+        } else {
+            high, above = mid, result
+        }
+    }
+    best.BindingTarget = above.BindingTarget
+    return best, nil
+}
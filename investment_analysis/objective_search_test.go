@@ -0,0 +1,68 @@
+package investment_analysis
+import (
+    "testing";
+)
+
+func TestMaximumPurchasePrice(t *testing.T) {
+    var testCases = []struct {
+        name string
+        targets ReturnTargets
+        wantBinding ReturnTarget
+    }{
+        {
+            name: "IRR target below the current deal",
+            targets: ReturnTargets{MinLeveredIRR: 0.20},
+            wantBinding: LeveredIRRTarget,
+        },
+        {
+            name: "IRR target above the current deal",
+            targets: ReturnTargets{MinLeveredIRR: 0.30},
+            wantBinding: LeveredIRRTarget,
+        },
+        {
+            name: "Cash on cash as binding target",
+            targets: ReturnTargets{MinLeveredIRR: 0.10, MinYearOneCashOnCash: 0.06},
+            wantBinding: CashOnCashTarget,
+        },
+        {
+            name: "Equity multiple as binding target",
+            targets: ReturnTargets{MinLeveredIRR: 0.10, MinEquityMultiple: 7},
+            wantBinding: EquityMultipleTarget,
+        },
+    }
+
+    roi, err := newTestROI(baseTestROI)
+    if err != nil {
+        t.Errorf("ReturnOfInvestment internal error: %v", err)
+        return
+    }
+
+    for _, test := range testCases {
+        t.Run(test.name, func(t *testing.T) {
+            got, err := roi.MaximumPurchasePrice(test.targets)
+            if err != nil {
+                t.Errorf("MaximumPurchasePrice internal error: %v", err)
+                return
+            }
+            if got.BindingTarget != test.wantBinding {
+                t.Errorf("BindingTarget got: %v, wanted: %v", got.BindingTarget, test.wantBinding)
+            }
+            // The returned price must meet the targets, one dollar more must
+            // not.
+            at, err := roi.evaluate_purchase_price(got.PurchasePrice, test.targets)
+            if err != nil || at.BindingTarget != "" {
+                t.Errorf("PurchasePrice %v does not meet the targets: %+v", got.PurchasePrice, at)
+            }
+            above, err := roi.evaluate_purchase_price(got.PurchasePrice + 1, test.targets)
+            if err != nil || above.BindingTarget != test.wantBinding {
+                t.Errorf("PurchasePrice %v meets the targets: %+v", got.PurchasePrice + 1, above)
+            }
+        })
+    }
+
+    t.Run("No targets", func(t *testing.T) {
+        if _, err := roi.MaximumPurchasePrice(ReturnTargets{}); err == nil {
+            t.Errorf("expected an error without targets")
+        }
+    })
+}
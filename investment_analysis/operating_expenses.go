@@ -0,0 +1,201 @@
+// Operating expenses of the property line by line. Every expense item grows
+// at its own rate, and the expense of every year is built from them instead
+// of growing the initial operating expenses at a flat rate.
+
+package investment_analysis
+
+import (
+    "fmt";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+)
+
+// ExpenseItem is a named line of the operating expenses of the property, like
+// the taxes, the insurance, the utilities, the management fee, the repairs or
+// the payroll.
+//
+// The Amount is the expense of the year 1 (negative), and it grows by the
+// Growth every year. An item with a ShareOfRevenue, like the management fee,
+// is that share of the effective gross revenue of every year instead. A
+// Variable item changes with the occupancy of the property, its Amount is the
+// expense at full occupancy and every year it is taken at the occupancy left
+// by the vacancy of the operating statement and the downtime of the rent
+// roll. An item with a ReassessmentRate, like the property taxes, is
+// reassessed at the sale to that share of the sale price, and the buyer's NOI
+// on which the property is sold carries the reassessed item.
+type ExpenseItem struct {
+    Name                string
+    Amount              float64
+    Growth              float64
+    ShareOfRevenue      float64
+    Variable            bool
+    ReassessmentRate    float64
+}
+
+// NewExpenseItem returns an ExpenseItem struct if the values given are valid.
+// If not, returns a default struct with the error.
+func NewExpenseItem(
+    name string,
+    amount float64,
+    growth float64,
+    shareOfRevenue float64,
+    variable bool,
+    reassessmentRate float64,
+) (
+    ExpenseItem,
+    error,
+) {
+    // Data Validation
+    if amount > 0 {
+        return ExpenseItem{}, fmt.Errorf("The amount of the expense %v cannot be greater than 0.", name)
+    }
+    if growth <= -1 {
+        return ExpenseItem{}, fmt.Errorf("The growth of the expense %v must be greater than -1.", name)
+    }
+    if shareOfRevenue < 0 || shareOfRevenue > 1 {
+        return ExpenseItem{}, fmt.Errorf("The shareOfRevenue of the expense %v must be between 0 and 1.", name)
+    }
+    if shareOfRevenue > 0 && amount != 0 {
+        return ExpenseItem{}, fmt.Errorf("The expense %v is either an amount or a share of the revenue.", name)
+    }
+    if reassessmentRate < 0 || reassessmentRate > 1 {
+        return ExpenseItem{}, fmt.Errorf("The reassessmentRate of the expense %v must be between 0 and 1.", name)
+    }
+    // Struct Creation
+    item := ExpenseItem{
+        Name: name,
+        Amount: amount,
+        Growth: growth,
+        ShareOfRevenue: shareOfRevenue,
+        Variable: variable,
+        ReassessmentRate: reassessmentRate,
+    }
+    return item, nil
+}
+
+// expense returns the expense of the item on the given year of the
+// projection, starting at 0, for the effective gross revenue and the
+// occupancy of the year.
+func (item ExpenseItem) expense (year int, revenue float64, occupancy float64) float64 {
+    if item.ShareOfRevenue > 0 {
+        return utils.Round2(- revenue * item.ShareOfRevenue)
+    }
+    amount := item.Amount
+    for i := 0; i < year; i++ {
+        amount = utils.Round2(amount + amount * item.Growth)
+    }
+    if item.Variable {
+        amount = utils.Round2(amount * occupancy)
+    }
+    return amount
+}
+
+// OperatingExpenses is the list of the expense items of the property.
+type OperatingExpenses struct {
+    Items       []ExpenseItem
+}
+
+// NewOperatingExpenses returns an OperatingExpenses struct if the items given
+// are valid. The names of the items cannot repeat.
+func NewOperatingExpenses(items ...ExpenseItem) (OperatingExpenses, error) {
+    validated := make([]ExpenseItem, len(items))
+    names := make(map[string]bool, len(items))
+    for i, item := range items {
+        v, err := NewExpenseItem(
+            item.Name,
+            item.Amount,
+            item.Growth,
+            item.ShareOfRevenue,
+            item.Variable,
+            item.ReassessmentRate,
+        )
+        if err != nil {
+            return OperatingExpenses{}, fmt.Errorf("NewExpenseItem internal error: %v", err)
+        }
+        if names[v.Name] {
+            return OperatingExpenses{}, fmt.Errorf("The expense %v is repeated.", v.Name)
+        }
+        names[v.Name] = true
+        validated[i] = v
+    }
+    return OperatingExpenses{Items: validated}, nil
+}
+
+// ExpenseLine is the expense of an item on a year of the projection
+// (negative).
+type ExpenseLine struct {
+    Name        string  `json:"name"`
+    Year        int     `json:"year"`
+    Amount      float64 `json:"amount"`
+}
+
+// expense_lines returns the ExpenseLine of every item on the given year of
+// the projection, starting at 0, and their total.
+func (oe OperatingExpenses) expense_lines (year int, revenue float64, occupancy float64) ([]ExpenseLine, float64) {
+    lines := make([]ExpenseLine, len(oe.Items))
+    total := 0.0
+    for i, item := range oe.Items {
+        amount := item.expense(year, revenue, occupancy)
+        lines[i] = ExpenseLine{Name: item.Name, Year: year + 1, Amount: amount}
+        total += amount
+    }
+    return lines, utils.Round2(total)
+}
+
+// operating_expenses returns the ExpenseLine of every item of the deal on the
+// given year of the projection, starting at 0, and their total, for the
+// lines of the operating statement of the year.
+func (roi ReturnOfInvestment) operating_expenses (year int, lines revenue_lines) ([]ExpenseLine, float64) {
+    return roi.operatingExpenses.expense_lines(year, lines.effective, lines.occupancy())
+}
+
+// reassessed_noi returns the NOI of the buyer, on the year after the sale,
+// with the items that have a ReassessmentRate charged on the sale price, for
+// the lines of the operating statement of that year. The sale price is the
+// NOI over the exit cap rate, so the NOI without the reassessed items is
+// capitalized at the exit cap rate plus the reassessment rates.
+func (roi ReturnOfInvestment) reassessed_noi (lines revenue_lines) float64 {
+    year := roi.saleMetrics.SaleYear
+    revenue, occupancy := lines.effective, lines.occupancy()
+    expense, reassessment_rate := 0.0, 0.0
+    for _, item := range roi.operatingExpenses.Items {
+        if item.ReassessmentRate > 0 {
+            reassessment_rate += item.ReassessmentRate
+            continue
+        }
+        expense += item.expense(year, revenue, occupancy)
+    }
+    noi := utils.Round2(revenue + expense)
+    if reassessment_rate == 0 {
+        return noi
+    }
+    sale_price := utils.Round2(noi / (roi.saleMetrics.ExitCapRate + reassessment_rate))
+    return utils.Round2(noi - sale_price * reassessment_rate)
+}
+
+// WithOperatingExpenses returns a copy of the ReturnOfInvestment with the
+// operating expenses of every year built from the given items, in place of
+// the initial operating expenses grown at the expenses growth of the deal.
+// The initial operating expenses of the deal are set to the expenses of the
+// items on the year 1, and the loan is sized on the NOI of that year. The deal
+// cannot have an operating expenses growth vector.
+func (roi ReturnOfInvestment) WithOperatingExpenses (oe OperatingExpenses) (ReturnOfInvestment, error) {
+    validated, err := NewOperatingExpenses(oe.Items...)
+    if err != nil {
+        return roi, fmt.Errorf("NewOperatingExpenses internal error: %v", err)
+    }
+    if len(validated.Items) == 0 {
+        return roi, fmt.Errorf("The operating expenses must have at least one item.")
+    }
+    if roi.dealMetrics.OperatingExpensesGrowth != nil {
+        return roi, fmt.Errorf("The expense growth is not used with operating expense items.")
+    }
+    roi.operatingExpenses = &validated
+    return roi.with_year_one_noi(), nil
+}
+
+// year_one_expenses returns the operating expenses of the items of the deal
+// on the year 1.
+func (roi ReturnOfInvestment) year_one_expenses () float64 {
+    _, total := roi.operating_expenses(0, roi.year_one_lines())
+    return total
+}
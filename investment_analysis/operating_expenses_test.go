@@ -0,0 +1,210 @@
+package investment_analysis
+import (
+    "testing";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+)
+
+// testExpenseItems are the operating expenses of the test deal, with the
+// utilities and the repairs changing with the occupancy, the management fee
+// at 3% of the effective gross revenue and the taxes reassessed at 1.5% of
+// the sale price.
+var testExpenseItems = []ExpenseItem{
+    {"Taxes", -90000, 0.02, 0, false, 0.015},
+    {"Insurance", -30000, 0.04, 0, false, 0},
+    {"Utilities", -45000, 0.03, 0, true, 0},
+    {"Management fee", 0, 0, 0.03, false, 0},
+    {"Repairs", -40000, 0.03, 0, true, 0},
+    {"Payroll", -60000, 0.035, 0, false, 0},
+}
+
+func TestOperatingExpenses(t *testing.T) {
+    base, err := newTestROI(baseTestROI)
+    if err != nil {
+        t.Errorf("ReturnOfInvestment internal error: %v", err)
+        return
+    }
+    vacancy, err := NewOperatingStatement(0.05, 0, 0)
+    if err != nil {
+        t.Errorf("NewOperatingStatement internal error: %v", err)
+        return
+    }
+    not_reassessed := make([]ExpenseItem, len(testExpenseItems))
+    copy(not_reassessed, testExpenseItems)
+    not_reassessed[0].ReassessmentRate = 0
+
+    var testCases = []struct {
+        name string
+        items []ExpenseItem
+        statement *OperatingStatement
+        expenses float64
+        year_one []float64
+        year_ten []float64
+        sale_price float64
+        cash_flows map[int]float64
+        irr float64
+    }{
+        {
+            name: "Full occupancy",
+            items: testExpenseItems,
+            expenses: -285625,
+            year_one: []float64{-90000, -30000, -45000, -20625, -40000, -60000},
+            year_ten: []float64{-107558.33, -42699.35, -58714.8, -28109.76, -52190.92, -81773.85},
+            sale_price: 8499768.6,
+            cash_flows: map[int]float64{1: 122891.86, 2: 134847.32, 10: 4202915.04},
+            irr: 0.1202,
+        },
+        {
+            name: "Variable expenses at 95% occupancy",
+            items: testExpenseItems,
+            statement: &vacancy,
+            expenses: -280343.75,
+            year_one: []float64{-90000, -30000, -42750, -19593.75, -38000, -60000},
+            year_ten: []float64{-107558.33, -42699.35, -55779.06, -26704.27, -49581.37, -81773.85},
+            sale_price: 7996144.35,
+            cash_flows: map[int]float64{1: 101071.55, 2: 112247.36, 10: 3744910.32},
+            irr: 0.1019,
+        },
+        {
+            // the taxes of the buyer are the grown taxes, lower than the
+            // reassessed ones.
+            name: "Taxes not reassessed",
+            items: not_reassessed,
+            statement: &vacancy,
+            expenses: -280343.75,
+            year_one: []float64{-90000, -30000, -42750, -19593.75, -38000, -60000},
+            sale_price: 8195766,
+            cash_flows: map[int]float64{10: 3914588.72},
+            irr: 0.1057,
+        },
+    }
+    for _, test := range testCases {
+        t.Run(test.name, func(t *testing.T) {
+            roi := base
+            if test.statement != nil {
+                roi, err = roi.WithOperatingStatement(*test.statement)
+                if err != nil {
+                    t.Errorf("WithOperatingStatement internal error: %v", err)
+                    return
+                }
+            }
+            roi, err := roi.WithOperatingExpenses(OperatingExpenses{Items: test.items})
+            if err != nil {
+                t.Errorf("WithOperatingExpenses internal error: %v", err)
+                return
+            }
+            if roi.dealMetrics.InitOperatingExpenses != test.expenses {
+                t.Errorf("InitOperatingExpenses got: %v, wanted: %v", roi.dealMetrics.InitOperatingExpenses, test.expenses)
+            }
+            projection, err := roi.Projection()
+            if err != nil {
+                t.Errorf("Projection internal error: %v", err)
+                return
+            }
+            if len(projection.Expenses) != len(test.items) * baseTestROI.saleYear {
+                t.Errorf("Expenses got: %v lines, wanted: %v", len(projection.Expenses), len(test.items) * baseTestROI.saleYear)
+                return
+            }
+            for year, want := range map[int][]float64{1: test.year_one, 10: test.year_ten} {
+                total := 0.0
+                for i, amount := range want {
+                    line := projection.Expenses[(year - 1) * len(test.items) + i]
+                    if line.Name != test.items[i].Name || line.Year != year || !utils.Tolerance(line.Amount, amount, 0.01) {
+                        t.Errorf("ExpenseLine got: %+v, wanted: %v %v %v", line, test.items[i].Name, year, amount)
+                    }
+                    total += amount
+                }
+                if want != nil && !utils.Tolerance(projection.Years[year - 1].Expense, total, 0.01) {
+                    t.Errorf("Expense of the year %v got: %v, wanted: %v", year, projection.Years[year - 1].Expense, total)
+                }
+            }
+            if !utils.Tolerance(projection.Sale.SalePrice, test.sale_price, 0.01) {
+                t.Errorf("SalePrice got: %v, wanted: %v", projection.Sale.SalePrice, test.sale_price)
+            }
+            cash_flows := projection.CashFlows()
+            for year, want := range test.cash_flows {
+                if !utils.Tolerance(cash_flows[year], want, 0.01) {
+                    t.Errorf("Year %v net_cash_flow got: %v, wanted: %v", year, cash_flows[year], want)
+                }
+            }
+            metrics, err := return_metrics(projection)
+            if err != nil {
+                t.Errorf("return_metrics internal error: %v", err)
+                return
+            }
+            if !utils.Tolerance(metrics.LeveredIRR, test.irr, 0.0001) {
+                t.Errorf("LeveredIRR got: %v, wanted: %v", metrics.LeveredIRR, test.irr)
+            }
+        })
+    }
+
+    t.Run("Statement after the expenses", func(t *testing.T) {
+        roi, err := base.WithOperatingExpenses(OperatingExpenses{Items: testExpenseItems})
+        if err != nil {
+            t.Errorf("WithOperatingExpenses internal error: %v", err)
+            return
+        }
+        roi, err = roi.WithOperatingStatement(vacancy)
+        if err != nil {
+            t.Errorf("WithOperatingStatement internal error: %v", err)
+            return
+        }
+        if roi.dealMetrics.InitOperatingExpenses != -280343.75 || roi.loanMetrics.NOI != 372781.25 {
+            t.Errorf("InitOperatingExpenses and NOI got: %v %v, wanted: %v %v", roi.dealMetrics.InitOperatingExpenses, roi.loanMetrics.NOI, -280343.75, 372781.25)
+        }
+        if _, err := roi.with_input(ExpenseGrowthInput, 0.02); err == nil {
+            t.Errorf("with_input got no error, wanted an error")
+        }
+    })
+
+    t.Run("Expense growth vector", func(t *testing.T) {
+        growth := []float64{0.03, 0.03, 0.03, 0.03, 0.03, 0.03, 0.03, 0.03, 0.03, 0.03}
+        roi, err := base.WithOperatingExpenses(OperatingExpenses{Items: testExpenseItems})
+        if err != nil {
+            t.Errorf("WithOperatingExpenses internal error: %v", err)
+            return
+        }
+        if _, err := roi.WithGrowthVectors(nil, growth, nil); err == nil {
+            t.Errorf("WithGrowthVectors got no error, wanted an error")
+        }
+        vectors, err := base.WithGrowthVectors(nil, growth, nil)
+        if err != nil {
+            t.Errorf("WithGrowthVectors internal error: %v", err)
+            return
+        }
+        if _, err := vectors.WithOperatingExpenses(OperatingExpenses{Items: testExpenseItems}); err == nil {
+            t.Errorf("WithOperatingExpenses got no error, wanted an error")
+        }
+    })
+}
+
+func TestNewOperatingExpenses(t *testing.T) {
+    var testCases = []struct {
+        name string
+        items []ExpenseItem
+    }{
+        {"Positive amount", []ExpenseItem{{"Taxes", 90000, 0.02, 0, false, 0}}},
+        {"Growth of -100%", []ExpenseItem{{"Taxes", -90000, -1, 0, false, 0}}},
+        {"Share of revenue above 1", []ExpenseItem{{"Management fee", 0, 0, 1.5, false, 0}}},
+        {"Amount and share of revenue", []ExpenseItem{{"Management fee", -20000, 0, 0.03, false, 0}}},
+        {"Negative reassessment rate", []ExpenseItem{{"Taxes", -90000, 0.02, 0, false, -0.01}}},
+        {"Repeated names", []ExpenseItem{testExpenseItems[0], testExpenseItems[0]}},
+    }
+    for _, test := range testCases {
+        t.Run(test.name, func(t *testing.T) {
+            if _, err := NewOperatingExpenses(test.items...); err == nil {
+                t.Errorf("NewOperatingExpenses got no error, wanted an error")
+            }
+        })
+    }
+
+    t.Run("No items", func(t *testing.T) {
+        roi, err := newTestROI(baseTestROI)
+        if err != nil {
+            t.Errorf("ReturnOfInvestment internal error: %v", err)
+            return
+        }
+        if _, err := roi.WithOperatingExpenses(OperatingExpenses{}); err == nil {
+            t.Errorf("WithOperatingExpenses got no error, wanted an error")
+        }
+    })
+}
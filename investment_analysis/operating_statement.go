@@ -0,0 +1,236 @@
+// Operating statement of the property. The revenue of every year starts from
+// the gross potential revenue and goes down to the effective gross revenue,
+// less the general vacancy, the concessions and the credit loss, the way the
+// property financials report it.
+
+package investment_analysis
+
+import (
+    "fmt";
+    "math";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+)
+
+// OperatingStatement is a struct with the losses taken from the gross
+// potential revenue of every year.
+//
+// The Vacancy and the Concessions are shares of the gross potential revenue,
+// and the CreditLoss is the share of the rent billed to the tenants, the gross
+// potential revenue less the vacancy and the concessions, that is never
+// collected. The VacancyByYear, CreditLossByYear and ConcessionsByYear
+// vectors, when set, have the rate of every year instead, the first rate is
+// the one of the year 1. Past the end of a vector the constant rate is used.
+type OperatingStatement struct {
+    Vacancy             float64
+    CreditLoss          float64
+    Concessions         float64
+    VacancyByYear       []float64
+    CreditLossByYear    []float64
+    ConcessionsByYear   []float64
+}
+
+// NewOperatingStatement returns an OperatingStatement struct if the values
+// given are valid. If not, returns a default struct with the error.
+func NewOperatingStatement(
+    vacancy float64,
+    creditLoss float64,
+    concessions float64,
+) (
+    OperatingStatement,
+    error,
+) {
+    // Data Validation
+    if vacancy < 0 || vacancy > 1 {
+        return OperatingStatement{}, fmt.Errorf("The vacancy must be between 0 and 1.")
+    }
+    if creditLoss < 0 || creditLoss > 1 {
+        return OperatingStatement{}, fmt.Errorf("The creditLoss must be between 0 and 1.")
+    }
+    if concessions < 0 || concessions > 1 {
+        return OperatingStatement{}, fmt.Errorf("The concessions must be between 0 and 1.")
+    }
+    if vacancy + concessions > 1 {
+        return OperatingStatement{}, fmt.Errorf("The vacancy and the concessions cannot be greater than 1 together.")
+    }
+    // Struct Creation
+    statement := OperatingStatement{
+        Vacancy: vacancy,
+        CreditLoss: creditLoss,
+        Concessions: concessions,
+    }
+    return statement, nil
+}
+
+// WithYearlyRates returns a copy of the OperatingStatement with the given
+// rate of every year for the vacancy, the credit loss and the concessions. A
+// nil vector keeps the constant rate of that loss.
+func (statement OperatingStatement) WithYearlyRates (
+    vacancy []float64,
+    creditLoss []float64,
+    concessions []float64,
+) (
+    OperatingStatement,
+    error,
+) {
+    // Data Validation
+    vectors := []struct {
+        name string
+        rates []float64
+    }{
+        {"vacancy", vacancy},
+        {"creditLoss", creditLoss},
+        {"concessions", concessions},
+    }
+    for _, vector := range vectors {
+        if vector.rates != nil && len(vector.rates) == 0 {
+            return statement, fmt.Errorf("The %v vector must have at least one rate.", vector.name)
+        }
+        for year, rate := range vector.rates {
+            if rate < 0 || rate > 1 {
+                return statement, fmt.Errorf("The %v of the year %d must be between 0 and 1.", vector.name, year + 1)
+            }
+        }
+    }
+    // Struct Creation
+    with_rates := statement
+    with_rates.VacancyByYear = append([]float64(nil), vacancy...)
+    with_rates.CreditLossByYear = append([]float64(nil), creditLoss...)
+    with_rates.ConcessionsByYear = append([]float64(nil), concessions...)
+    years := max(len(vacancy), len(creditLoss), len(concessions))
+    for year := 0; year < years; year++ {
+        vacancy_rate, _, concessions_rate := with_rates.rates(year)
+        if vacancy_rate + concessions_rate > 1 {
+            return statement, fmt.Errorf("The vacancy and the concessions of the year %d cannot be greater than 1 together.", year + 1)
+        }
+    }
+    return with_rates, nil
+}
+
+// validated returns the OperatingStatement checked by its constructor.
+func (statement OperatingStatement) validated () (OperatingStatement, error) {
+    validated, err := NewOperatingStatement(
+        statement.Vacancy,
+        statement.CreditLoss,
+        statement.Concessions,
+    )
+    if err != nil {
+        return statement, fmt.Errorf("NewOperatingStatement internal error: %v", err)
+    }
+    validated, err = validated.WithYearlyRates(
+        statement.VacancyByYear,
+        statement.CreditLossByYear,
+        statement.ConcessionsByYear,
+    )
+    if err != nil {
+        return statement, fmt.Errorf("WithYearlyRates internal error: %v", err)
+    }
+    return validated, nil
+}
+
+// rates returns the vacancy, credit loss and concessions of the given year of
+// the projection, starting at 0.
+func (statement OperatingStatement) rates (year int) (vacancy float64, creditLoss float64, concessions float64) {
+    rate := func (vector []float64, constant float64) float64 {
+        if year < len(vector) {
+            return vector[year]
+        }
+        return constant
+    }
+    vacancy = rate(statement.VacancyByYear, statement.Vacancy)
+    creditLoss = rate(statement.CreditLossByYear, statement.CreditLoss)
+    concessions = rate(statement.ConcessionsByYear, statement.Concessions)
+    return vacancy, creditLoss, concessions
+}
+
+// revenue_lines are the lines of the operating statement from the gross
+// potential revenue of a year down to its effective gross revenue, with the
+// downtime loss of the rollover of the rent roll already taken out of the
+// gross potential revenue. The losses are negative.
+type revenue_lines struct {
+    gross_potential     float64
+    downtime            float64
+    vacancy             float64
+    credit_loss         float64
+    concessions         float64
+    effective           float64
+}
+
+// revenue_lines returns the lines of the operating statement of the given
+// year of the projection, starting at 0, for its gross potential revenue and
+// the downtime loss of the rent roll on the year. The general vacancy is an
+// allowance on the rent of the year with all the space leased, the gross
+// potential revenue plus the downtime loss, net of the downtime loss, only the
+// vacancy above the downtime is taken. Without an OperatingStatement the
+// effective gross revenue is the gross potential revenue.
+func (roi ReturnOfInvestment) revenue_lines (year int, grossPotential float64, downtime float64) revenue_lines {
+    lines := revenue_lines{gross_potential: grossPotential, downtime: downtime, effective: grossPotential}
+    if roi.operatingStatement == nil {
+        return lines
+    }
+    vacancy, credit_loss, concessions := roi.operatingStatement.rates(year)
+    lines.vacancy = utils.Round2(math.Min(- (grossPotential - downtime) * vacancy - downtime, 0))
+    lines.concessions = utils.Round2(- grossPotential * concessions)
+    lines.credit_loss = utils.Round2(- (grossPotential + lines.vacancy + lines.concessions) * credit_loss)
+    lines.effective = utils.Round2(grossPotential + lines.vacancy + lines.concessions + lines.credit_loss)
+    return lines
+}
+
+// occupancy returns the occupancy of the property of the given lines of the
+// operating statement, the share of the space that is not vacant by the
+// general vacancy or by the downtime of the rent roll.
+func (lines revenue_lines) occupancy () float64 {
+    leased := lines.gross_potential - lines.downtime
+    if leased <= 0 {
+        return 1
+    }
+    return math.Max(1 + (lines.vacancy + lines.downtime) / leased, 0)
+}
+
+// downtime_loss returns the downtime loss of the rollover of the rent roll for
+// every year from 1 to the given number of years, zero without a rent roll.
+func (roi ReturnOfInvestment) downtime_loss (years int) []float64 {
+    if roi.rentRoll == nil {
+        return make([]float64, years)
+    }
+    return roi.rentRoll.DowntimeLoss(years)
+}
+
+// year_one_lines returns the lines of the operating statement of the year 1 of
+// the deal.
+func (roi ReturnOfInvestment) year_one_lines () revenue_lines {
+    return roi.revenue_lines(0, roi.dealMetrics.InitRevenue, roi.downtime_loss(1)[0])
+}
+
+// year_one_noi returns the NOI of the year 1 of the deal, on which the loan is
+// sized, with the effective gross revenue of the year.
+func (roi ReturnOfInvestment) year_one_noi () float64 {
+    return roi.year_one_lines().effective + roi.dealMetrics.InitOperatingExpenses
+}
+
+// with_year_one_noi returns a copy of the ReturnOfInvestment with the loan
+// sized on the NOI of the year 1. If the deal has operating expense items, the
+// initial operating expenses are set to the ones of the items on the year 1.
+func (roi ReturnOfInvestment) with_year_one_noi () ReturnOfInvestment {
+    if roi.operatingExpenses != nil {
+        roi.dealMetrics.InitOperatingExpenses = roi.year_one_expenses()
+    }
+    roi.loanMetrics.NOI = roi.year_one_noi()
+    return roi
+}
+
+// WithOperatingStatement returns a copy of the ReturnOfInvestment with the
+// revenue of every year, the initial revenue grown by the revenue growth or
+// the revenue of the rent roll, taken as the gross potential revenue and
+// reduced by the vacancy, credit loss and concessions of the given
+// OperatingStatement. With a rent roll, the vacancy is a general allowance
+// that takes the downtime of the rollover of the leases as part of it, only
+// the vacancy above the downtime loss of the year is taken. The NOI on which
+// the loan is sized is the one of the effective gross revenue of the year 1.
+func (roi ReturnOfInvestment) WithOperatingStatement (statement OperatingStatement) (ReturnOfInvestment, error) {
+    validated, err := statement.validated()
+    if err != nil {
+        return roi, fmt.Errorf("OperatingStatement internal error: %v", err)
+    }
+    roi.operatingStatement = &validated
+    return roi.with_year_one_noi(), nil
+}
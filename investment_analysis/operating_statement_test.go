@@ -0,0 +1,131 @@
+package investment_analysis
+import (
+    "testing";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+)
+
+func TestOperatingStatement(t *testing.T) {
+    base, err := newTestROI(baseTestROI)
+    if err != nil {
+        t.Errorf("ReturnOfInvestment internal error: %v", err)
+        return
+    }
+    statement, err := NewOperatingStatement(0.05, 0.01, 0.02)
+    if err != nil {
+        t.Errorf("NewOperatingStatement internal error: %v", err)
+        return
+    }
+    // a lease up, with the vacancy of the first two years above the general
+    // vacancy.
+    lease_up, err := statement.WithYearlyRates([]float64{0.25, 0.10}, nil, nil)
+    if err != nil {
+        t.Errorf("WithYearlyRates internal error: %v", err)
+        return
+    }
+
+    var testCases = []struct {
+        name string
+        statement OperatingStatement
+        noi float64
+        loan float64
+        years map[int]CashFlowYear
+        cash_flows map[int]float64
+        irr float64
+    }{
+        {
+            name: "Constant rates",
+            statement: statement,
+            // 687500 less 5% of vacancy, 2% of concessions and 1% of the
+            // rent billed.
+            noi: 332981.25,
+            loan: 4339115,
+            years: map[int]CashFlowYear{
+                1: {GrossPotentialRevenue: 687500, Vacancy: -34375, CreditLoss: -6393.75, Concessions: -13750, Revenue: 632981.25},
+                10: {GrossPotentialRevenue: 936991.93, Vacancy: -46849.6, CreditLoss: -8714.02, Concessions: -18739.84, Revenue: 862688.47},
+            },
+            cash_flows: map[int]float64{0: -2429276.15, 1: 81795.65, 2: 92973.91, 10: 3596951.38},
+            irr: 0.0802,
+        },
+        {
+            name: "Lease up",
+            statement: lease_up,
+            noi: 196856.25,
+            loan: 2565255,
+            years: map[int]CashFlowYear{
+                1: {GrossPotentialRevenue: 687500, Vacancy: -171875, CreditLoss: -5018.75, Concessions: -13750, Revenue: 496856.25},
+                2: {GrossPotentialRevenue: 711562.5, Vacancy: -71156.25, CreditLoss: -6261.75, Concessions: -14231.25, Revenue: 619913.25},
+                3: {GrossPotentialRevenue: 736467.19, Vacancy: -36823.36, CreditLoss: -6849.14, Concessions: -14729.34, Revenue: 678065.35},
+            },
+            cash_flows: map[int]float64{0: -4185397.55, 1: 68645.98, 2: 155501.23, 3: 193541.87},
+            irr: 0.0614,
+        },
+    }
+    for _, test := range testCases {
+        t.Run(test.name, func(t *testing.T) {
+            roi, err := base.WithOperatingStatement(test.statement)
+            if err != nil {
+                t.Errorf("WithOperatingStatement internal error: %v", err)
+                return
+            }
+            // the loan is sized on the NOI of the effective gross revenue.
+            if !utils.Tolerance(roi.loanMetrics.NOI, test.noi, 0.01) {
+                t.Errorf("NOI got: %v, wanted: %v", roi.loanMetrics.NOI, test.noi)
+            }
+            mla, err := roi.loanMetrics.MaximumLoanAmount()
+            if err != nil {
+                t.Errorf("MaximumLoanAmount internal error: %v", err)
+                return
+            }
+            if mla != test.loan {
+                t.Errorf("MaximumLoanAmount got: %v, wanted: %v", mla, test.loan)
+            }
+            projection, err := roi.Projection()
+            if err != nil {
+                t.Errorf("Projection internal error: %v", err)
+                return
+            }
+            for year, want := range test.years {
+                got := projection.Years[year - 1]
+                if !utils.Tolerance(got.GrossPotentialRevenue, want.GrossPotentialRevenue, 0.01) ||
+                    !utils.Tolerance(got.Vacancy, want.Vacancy, 0.01) ||
+                    !utils.Tolerance(got.CreditLoss, want.CreditLoss, 0.01) ||
+                    !utils.Tolerance(got.Concessions, want.Concessions, 0.01) ||
+                    !utils.Tolerance(got.Revenue, want.Revenue, 0.01) {
+                    t.Errorf("Year %v got: %+v, wanted: %+v", year, got, want)
+                }
+            }
+            cash_flows := projection.CashFlows()
+            for year, want := range test.cash_flows {
+                if !utils.Tolerance(cash_flows[year], want, 0.01) {
+                    t.Errorf("Year %v net_cash_flow got: %v, wanted: %v", year, cash_flows[year], want)
+                }
+            }
+            metrics, err := return_metrics(projection)
+            if err != nil {
+                t.Errorf("return_metrics internal error: %v", err)
+                return
+            }
+            if !utils.Tolerance(metrics.LeveredIRR, test.irr, 0.0001) {
+                t.Errorf("LeveredIRR got: %v, wanted: %v", metrics.LeveredIRR, test.irr)
+            }
+        })
+    }
+
+    t.Run("Invalid rates", func(t *testing.T) {
+        if _, err := NewOperatingStatement(1.2, 0, 0); err == nil {
+            t.Errorf("NewOperatingStatement got no error, wanted an error")
+        }
+        if _, err := NewOperatingStatement(0.6, 0, 0.5); err == nil {
+            t.Errorf("NewOperatingStatement got no error, wanted an error")
+        }
+        if _, err := statement.WithYearlyRates(nil, []float64{-0.01}, nil); err == nil {
+            t.Errorf("WithYearlyRates got no error, wanted an error")
+        }
+        if _, err := statement.WithYearlyRates([]float64{0.5}, nil, []float64{0.6}); err == nil {
+            t.Errorf("WithYearlyRates got no error, wanted an error")
+        }
+        if _, err := base.WithOperatingStatement(OperatingStatement{Vacancy: 0.05, CreditLossByYear: []float64{2}}); err == nil {
+            t.Errorf("WithOperatingStatement got no error, wanted an error")
+        }
+    })
+}
@@ -0,0 +1,527 @@
+// Typed net cash flow projection of the deal. The projection has the
+// acquisition of the property, the yearly cash flows up to the sale and the
+// sale event itself.
+
+package investment_analysis
+
+import (
+    "fmt";
+    "math";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+    ls "github.com/jacobitosuperstar/go-cre-loan-calculations/loan_sizer";
+)
+
+// AcquisitionRow is the year 0 of the projection, the money needed to
+// adquire the property.
+type AcquisitionRow struct {
+    PurchasePrice           float64 `json:"purchase_price"`
+    ClosingAndRenovations   float64 `json:"closing_and_renovations"`
+    LoanAmount              float64 `json:"loan_amount"`
+    LoanOriginationFees     float64 `json:"loan_origination_fees"`
+    RateCapPremium          float64 `json:"rate_cap_premium"`
+    TrancheFunding          float64 `json:"tranche_funding"`
+    CapexHoldback           float64 `json:"capex_holdback"`
+    NetCashFlow             float64 `json:"net_cash_flow"`
+}
+
+// CashFlowYear is a year of operation of the property within the projection.
+// The Revenue is the effective gross revenue, the GrossPotentialRevenue less
+// the Vacancy, CreditLoss and Concessions of the operating statement
+// (negative). The DowntimeLoss (negative) is the rent lost to the downtime of
+// the rollover of the rent roll, already out of the GrossPotentialRevenue,
+// the GrossPotentialRevenue less the DowntimeLoss is the rent with all the
+// space leased, on which the vacancy allowance is taken. The
+// CapitalExpenditures (negative) are the capex of the year, and the
+// CapexFunding is the holdback released and the draws on the loan that pay
+// for them. The InterestPayment has the interest of the draws. The
+// AnnualDebtService (negative) is the debt service of the loan charged in the
+// cash flow after debt service, with the interest of the draws, and the
+// LoanPayoff (negative) is the balance of the loan, with the draws, paid off
+// at its maturity when the property is held past it.
+type CashFlowYear struct {
+    Year                        int     `json:"year"`
+    GrossPotentialRevenue       float64 `json:"gross_potential_revenue"`
+    DowntimeLoss                float64 `json:"downtime_loss"`
+    Vacancy                     float64 `json:"vacancy"`
+    CreditLoss                  float64 `json:"credit_loss"`
+    Concessions                 float64 `json:"concessions"`
+    Revenue                     float64 `json:"revenue"`
+    Expense                     float64 `json:"expense"`
+    NOI                         float64 `json:"noi"`
+    Reserve                     float64 `json:"reserve"`
+    TenantImprovements          float64 `json:"tenant_improvements"`
+    LeasingCommissions          float64 `json:"leasing_commissions"`
+    CapitalExpenditures         float64 `json:"capital_expenditures"`
+    CapexFunding                float64 `json:"capex_funding"`
+    PrincipalPayment            float64 `json:"principal_payment"`
+    InterestPayment             float64 `json:"interest_payment"`
+    AnnualDebtService           float64 `json:"annual_debt_service"`
+    LoanPayoff                  float64 `json:"loan_payoff"`
+    RateCapPayout               float64 `json:"rate_cap_payout"`
+    TranchePayments             float64 `json:"tranche_payments"`
+    CashFlowAfterDebtService    float64 `json:"cashflow_after_debt_service"`
+    DepreciationExpense         float64 `json:"depreciation_expense"`
+    IncomeTax                   float64 `json:"income_tax"`
+    ImpliedIncomeTax            float64 `json:"implied_income_tax"`
+    NetCashFlow                 float64 `json:"net_cash_flow"`
+    CashOnCashReturn            float64 `json:"cash_on_cash_return"`
+}
+
+// SaleEvent is the sale of the property, at the end of the year of sale.
+// The taxes, the payoff of the loan (BalloonPayment), with the draws for the
+// capex, and the penalties are negative. NetCashFlow is the money given by
+// the sale alone, the operating cash flow of that year is in its
+// CashFlowYear.
+type SaleEvent struct {
+    Year                        int     `json:"year"`
+    SalePrice                   float64 `json:"sale_price"`
+    DepreciationRecaptureTax    float64 `json:"depreciation_recapture_tax"`
+    CapitalGainsTax             float64 `json:"capital_gains_tax"`
+    BalloonPayment              float64 `json:"balloon_payment"`
+    PrepaymentPenalty           float64 `json:"prepayment_penalty"`
+    TrancheRepayment            float64 `json:"tranche_repayment"`
+    NetCashFlow                 float64 `json:"net_cash_flow"`
+}
+
+// Projection is the net cash flow projection of the deal. Negative values are
+// payments that need to be done, positive values are money given. Refinance
+// is nil if the loan of the deal is not refinanced. Expenses has the expense
+// of every item of the operating expenses on every year, if the deal has them.
+type Projection struct {
+    Acquisition     AcquisitionRow  `json:"acquisition"`
+    Years           []CashFlowYear  `json:"years"`
+    Expenses        []ExpenseLine   `json:"expenses,omitempty"`
+    Refinance       *RefinanceEvent `json:"refinance,omitempty"`
+    Sale            SaleEvent       `json:"sale"`
+}
+
+// cash_on_cash returns the cash on cash return of the given year of the
+// projection with its sign, negative on a year with a negative net cash flow,
+// unlike the CashOnCashReturn of the year.
+func (p Projection) cash_on_cash (year CashFlowYear) float64 {
+    adq_cost := math.Abs(p.Acquisition.NetCashFlow)
+    if adq_cost == 0 {
+        return 0
+    }
+    return utils.Round4(year.NetCashFlow / adq_cost)
+}
+
+// CashFlows returns the net cash flow of every year of the projection,
+// starting with the acquisition at year 0 and adding the refinance and the
+// sale to the year on which they happen.
+func (p Projection) CashFlows () []float64 {
+    cash_flows := make([]float64, len(p.Years) + 1)
+    cash_flows[0] = p.Acquisition.NetCashFlow
+    for i, year := range p.Years {
+        cash_flows[i + 1] = year.NetCashFlow
+    }
+    if p.Refinance != nil {
+        cash_flows[p.Refinance.Year] = utils.Round2(cash_flows[p.Refinance.Year] + p.Refinance.NetCashFlow)
+    }
+    cash_flows[p.Sale.Year] = utils.Round2(cash_flows[p.Sale.Year] + p.Sale.NetCashFlow)
+    return cash_flows
+}
+
+// ToMap returns the projection as a slice of maps, the form in which the
+// NetCashFlowProjection has been returned. Year 0 only has the
+// "net_cash_flow" key and the sale keys are added to the row of the year of
+// the sale, with its "net_cash_flow" including the sale. The cash out of a
+// refinance is added in the same way to the row of its year, and the
+// "loan_payoff" key to the row of the maturity of the loan.
+func (p Projection) ToMap () []map[string]interface{} {
+    net_cash_flow_projection := []map[string]interface{}{
+        {
+            "net_cash_flow": p.Acquisition.NetCashFlow,
+        },
+    }
+    for _, year := range p.Years {
+        net_cash_flow_projection = append(
+            net_cash_flow_projection,
+            map[string]interface{} {
+                "year": year.Year,
+                "downtime_loss": year.DowntimeLoss,
+                "revenue": year.Revenue,
+                "expense": year.Expense,
+                "noi": year.NOI,
+                "reserve": year.Reserve,
+                "principal_payment": year.PrincipalPayment,
+                "interest_payment": year.InterestPayment,
+                "cashflow_after_debt_service": year.CashFlowAfterDebtService,
+                "depreciation_expense": year.DepreciationExpense,
+                "income_tax": year.IncomeTax,
+                "implied_income_tax": year.ImpliedIncomeTax,
+                "net_cash_flow": year.NetCashFlow,
+                "cash_on_cash_return": year.CashOnCashReturn,
+            },
+        )
+    }
+    for _, year := range p.Years {
+        if year.LoanPayoff != 0 {
+            net_cash_flow_projection[year.Year]["loan_payoff"] = year.LoanPayoff
+        }
+    }
+    if p.Refinance != nil {
+        refinance := net_cash_flow_projection[p.Refinance.Year]
+        refinance["net_cash_flow"] = utils.Round2(refinance["net_cash_flow"].(float64) + p.Refinance.NetCashFlow)
+        refinance["refinance_loan_amount"] = p.Refinance.LoanAmount
+        refinance["refinance_net_cash_flow"] = p.Refinance.NetCashFlow
+    }
+    sale := net_cash_flow_projection[p.Sale.Year]
+    sale["net_cash_flow"] = utils.Round2(sale["net_cash_flow"].(float64) + p.Sale.NetCashFlow)
+    sale["sale_price"] = p.Sale.SalePrice
+    sale["depreciation_recapture_tax"] = p.Sale.DepreciationRecaptureTax
+    sale["capital_gains_tax"] = p.Sale.CapitalGainsTax
+    return net_cash_flow_projection
+}
+
+// loan_schedule has the yearly principal and interest payments, debt service,
+// rate cap payouts and payoff at the maturity of the loans of the projection.
+type loan_schedule struct {
+    ppmt                []float64
+    ipmt                []float64
+    debt_service        []float64
+    rate_cap_payouts    []float64
+    payoff              []float64
+}
+
+// new_loan_schedule returns the loan_schedule of the given loan for the given
+// number of years. If the loan matures before, its balance is paid off on the
+// last year of the term and there are no payments after it.
+func new_loan_schedule (loan ls.LoanSizer, years int) (loan_schedule, error) {
+    var schedule loan_schedule
+
+    ppmt, ipmt, err := loan.AnnualPaymentDistribution()
+    if err != nil {
+        return schedule, fmt.Errorf("AnnualPaymentDistribution internal error: %v", err)
+    }
+    debt_service, err := loan.AnnualDebtService()
+    if err != nil {
+        return schedule, fmt.Errorf("AnnualDebtService internal error: %v", err)
+    }
+    rate_cap_payouts, err := loan.AnnualRateCapPayouts()
+    if err != nil {
+        return schedule, fmt.Errorf("AnnualRateCapPayouts internal error: %v", err)
+    }
+    payoff := make([]float64, loan.Term)
+    if years > loan.Term {
+        balloon, err := loan.EndofTermBalloonPayment()
+        if err != nil {
+            return schedule, fmt.Errorf("EndofTermBalloonPayment internal error: %v", err)
+        }
+        payoff[loan.Term - 1] = - balloon
+        after_term := make([]float64, years - loan.Term)
+        ppmt = append(ppmt, after_term...)
+        ipmt = append(ipmt, after_term...)
+        debt_service = append(debt_service, after_term...)
+        rate_cap_payouts = append(rate_cap_payouts, after_term...)
+        payoff = append(payoff, after_term...)
+    }
+    schedule = loan_schedule{
+        ppmt: ppmt[:years],
+        ipmt: ipmt[:years],
+        debt_service: debt_service[:years],
+        rate_cap_payouts: rate_cap_payouts[:years],
+        payoff: payoff[:years],
+    }
+    return schedule, nil
+}
+
+// append returns the loan_schedule followed by the given one.
+func (schedule loan_schedule) append (next loan_schedule) loan_schedule {
+    return loan_schedule{
+        ppmt: append(schedule.ppmt[:len(schedule.ppmt):len(schedule.ppmt)], next.ppmt...),
+        ipmt: append(schedule.ipmt[:len(schedule.ipmt):len(schedule.ipmt)], next.ipmt...),
+        debt_service: append(schedule.debt_service[:len(schedule.debt_service):len(schedule.debt_service)], next.debt_service...),
+        rate_cap_payouts: append(schedule.rate_cap_payouts[:len(schedule.rate_cap_payouts):len(schedule.rate_cap_payouts)], next.rate_cap_payouts...),
+        payoff: append(schedule.payoff[:len(schedule.payoff):len(schedule.payoff)], next.payoff...),
+    }
+}
+
+// Projection returns the typed net cash flow projection of the Deal.
+func (roi ReturnOfInvestment) Projection () (Projection, error) {
+    var projection Projection
+
+    mla, err := roi.loanMetrics.MaximumLoanAmount()
+    if err != nil {
+        return projection, fmt.Errorf("MaximumLoanAmount internal error: %v", err)
+    }
+    rate_cap_premium, err := roi.loanMetrics.RateCapPremium()
+    if err != nil {
+        return projection, fmt.Errorf("RateCapPremium internal error: %v", err)
+    }
+    adquisition_cost, err := roi.AdquisitionCost()
+    if err != nil {
+        return projection, fmt.Errorf("AdquisitionCost internal error: %v", err)
+    }
+    projection.Acquisition = AcquisitionRow{
+        PurchasePrice: - float64(roi.dealMetrics.PurchasePrice),
+        ClosingAndRenovations: float64(roi.dealMetrics.ClosingAndRenovations),
+        LoanAmount: mla,
+        LoanOriginationFees: - roi.loan_origination_fees(mla),
+        RateCapPremium: - rate_cap_premium,
+        TrancheFunding: roi.capitalStack.Amount(),
+        CapexHoldback: - roi.CapexHoldback(),
+        NetCashFlow: adquisition_cost,
+    }
+
+    // gross potential revenue of the year, the effective gross revenue comes
+    // from it through the operating statement.
+    revenue := roi.dealMetrics.InitRevenue
+    // revenue of every year from the rent roll, up to the year after the
+    // sale, and the tenant improvements and leasing commissions of its
+    // rollover, amortized in the income tax and added to the cost of the
+    // project for the capital gains.
+    var rent_roll_revenue []float64
+    tenant_improvements := make([]float64, roi.saleMetrics.SaleYear)
+    leasing_commissions := make([]float64, roi.saleMetrics.SaleYear)
+    leasing_amortization := make([]float64, roi.saleMetrics.SaleYear)
+    leasing_basis := 0.0
+    // downtime loss of the rollover of every year, part of the general
+    // vacancy of the operating statement.
+    downtime := roi.downtime_loss(roi.saleMetrics.SaleYear + 1)
+    if roi.rentRoll != nil {
+        rent_roll_revenue = roi.rentRoll.Revenue(roi.saleMetrics.SaleYear + 1)
+        revenue = rent_roll_revenue[0]
+        tenant_improvements, leasing_commissions = roi.rentRoll.LeasingCosts(roi.saleMetrics.SaleYear)
+        leasing_amortization = roi.rentRoll.LeasingCostsAmortization(roi.saleMetrics.SaleYear)
+        for i := range tenant_improvements {
+            leasing_basis -= tenant_improvements[i] + leasing_commissions[i]
+        }
+        leasing_basis = utils.Round2(leasing_basis)
+    }
+    expense := roi.dealMetrics.InitOperatingExpenses
+    reserve := roi.dealMetrics.InitCapitalReserves
+
+    // getting the building value
+    purchase_price := roi.dealMetrics.PurchasePrice
+    building_value := utils.Round2(float64(purchase_price) * (1.0 - roi.taxMetrics.LanBuildingValue))
+
+    // depreciation of the building
+    building_depreciation := utils.Round2(- building_value/float64(roi.taxMetrics.FixDepreciationTimeLine))
+
+    // loan outstanding at the sale, and the year of the projection on which
+    // it started.
+    loan := roi.loanMetrics
+    loan_start_year := 0
+
+    // yearly payments of the loan up to the sale, or up to the refinance.
+    loan_years := roi.saleMetrics.SaleYear
+    if roi.refinance != nil && roi.refinance.Year < loan_years {
+        loan_years = roi.refinance.Year
+    }
+    schedule, err := new_loan_schedule(loan, loan_years)
+    if err != nil {
+        return projection, fmt.Errorf("new_loan_schedule internal error: %v", err)
+    }
+
+    // balances of the tranches of the capital stack
+    tranches := roi.capitalStack.new_tranche_ledger()
+
+    // capex of every year, and the balance of the draws on the loan to pay
+    // for it.
+    capex := roi.capex_years()
+    draws := 0.0
+    if roi.refinance != nil {
+        for i := roi.refinance.Year; i < roi.saleMetrics.SaleYear; i++ {
+            if capex.holdback[i] != 0 {
+                return projection, fmt.Errorf("The capex funded by the holdback must be spent up to the refinance.")
+            }
+        }
+    }
+
+    // Iterating over the term and appending the values to the projection.
+    for i := 0; i < roi.saleMetrics.SaleYear; i++ {
+        // this year NOI
+        revenue_lines := roi.revenue_lines(i, revenue, downtime[i])
+        // the expense of the items of the operating expenses, if the deal has
+        // them.
+        if roi.operatingExpenses != nil {
+            var expense_lines []ExpenseLine
+            expense_lines, expense = roi.operating_expenses(i, revenue_lines)
+            projection.Expenses = append(projection.Expenses, expense_lines...)
+        }
+        current_noi := utils.Round2(revenue_lines.effective + expense)
+        // the refinance happens at the end of the previous year, sized with
+        // the NOI of this year, and the new loan pays from this year on.
+        if roi.refinance != nil && i == roi.refinance.Year {
+            refinance, new_loan, err := roi.refinance_event(current_noi)
+            if err != nil {
+                return projection, fmt.Errorf("refinance_event internal error: %v", err)
+            }
+            // the draws on the old loan are paid off with it.
+            refinance.LoanPayoff = utils.Round2(refinance.LoanPayoff - draws)
+            refinance.NetCashFlow = utils.Round2(refinance.NetCashFlow - draws)
+            draws = 0
+            projection.Refinance = &refinance
+            loan = new_loan
+            loan_start_year = i
+            new_schedule, err := new_loan_schedule(loan, roi.saleMetrics.SaleYear - i)
+            if err != nil {
+                return projection, fmt.Errorf("new_loan_schedule internal error: %v", err)
+            }
+            schedule = schedule.append(new_schedule)
+        }
+        // this year interest and principal payments
+        current_ppmt := schedule.ppmt[i]
+        current_ipmt := schedule.ipmt[i]
+        current_pmt := schedule.debt_service[i]
+        current_rate_cap_payout := schedule.rate_cap_payouts[i]
+        current_payoff := schedule.payoff[i]
+        // the draws of the year are taken at its start and pay interest only
+        // at the rate of the loan.
+        if capex.draws[i] != 0 && i >= loan_start_year + loan.Term {
+            return projection, fmt.Errorf("The capex funded by the loan must be spent within the term of the loan.")
+        }
+        draws = utils.Round2(draws + capex.draws[i])
+        if draws != 0 {
+            draws_interest := utils.Round2(- draws * annual_rate(loan, i - loan_start_year))
+            current_ipmt = utils.Round2(current_ipmt + draws_interest)
+            current_pmt = utils.Round2(current_pmt + draws_interest)
+        }
+        // the draws are paid off with the loan at its maturity, if it matures
+        // before the refinance or the sale.
+        loan_end_year := roi.saleMetrics.SaleYear
+        if roi.refinance != nil && i < roi.refinance.Year {
+            loan_end_year = roi.refinance.Year
+        }
+        if i == loan_start_year + loan.Term - 1 && loan_end_year > i + 1 {
+            current_payoff = utils.Round2(current_payoff - draws)
+            draws = 0
+        }
+        capex_funding := utils.Round2(capex.holdback[i] + capex.draws[i])
+        // cashflow after debt service, with the payouts of the rate cap
+        // paying back part of the interest and the leasing costs paid below
+        // the NOI.
+        cfads := utils.Round2(current_noi + reserve + tenant_improvements[i] + leasing_commissions[i] + capex.capital_expenditures[i] + capex_funding + current_pmt + current_payoff + current_rate_cap_payout)
+        // the tranches of the capital stack are paid, in order, with the
+        // cashflow left after the senior loan.
+        tranche_payments, tranche_interest := tranches.pay_year(cfads)
+        cfads = utils.Round2(cfads + tranche_payments)
+        // depreciation expense, of the building, of the capex and of the
+        // leasing costs
+        depreciation_expense := utils.Round2(capex.depreciation[i] + leasing_amortization[i])
+        if i < roi.taxMetrics.FixDepreciationTimeLine {
+            depreciation_expense = utils.Round2(depreciation_expense + building_depreciation)
+        }
+        // income tax
+        income_tax := utils.Round2(- (current_noi + current_ipmt + current_rate_cap_payout + tranche_interest + depreciation_expense) * roi.taxMetrics.IncomeTaxRate)
+        implied_income_tax := utils.Round4(math.Abs(income_tax/cfads))
+        // net cashflow
+        ncf := utils.Round2(cfads + income_tax)
+        // cash on cash return
+        cocr, err := roi.CashOnCashReturn(ncf)
+        if err != nil {
+            return projection, fmt.Errorf("CashOnCashReturn internal error: %v", err)
+        }
+
+        projection.Years = append(
+            projection.Years,
+            CashFlowYear{
+                Year: i + 1,
+                GrossPotentialRevenue: revenue,
+                DowntimeLoss: revenue_lines.downtime,
+                Vacancy: revenue_lines.vacancy,
+                CreditLoss: revenue_lines.credit_loss,
+                Concessions: revenue_lines.concessions,
+                Revenue: revenue_lines.effective,
+                Expense: expense,
+                NOI: current_noi,
+                Reserve: reserve,
+                TenantImprovements: tenant_improvements[i],
+                LeasingCommissions: leasing_commissions[i],
+                CapitalExpenditures: capex.capital_expenditures[i],
+                CapexFunding: capex_funding,
+                PrincipalPayment: current_ppmt,
+                InterestPayment: current_ipmt,
+                AnnualDebtService: current_pmt,
+                LoanPayoff: current_payoff,
+                RateCapPayout: current_rate_cap_payout,
+                TranchePayments: tranche_payments,
+                CashFlowAfterDebtService: cfads,
+                DepreciationExpense: depreciation_expense,
+                IncomeTax: income_tax,
+                ImpliedIncomeTax: implied_income_tax,
+                NetCashFlow: ncf,
+                CashOnCashReturn: cocr,
+            },
+        )
+        revenue_growth, expense_growth, reserve_growth := roi.dealMetrics.growth(i)
+        revenue = utils.Round2(revenue + revenue * revenue_growth)
+        if rent_roll_revenue != nil {
+            revenue = rent_roll_revenue[i + 1]
+        }
+        expense = utils.Round2(expense + expense * expense_growth)
+        reserve = utils.Round2(reserve + reserve * reserve_growth)
+    }
+    after_term_lines := roi.revenue_lines(roi.saleMetrics.SaleYear, revenue, downtime[roi.saleMetrics.SaleYear])
+    after_term_noi := utils.Round2(after_term_lines.effective + expense)
+    // the items of the operating expenses, with the ones reassessed at the
+    // sale charged on the sale price.
+    if roi.operatingExpenses != nil {
+        after_term_noi = roi.reassessed_noi(after_term_lines)
+    }
+
+    // BalloonPayment at the year of sale, with the draws for the capex, there
+    // is none if the loan was paid off at its maturity.
+    balloonpayment := 0.0
+    if roi.saleMetrics.SaleYear - loan_start_year <= loan.Term {
+        balloonpayment, err = loan.SaleYearBalloonPayment(roi.saleMetrics.SaleYear - loan_start_year)
+        if err != nil {
+            return projection, fmt.Errorf("BalloonPayment internal error: %v", err)
+        }
+    }
+    balloonpayment = utils.Round2(balloonpayment + draws)
+
+    // Prepayment penalty if the sale happens before the end of the term
+    prepayment_penalty, err := loan.PrepaymentPenalty(roi.saleMetrics.SaleYear - loan_start_year)
+    if err != nil {
+        return projection, fmt.Errorf("PrepaymentPenalty internal error: %v", err)
+    }
+
+    // Adding the cashflow after the sell of the property
+    // sale with the projected NOI
+    projected_sale_price := roi.saleMetrics.ProjectedSalePrice(after_term_noi)
+    // capital gains tax, over the gain on the cost of the project, the capex
+    // and the leasing costs spent. There is no tax on a loss.
+    cg := utils.Round2(projected_sale_price - float64(roi.dealMetrics.ProjectCost()) - capex.basis - leasing_basis)
+    cgt := utils.Round2(- math.Max(cg, 0) * roi.taxMetrics.CapitalGainsTaxRate)
+    // Depreciation Recapture tax, over the depreciation taken up to the sale.
+    depreciation_years := roi.saleMetrics.SaleYear
+    if depreciation_years > roi.taxMetrics.FixDepreciationTimeLine {
+        depreciation_years = roi.taxMetrics.FixDepreciationTimeLine
+    }
+    improvements_depreciation := 0.0
+    for i := range capex.depreciation {
+        improvements_depreciation += capex.depreciation[i] + leasing_amortization[i]
+    }
+    // Only the depreciation covered by the gain over the adjusted basis, the
+    // cost less the depreciation taken, is recaptured, none on a sale below
+    // the adjusted basis.
+    depreciation := - (building_depreciation * float64(depreciation_years) + improvements_depreciation)
+    recaptured := math.Min(depreciation, cg + depreciation)
+    drt := 0.0
+    if recaptured > 0 {
+        drt = utils.Round2(- recaptured * roi.taxMetrics.DepreciationRecaptureTaxRate)
+    }
+    // The tranches of the capital stack are paid off, in order, with what is
+    // left of the sale after the senior loan.
+    tranche_repayment := tranches.repay(projected_sale_price - balloonpayment - prepayment_penalty)
+    // Sale calculations, booked on the row of the year of sale.
+    sale_net_cash_flow := projected_sale_price +
+        drt +
+        cgt -
+        balloonpayment -
+        prepayment_penalty +
+        tranche_repayment
+    projection.Sale = SaleEvent{
+        Year: roi.saleMetrics.SaleYear,
+        SalePrice: projected_sale_price,
+        DepreciationRecaptureTax: drt,
+        CapitalGainsTax: cgt,
+        BalloonPayment: - balloonpayment,
+        PrepaymentPenalty: - prepayment_penalty,
+        TrancheRepayment: tranche_repayment,
+        NetCashFlow: utils.Round2(sale_net_cash_flow),
+    }
+    return projection, nil
+}
@@ -0,0 +1,321 @@
+package investment_analysis
+import (
+    "encoding/json";
+    "testing";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+    ls "github.com/jacobitosuperstar/go-cre-loan-calculations/loan_sizer";
+)
+
+func TestProjection(t *testing.T) {
+    roi, err := newTestROI(baseTestROI)
+    if err != nil {
+        t.Errorf("ReturnOfInvestment internal error: %v", err)
+        return
+    }
+    projection, err := roi.Projection()
+    if err != nil {
+        t.Errorf("Projection internal error: %v", err)
+        return
+    }
+
+    t.Run("Typed rows", func(t *testing.T) {
+        if projection.Acquisition.NetCashFlow != -2220500.0 {
+            t.Errorf("Acquisition NetCashFlow got: %v, wanted: %v", projection.Acquisition.NetCashFlow, -2220500.0)
+        }
+        if len(projection.Years) != baseTestROI.saleYear {
+            t.Errorf("Years got: %v rows, wanted: %v", len(projection.Years), baseTestROI.saleYear)
+            return
+        }
+        want := CashFlowYear{
+            Year: 1,
+            GrossPotentialRevenue: 687500.0,
+            Revenue: 687500.0,
+            Expense: -300000.0,
+            NOI: 387500.0,
+            Reserve: 7500.0,
+            PrincipalPayment: 0.0,
+            InterestPayment: -204750.0,
+            AnnualDebtService: -279331.52,
+            CashFlowAfterDebtService: 115668.48,
+            DepreciationExpense: -168518.52,
+            IncomeTax: -3557.87,
+            ImpliedIncomeTax: 0.0308,
+            NetCashFlow: 112110.61,
+            CashOnCashReturn: 0.0505,
+        }
+        if projection.Years[0] != want {
+            t.Errorf("Year 1 got: %+v, wanted: %+v", projection.Years[0], want)
+        }
+    })
+
+    t.Run("Map adapter", func(t *testing.T) {
+        cash_flows := projection.CashFlows()
+        net_cash_flow_projection := projection.ToMap()
+        if len(net_cash_flow_projection) != len(cash_flows) {
+            t.Errorf("ToMap got: %v rows, wanted: %v", len(net_cash_flow_projection), len(cash_flows))
+            return
+        }
+        for i, row := range net_cash_flow_projection {
+            if !utils.Tolerance(row["net_cash_flow"].(float64), cash_flows[i], 0.01) {
+                t.Errorf("net_cash_flow of row %v got: %v, wanted: %v", i, row["net_cash_flow"], cash_flows[i])
+            }
+        }
+        sale := net_cash_flow_projection[projection.Sale.Year]
+        if sale["sale_price"] != projection.Sale.SalePrice {
+            t.Errorf("sale_price got: %v, wanted: %v", sale["sale_price"], projection.Sale.SalePrice)
+        }
+    })
+
+    t.Run("JSON serialization", func(t *testing.T) {
+        data, err := json.Marshal(projection)
+        if err != nil {
+            t.Errorf("json.Marshal internal error: %v", err)
+            return
+        }
+        var decoded map[string]interface{}
+        if err := json.Unmarshal(data, &decoded); err != nil {
+            t.Errorf("json.Unmarshal internal error: %v", err)
+            return
+        }
+        for _, key := range []string{"acquisition", "years", "sale"} {
+            if _, ok := decoded[key]; !ok {
+                t.Errorf("key %v missing from the JSON projection", key)
+            }
+        }
+        year := decoded["years"].([]interface{})[0].(map[string]interface{})
+        if year["cashflow_after_debt_service"] != 115668.48 {
+            t.Errorf("cashflow_after_debt_service got: %v, wanted: %v", year["cashflow_after_debt_service"], 115668.48)
+        }
+    })
+}
+
+func TestFloatingRateProjection(t *testing.T) {
+    roi, err := newTestROI(baseTestROI)
+    if err != nil {
+        t.Errorf("ReturnOfInvestment internal error: %v", err)
+        return
+    }
+    fr := ls.FloatingRate{
+        ForwardCurve: []float64{0.040, 0.045, 0.050},
+        Spread: 0.02,
+        CapStrike: 0.05,
+    }
+    roi, err = roi.WithFloatingRate(fr)
+    if err != nil {
+        t.Errorf("WithFloatingRate internal error: %v", err)
+        return
+    }
+    projection, err := roi.Projection()
+    if err != nil {
+        t.Errorf("Projection internal error: %v", err)
+        return
+    }
+    mla := projection.Acquisition.LoanAmount
+    // The first two years are interest only, the interest follows the
+    // forward curve and the debt service is the interest alone.
+    for i := 0; i < baseTestROI.ioPeriod; i++ {
+        year := projection.Years[i]
+        want := utils.Round2(- mla * fr.Rate(i))
+        if year.InterestPayment != want {
+            t.Errorf("InterestPayment of year %v got: %v, wanted: %v", year.Year, year.InterestPayment, want)
+        }
+        cfads := utils.Round2(year.NOI + year.Reserve + want)
+        if year.CashFlowAfterDebtService != cfads {
+            t.Errorf("CashFlowAfterDebtService of year %v got: %v, wanted: %v", year.Year, year.CashFlowAfterDebtService, cfads)
+        }
+    }
+}
+
+func TestRateCapProjection(t *testing.T) {
+    roi, err := newTestROI(baseTestROI)
+    if err != nil {
+        t.Errorf("ReturnOfInvestment internal error: %v", err)
+        return
+    }
+    roi, err = roi.WithFloatingRate(ls.FloatingRate{
+        ForwardCurve: []float64{0.040, 0.050, 0.060},
+        Spread: 0.02,
+    })
+    if err != nil {
+        t.Errorf("WithFloatingRate internal error: %v", err)
+        return
+    }
+    uncapped, err := roi.Projection()
+    if err != nil {
+        t.Errorf("Projection internal error: %v", err)
+        return
+    }
+    roi, err = roi.WithRateCap(ls.RateCap{Strike: 0.05, Volatility: 0.25, Term: 3})
+    if err != nil {
+        t.Errorf("WithRateCap internal error: %v", err)
+        return
+    }
+    projection, err := roi.Projection()
+    if err != nil {
+        t.Errorf("Projection internal error: %v", err)
+        return
+    }
+
+    if projection.Acquisition.RateCapPremium >= 0 {
+        t.Errorf("RateCapPremium got: %v, wanted a payment", projection.Acquisition.RateCapPremium)
+    }
+    // The cap sizes the loan at the capped rate, so the acquisition changes
+    // by the premium and by the loan amount.
+    loan_difference := projection.Acquisition.LoanAmount - uncapped.Acquisition.LoanAmount
+    fees_difference := projection.Acquisition.LoanOriginationFees - uncapped.Acquisition.LoanOriginationFees
+    want := utils.Round2(uncapped.Acquisition.NetCashFlow + loan_difference + fees_difference + projection.Acquisition.RateCapPremium)
+    if !utils.Tolerance(projection.Acquisition.NetCashFlow, want, 0.02) {
+        t.Errorf("Acquisition NetCashFlow got: %v, wanted: %v", projection.Acquisition.NetCashFlow, want)
+    }
+
+    // The index is above the strike on the third year only, while the cap
+    // lasts.
+    mla := projection.Acquisition.LoanAmount
+    wantPayouts := []float64{0, 0, utils.Round2(mla * 0.01), 0}
+    for i, payout := range wantPayouts {
+        if projection.Years[i].RateCapPayout != payout {
+            t.Errorf("RateCapPayout of year %v got: %v, wanted: %v", i + 1, projection.Years[i].RateCapPayout, payout)
+        }
+    }
+    year := projection.Years[2]
+    cfads := utils.Round2(year.NOI + year.Reserve + year.InterestPayment + year.PrincipalPayment + year.RateCapPayout)
+    if year.CashFlowAfterDebtService != cfads {
+        t.Errorf("CashFlowAfterDebtService got: %v, wanted: %v", year.CashFlowAfterDebtService, cfads)
+    }
+}
+
+func TestSaleYear(t *testing.T) {
+    var testCases = []struct {
+        name string
+        term int
+        ioPeriod int
+        saleYear int
+        want SaleEvent
+    }{
+        {
+            name: "Sale at the end of the term",
+            term: 10, ioPeriod: 2, saleYear: 10,
+            want: SaleEvent{
+                SalePrice: 8786419.35,
+                DepreciationRecaptureTax: -421296.30,
+                CapitalGainsTax: -309212.90,
+                BalloonPayment: -3850424.34,
+                NetCashFlow: 4205485.81,
+            },
+        },
+        {
+            name: "Sale before the end of the term",
+            term: 10, ioPeriod: 2, saleYear: 5,
+            want: SaleEvent{
+                SalePrice: 7156677.90,
+                DepreciationRecaptureTax: -210648.15,
+                CapitalGainsTax: -64751.69,
+                BalloonPayment: -4316035.91,
+                NetCashFlow: 2565242.15,
+            },
+        },
+        {
+            // the gain over the adjusted basis recaptures only a part of
+            // the depreciation.
+            name: "Sale at a loss without IO period",
+            term: 10, ioPeriod: 0, saleYear: 3,
+            want: SaleEvent{
+                SalePrice: 6587645.25,
+                DepreciationRecaptureTax: -92050.2,
+                CapitalGainsTax: 0,
+                BalloonPayment: -4316035.91,
+                NetCashFlow: 2179559.14,
+            },
+        },
+        {
+            name: "Shorter term without IO period",
+            term: 7, ioPeriod: 0, saleYear: 7,
+            want: SaleEvent{
+                SalePrice: 7771293.90,
+                DepreciationRecaptureTax: -294907.41,
+                CapitalGainsTax: -156944.09,
+                BalloonPayment: -3951919.48,
+                NetCashFlow: 3367522.92,
+            },
+        },
+        {
+            name: "Shorter term with IO period",
+            term: 7, ioPeriod: 2, saleYear: 5,
+            want: SaleEvent{
+                SalePrice: 7156677.90,
+                DepreciationRecaptureTax: -210648.15,
+                CapitalGainsTax: -64751.69,
+                BalloonPayment: -4316035.91,
+                NetCashFlow: 2565242.15,
+            },
+        },
+        {
+            // the sale is below the adjusted basis, there is nothing to
+            // recapture.
+            name: "Sale after the first year",
+            term: 7, ioPeriod: 0, saleYear: 1,
+            want: SaleEvent{
+                SalePrice: 6060937.50,
+                DepreciationRecaptureTax: 0,
+                CapitalGainsTax: 0,
+                BalloonPayment: -4475418.48,
+                NetCashFlow: 1585519.02,
+            },
+        },
+    }
+
+    for _, test := range testCases {
+        t.Run(test.name, func(t *testing.T) {
+            input := baseTestROI
+            input.term = test.term
+            input.ioPeriod = test.ioPeriod
+            input.saleYear = test.saleYear
+            roi, err := newTestROI(input)
+            if err != nil {
+                t.Errorf("ReturnOfInvestment internal error: %v", err)
+                return
+            }
+            projection, err := roi.Projection()
+            if err != nil {
+                t.Errorf("Projection internal error: %v", err)
+                return
+            }
+            got := projection.Sale
+            if got.Year != test.saleYear || len(projection.Years) != test.saleYear {
+                t.Errorf("Sale got: year %v with %v rows, wanted: year %v", got.Year, len(projection.Years), test.saleYear)
+                return
+            }
+            var values = []struct {
+                name string
+                got float64
+                want float64
+            }{
+                {"SalePrice", got.SalePrice, test.want.SalePrice},
+                {"DepreciationRecaptureTax", got.DepreciationRecaptureTax, test.want.DepreciationRecaptureTax},
+                {"CapitalGainsTax", got.CapitalGainsTax, test.want.CapitalGainsTax},
+                {"BalloonPayment", got.BalloonPayment, test.want.BalloonPayment},
+                {"NetCashFlow", got.NetCashFlow, test.want.NetCashFlow},
+            }
+            for _, value := range values {
+                if !utils.Tolerance(value.got, value.want, 0.1) {
+                    t.Errorf("%s got: %v, wanted: %v", value.name, value.got, value.want)
+                }
+            }
+
+            // the sale is booked on the row of the year of sale.
+            net_cash_flow_projection, err := roi.NetCashFlowProjection()
+            if err != nil {
+                t.Errorf("NetCashFlowProjection internal error: %v", err)
+                return
+            }
+            sale_row := net_cash_flow_projection[test.saleYear]
+            if sale_row["year"] != test.saleYear || sale_row["sale_price"] != got.SalePrice {
+                t.Errorf("sale row got: year %v with sale_price %v, wanted: year %v", sale_row["year"], sale_row["sale_price"], test.saleYear)
+            }
+            want_net_cash_flow := utils.Round2(projection.Years[test.saleYear - 1].NetCashFlow + got.NetCashFlow)
+            if sale_row["net_cash_flow"] != want_net_cash_flow {
+                t.Errorf("sale row net_cash_flow got: %v, wanted: %v", sale_row["net_cash_flow"], want_net_cash_flow)
+            }
+        })
+    }
+}
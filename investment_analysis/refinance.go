@@ -0,0 +1,156 @@
+// Refinance of the property during the hold period. The loan of the deal is
+// paid off with a new loan, sized on the projected NOI and value at the time
+// of the refinance, and the projection goes on with the new debt service.
+
+package investment_analysis
+
+import (
+    "fmt";
+    "math";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+    ls "github.com/jacobitosuperstar/go-cre-loan-calculations/loan_sizer";
+)
+
+// Refinance is a struct with the terms of the refinance of the property.
+//
+// The refinance happens at the end of the given Year. The property is valued
+// with the NOI of the following year over the CapRate, and the new Loan is
+// sized on that value and NOI, the PropertyValue and NOI of the Loan are not
+// used. The ClosingCosts are the fees of the refinance besides the loan
+// origination fees of the new Loan.
+type Refinance struct {
+    Year            int
+    CapRate         float64
+    Loan            ls.LoanSizer
+    ClosingCosts    float64
+}
+
+// NewRefinance returns a Refinance struct if the values given are valid. If
+// not, returns a default struct with the error.
+func NewRefinance(
+    year int,
+    capRate float64,
+    loan ls.LoanSizer,
+    closingCosts float64,
+) (
+    Refinance,
+    error,
+) {
+    // Data Validation
+    if year <= 0 {
+        return Refinance{}, fmt.Errorf("The year of the refinance must be greater than 0.")
+    }
+    if capRate <= 0 || capRate > 1 {
+        return Refinance{}, fmt.Errorf("The capRate of the refinance must be between 0 and 1.")
+    }
+    if loan.Term <= 0 {
+        return Refinance{}, fmt.Errorf("The new loan must have a term greater than 0.")
+    }
+    if closingCosts < 0 {
+        return Refinance{}, fmt.Errorf("The closingCosts cannot be lower than 0.")
+    }
+    // Struct Creation
+    refinance := Refinance{
+        Year: year,
+        CapRate: capRate,
+        Loan: loan,
+        ClosingCosts: closingCosts,
+    }
+    return refinance, nil
+}
+
+// WithRefinance returns a copy of the ReturnOfInvestment with the loan of the
+// deal refinanced. The refinance must happen before the sale and within the
+// term of the current loan, and the new loan must last up to the sale.
+func (roi ReturnOfInvestment) WithRefinance (refinance Refinance) (ReturnOfInvestment, error) {
+    validated, err := NewRefinance(
+        refinance.Year,
+        refinance.CapRate,
+        refinance.Loan,
+        refinance.ClosingCosts,
+    )
+    if err != nil {
+        return roi, fmt.Errorf("NewRefinance internal error: %v", err)
+    }
+    if validated.Year >= roi.saleMetrics.SaleYear {
+        return roi, fmt.Errorf("The refinance must happen before the year of sale.")
+    }
+    if validated.Year > roi.loanMetrics.Term {
+        return roi, fmt.Errorf("The refinance cannot happen after the term of the loan.")
+    }
+    if roi.saleMetrics.SaleYear - validated.Year > validated.Loan.Term {
+        return roi, fmt.Errorf("The term of the new loan must last up to the year of sale.")
+    }
+    roi.refinance = &validated
+    return roi, nil
+}
+
+// RefinanceEvent is the refinance of the property within the projection. The
+// NetCashFlow is the cash out of the refinance, the new loan minus the payoff
+// of the old loan and the costs of the refinance.
+type RefinanceEvent struct {
+    Year                int     `json:"year"`
+    NOI                 float64 `json:"noi"`
+    PropertyValue       float64 `json:"property_value"`
+    LoanAmount          float64 `json:"loan_amount"`
+    LoanPayoff          float64 `json:"loan_payoff"`
+    PrepaymentPenalty   float64 `json:"prepayment_penalty"`
+    LoanOriginationFees float64 `json:"loan_origination_fees"`
+    RateCapPremium      float64 `json:"rate_cap_premium"`
+    ClosingCosts        float64 `json:"closing_costs"`
+    NetCashFlow         float64 `json:"net_cash_flow"`
+}
+
+// refinance_loan returns the new loan of the refinance, sized with the given
+// NOI and the value of the property at the cap rate of the refinance.
+func (refinance Refinance) refinance_loan (noi float64) ls.LoanSizer {
+    loan := refinance.Loan
+    loan.PropertyValue = int(math.Floor(noi / refinance.CapRate))
+    loan.NOI = noi
+    if loan.RequestedLoanAmount == 0 {
+        loan.RequestedLoanAmount = loan.PropertyValue
+    }
+    if loan.ProjectCost == 0 {
+        loan.ProjectCost = loan.PropertyValue
+    }
+    return loan
+}
+
+// refinance_event returns the RefinanceEvent of the deal, with the new loan,
+// given the NOI of the year after the refinance.
+func (roi ReturnOfInvestment) refinance_event (noi float64) (RefinanceEvent, ls.LoanSizer, error) {
+    var event RefinanceEvent
+
+    loan := roi.refinance.refinance_loan(noi)
+    mla, err := loan.MaximumLoanAmount()
+    if err != nil {
+        return event, loan, fmt.Errorf("MaximumLoanAmount internal error: %v", err)
+    }
+    payoff, err := roi.loanMetrics.SaleYearBalloonPayment(roi.refinance.Year)
+    if err != nil {
+        return event, loan, fmt.Errorf("SaleYearBalloonPayment internal error: %v", err)
+    }
+    prepayment_penalty, err := roi.loanMetrics.PrepaymentPenalty(roi.refinance.Year)
+    if err != nil {
+        return event, loan, fmt.Errorf("PrepaymentPenalty internal error: %v", err)
+    }
+    origination_fees := utils.Round2(loan.LoanOriginationFees * mla)
+    rate_cap_premium, err := loan.RateCapPremium()
+    if err != nil {
+        return event, loan, fmt.Errorf("RateCapPremium internal error: %v", err)
+    }
+
+    event = RefinanceEvent{
+        Year: roi.refinance.Year,
+        NOI: noi,
+        PropertyValue: float64(loan.PropertyValue),
+        LoanAmount: mla,
+        LoanPayoff: - payoff,
+        PrepaymentPenalty: - prepayment_penalty,
+        LoanOriginationFees: - origination_fees,
+        RateCapPremium: - rate_cap_premium,
+        ClosingCosts: - roi.refinance.ClosingCosts,
+        NetCashFlow: utils.Round2(mla - payoff - prepayment_penalty - origination_fees - rate_cap_premium - roi.refinance.ClosingCosts),
+    }
+    return event, loan, nil
+}
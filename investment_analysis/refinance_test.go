@@ -0,0 +1,137 @@
+package investment_analysis
+import (
+    "testing";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+    ls "github.com/jacobitosuperstar/go-cre-loan-calculations/loan_sizer";
+)
+
+func TestRefinance(t *testing.T) {
+    roi, err := newTestROI(baseTestROI)
+    if err != nil {
+        t.Errorf("ReturnOfInvestment internal error: %v", err)
+        return
+    }
+    loan, err := ls.NewLoanSizer(0.75, 1.25, 30, 7, 0, 0.05, 0, 0, 0, 0.01)
+    if err != nil {
+        t.Errorf("NewLoanSizer internal error: %v", err)
+        return
+    }
+    refinance, err := NewRefinance(3, 0.065, loan, 25000)
+    if err != nil {
+        t.Errorf("NewRefinance internal error: %v", err)
+        return
+    }
+    roi, err = roi.WithRefinance(refinance)
+    if err != nil {
+        t.Errorf("WithRefinance internal error: %v", err)
+        return
+    }
+    projection, err := roi.Projection()
+    if err != nil {
+        t.Errorf("Projection internal error: %v", err)
+        return
+    }
+    if projection.Refinance == nil {
+        t.Errorf("Refinance got: nil, wanted a refinance event")
+        return
+    }
+    event := *projection.Refinance
+
+    var testCases = []struct {
+        name string
+        got float64
+        want float64
+    }{
+        // the NOI of the year 4 over the cap rate.
+        {"NOI", event.NOI, 439176.35},
+        {"PropertyValue", event.PropertyValue, 6756559},
+        // 75% LTV of the refinance value.
+        {"LoanAmount", event.LoanAmount, 5067419},
+        {"LoanPayoff", event.LoanPayoff, -4475418.48},
+        {"LoanOriginationFees", event.LoanOriginationFees, -50674.19},
+        {"ClosingCosts", event.ClosingCosts, -25000},
+        {"NetCashFlow", event.NetCashFlow, 516326.33},
+        // the first year of the new loan pays interest on its whole amount.
+        {"Year 4 InterestPayment", projection.Years[3].InterestPayment, -5067419 * 0.05},
+        {"Year 3 CashFlows", projection.CashFlows()[3], projection.Years[2].NetCashFlow + event.NetCashFlow},
+    }
+    for _, test := range testCases {
+        t.Run(test.name, func(t *testing.T) {
+            if !utils.Tolerance(test.got, test.want, 0.1) {
+                t.Errorf("%s got: %g, wanted: %g", test.name, test.got, test.want)
+            }
+        })
+    }
+
+    t.Run("Rate cap of the new loan", func(t *testing.T) {
+        floating, err := loan.WithFloatingRate(ls.FloatingRate{
+            ForwardCurve: []float64{0.030, 0.035, 0.040},
+            Spread: 0.02,
+        })
+        if err != nil {
+            t.Errorf("WithFloatingRate internal error: %v", err)
+            return
+        }
+        capped, err := floating.WithRateCap(ls.RateCap{Strike: 0.035, Volatility: 0.25, Term: 3})
+        if err != nil {
+            t.Errorf("WithRateCap internal error: %v", err)
+            return
+        }
+        capped_refinance, err := NewRefinance(3, 0.065, capped, 25000)
+        if err != nil {
+            t.Errorf("NewRefinance internal error: %v", err)
+            return
+        }
+        capped_roi, err := roi.WithRefinance(capped_refinance)
+        if err != nil {
+            t.Errorf("WithRefinance internal error: %v", err)
+            return
+        }
+        projection, err := capped_roi.Projection()
+        if err != nil {
+            t.Errorf("Projection internal error: %v", err)
+            return
+        }
+        got := *projection.Refinance
+        premium, err := capped_refinance.refinance_loan(got.NOI).RateCapPremium()
+        if err != nil {
+            t.Errorf("RateCapPremium internal error: %v", err)
+            return
+        }
+        if premium <= 0 || got.RateCapPremium != - premium {
+            t.Errorf("RateCapPremium got: %v, wanted: %v", got.RateCapPremium, - premium)
+        }
+        // the premium is paid out of the cash out of the refinance.
+        want := utils.Round2(got.LoanAmount + got.LoanPayoff + got.PrepaymentPenalty + got.LoanOriginationFees + got.RateCapPremium + got.ClosingCosts)
+        if !utils.Tolerance(got.NetCashFlow, want, 0.02) {
+            t.Errorf("NetCashFlow got: %v, wanted: %v", got.NetCashFlow, want)
+        }
+        if event.RateCapPremium != 0 {
+            t.Errorf("RateCapPremium of a fixed rate loan got: %v, wanted: 0", event.RateCapPremium)
+        }
+    })
+
+    t.Run("Invalid refinance", func(t *testing.T) {
+        late, err := NewRefinance(10, 0.065, loan, 0)
+        if err != nil {
+            t.Errorf("NewRefinance internal error: %v", err)
+            return
+        }
+        if _, err := roi.WithRefinance(late); err == nil {
+            t.Errorf("expected an error for a refinance at the year of sale")
+        }
+        short_loan := loan
+        short_loan.Term = 5
+        short, err := NewRefinance(3, 0.065, short_loan, 0)
+        if err != nil {
+            t.Errorf("NewRefinance internal error: %v", err)
+            return
+        }
+        if _, err := roi.WithRefinance(short); err == nil {
+            t.Errorf("expected an error for a new loan that ends before the sale")
+        }
+        if _, err := NewRefinance(3, 0, loan, 0); err == nil {
+            t.Errorf("expected an error for a zero cap rate")
+        }
+    })
+}
@@ -0,0 +1,339 @@
+// Rent roll of the property. The revenue of the deal is built lease by lease,
+// with the contractual escalations, the free rent and the chance of renewal
+// of every lease, instead of growing the initial revenue at a flat rate.
+
+package investment_analysis
+
+import (
+    "fmt";
+    "math";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+)
+
+// Lease is a lease of the rent roll.
+//
+// The months are counted from the adquisition, the month 1 is the first month
+// of the projection, so a lease in place at the adquisition has a StartMonth
+// of 0 or lower. The ExpiryMonth is the last month of the lease. The Rent is
+// the annual rent per square foot at the start of the lease, and it rises by
+// the Escalation on every anniversary of the lease. The FreeRentMonths are
+// the first months of the lease without rent. At the expiry, the lease is
+// renewed with the RenewalProbability for another term of the same length,
+// with the escalations going on, and so on at every expiry. If the rent roll
+// has a MarketLeasingProfile for the lease, it is rolled over with the market
+// leasing assumptions of the profile instead.
+type Lease struct {
+    Tenant              string
+    Suite               string
+    SquareFeet          float64
+    StartMonth          int
+    ExpiryMonth         int
+    Rent                float64
+    Escalation          float64
+    FreeRentMonths      int
+    RenewalProbability  float64
+}
+
+// NewLease returns a Lease struct if the values given are valid. If not,
+// returns a default struct with the error.
+func NewLease(
+    tenant string,
+    suite string,
+    squareFeet float64,
+    startMonth int,
+    expiryMonth int,
+    rent float64,
+    escalation float64,
+    freeRentMonths int,
+    renewalProbability float64,
+) (
+    Lease,
+    error,
+) {
+    // Data Validation
+    if squareFeet <= 0 {
+        return Lease{}, fmt.Errorf("The squareFeet of the lease must be greater than 0.")
+    }
+    if expiryMonth < startMonth {
+        return Lease{}, fmt.Errorf("The expiryMonth of the lease cannot be before the startMonth.")
+    }
+    if rent < 0 {
+        return Lease{}, fmt.Errorf("The rent of the lease cannot be lower than 0.")
+    }
+    if escalation <= -1 {
+        return Lease{}, fmt.Errorf("The escalation of the lease must be greater than -1.")
+    }
+    if freeRentMonths < 0 || freeRentMonths > expiryMonth - startMonth + 1 {
+        return Lease{}, fmt.Errorf("The freeRentMonths must be between 0 and the months of the lease.")
+    }
+    if renewalProbability < 0 || renewalProbability > 1 {
+        return Lease{}, fmt.Errorf("The renewalProbability must be between 0 and 1.")
+    }
+    // Struct Creation
+    lease := Lease{
+        Tenant: tenant,
+        Suite: suite,
+        SquareFeet: squareFeet,
+        StartMonth: startMonth,
+        ExpiryMonth: expiryMonth,
+        Rent: rent,
+        Escalation: escalation,
+        FreeRentMonths: freeRentMonths,
+        RenewalProbability: renewalProbability,
+    }
+    return lease, nil
+}
+
+// term returns the months of the lease.
+func (lease Lease) term () int {
+    return lease.ExpiryMonth - lease.StartMonth + 1
+}
+
+// monthly_rent returns the contractual rent of the given month, before the
+// free rent, with the escalations of every anniversary since the start of
+// the lease.
+func (lease Lease) monthly_rent (month int) float64 {
+    lease_year := (month - lease.StartMonth) / 12
+    return lease.SquareFeet * lease.Rent * math.Pow(1 + lease.Escalation, float64(lease_year)) / 12
+}
+
+// RentRoll is the list of the leases of the property. The leases with a
+// MarketLeasingProfile, set with WithRollover or WithLeaseRollover, are
+// rolled over with it at their expiry.
+type RentRoll struct {
+    Leases      []Lease
+    rollovers   map[int]MarketLeasingProfile
+}
+
+// NewRentRoll returns a RentRoll struct if the leases given are valid. Two
+// leases of the same suite cannot overlap.
+func NewRentRoll(leases ...Lease) (RentRoll, error) {
+    validated := make([]Lease, len(leases))
+    for i, lease := range leases {
+        l, err := NewLease(
+            lease.Tenant,
+            lease.Suite,
+            lease.SquareFeet,
+            lease.StartMonth,
+            lease.ExpiryMonth,
+            lease.Rent,
+            lease.Escalation,
+            lease.FreeRentMonths,
+            lease.RenewalProbability,
+        )
+        if err != nil {
+            return RentRoll{}, fmt.Errorf("NewLease internal error: %v", err)
+        }
+        for _, previous := range validated[:i] {
+            if previous.Suite == l.Suite && previous.StartMonth <= l.ExpiryMonth && l.StartMonth <= previous.ExpiryMonth {
+                return RentRoll{}, fmt.Errorf("The leases of the suite %v overlap.", l.Suite)
+            }
+        }
+        validated[i] = l
+    }
+    return RentRoll{Leases: validated}, nil
+}
+
+// validated returns the RentRoll checked by its constructor, with the
+// MarketLeasingProfile of its leases.
+func (rr RentRoll) validated () (RentRoll, error) {
+    validated, err := NewRentRoll(rr.Leases...)
+    if err != nil {
+        return rr, fmt.Errorf("NewRentRoll internal error: %v", err)
+    }
+    for lease, profile := range rr.rollovers {
+        validated, err = validated.WithLeaseRollover(lease, profile)
+        if err != nil {
+            return rr, fmt.Errorf("WithLeaseRollover internal error: %v", err)
+        }
+    }
+    return validated, nil
+}
+
+// LeaseCashFlow is the revenue of a lease on a year of the projection.
+//
+// The ContractRent is the rent of the lease term, FreeRent is the rent given
+// away on it (negative) and RenewalRent is the rent of the renewals, weighted
+// by the chance of the lease being renewed up to then.
+//
+// If the lease has a MarketLeasingProfile, there are no renewals on the same
+// terms. The RolloverRent is the expected rent of the space after the expiry,
+// with the space at the market rent during the downtime, and the
+// DowntimeLoss and RolloverFreeRent are the expected rent lost to the
+// downtime and to the free rent of the leases of the rollover (negative). The
+// TenantImprovements and LeasingCommissions (negative) are the expected
+// capital costs of the rollover, paid on the year on which each lease starts,
+// and the LeasingCostsAmortization (negative) is the part of them amortized
+// on the year, straight line over the term of each lease.
+//
+// The Revenue is the expected revenue of the lease on the year.
+type LeaseCashFlow struct {
+    Tenant                      string  `json:"tenant"`
+    Suite                       string  `json:"suite"`
+    Year                        int     `json:"year"`
+    ContractRent                float64 `json:"contract_rent"`
+    FreeRent                    float64 `json:"free_rent"`
+    RenewalRent                 float64 `json:"renewal_rent"`
+    RolloverRent                float64 `json:"rollover_rent"`
+    DowntimeLoss                float64 `json:"downtime_loss"`
+    RolloverFreeRent            float64 `json:"rollover_free_rent"`
+    Revenue                     float64 `json:"revenue"`
+    TenantImprovements          float64 `json:"tenant_improvements"`
+    LeasingCommissions          float64 `json:"leasing_commissions"`
+    LeasingCostsAmortization    float64 `json:"leasing_costs_amortization"`
+}
+
+// lease_cash_flows returns the LeaseCashFlow of the lease for every year from
+// 1 to the given number of years, rolled over with the given profile if it is
+// not nil.
+func (lease Lease) lease_cash_flows (years int, profile *MarketLeasingProfile) []LeaseCashFlow {
+    cash_flows := make([]LeaseCashFlow, years)
+    var rollover []rollover_year
+    if profile != nil {
+        rollover = lease.rollover(years, *profile)
+    }
+    for year := 1; year <= years; year++ {
+        contract_rent, free_rent, renewal_rent := 0.0, 0.0, 0.0
+        for month := 12 * (year - 1) + 1; month <= 12 * year; month++ {
+            if month < lease.StartMonth {
+                continue
+            }
+            rent := lease.monthly_rent(month)
+            if month <= lease.ExpiryMonth {
+                contract_rent += rent
+                if month - lease.StartMonth < lease.FreeRentMonths {
+                    free_rent -= rent
+                }
+                continue
+            }
+            if rollover != nil {
+                continue
+            }
+            // the renewal on which the month falls, and the chance of the
+            // lease being renewed every time up to it.
+            renewal := (month - lease.ExpiryMonth - 1) / lease.term() + 1
+            renewal_rent += rent * math.Pow(lease.RenewalProbability, float64(renewal))
+        }
+        cash_flow := LeaseCashFlow{
+            Tenant: lease.Tenant,
+            Suite: lease.Suite,
+            Year: year,
+            ContractRent: utils.Round2(contract_rent),
+            FreeRent: utils.Round2(free_rent),
+            RenewalRent: utils.Round2(renewal_rent),
+        }
+        if rollover != nil {
+            cash_flow.RolloverRent = utils.Round2(rollover[year - 1].rent)
+            cash_flow.DowntimeLoss = utils.Round2(rollover[year - 1].downtime_loss)
+            cash_flow.RolloverFreeRent = utils.Round2(rollover[year - 1].free_rent)
+            cash_flow.TenantImprovements = utils.Round2(rollover[year - 1].tenant_improvements)
+            cash_flow.LeasingCommissions = utils.Round2(rollover[year - 1].leasing_commissions)
+            cash_flow.LeasingCostsAmortization = utils.Round2(rollover[year - 1].amortization)
+        }
+        cash_flow.Revenue = utils.Round2(
+            cash_flow.ContractRent +
+            cash_flow.FreeRent +
+            cash_flow.RenewalRent +
+            cash_flow.RolloverRent +
+            cash_flow.DowntimeLoss +
+            cash_flow.RolloverFreeRent,
+        )
+        cash_flows[year - 1] = cash_flow
+    }
+    return cash_flows
+}
+
+// LeaseCashFlows returns the LeaseCashFlow of every lease of the rent roll
+// for every year from 1 to the given number of years, lease by lease.
+func (rr RentRoll) LeaseCashFlows (years int) []LeaseCashFlow {
+    var cash_flows []LeaseCashFlow
+    for i, lease := range rr.Leases {
+        var profile *MarketLeasingProfile
+        if rollover, ok := rr.rollovers[i]; ok {
+            profile = &rollover
+        }
+        cash_flows = append(cash_flows, lease.lease_cash_flows(years, profile)...)
+    }
+    return cash_flows
+}
+
+// Revenue returns the expected revenue of the rent roll for every year from
+// 1 to the given number of years.
+func (rr RentRoll) Revenue (years int) []float64 {
+    revenue := make([]float64, years)
+    for _, cash_flow := range rr.LeaseCashFlows(years) {
+        revenue[cash_flow.Year - 1] += cash_flow.Revenue
+    }
+    for i := range revenue {
+        revenue[i] = utils.Round2(revenue[i])
+    }
+    return revenue
+}
+
+// DowntimeLoss returns the expected rent lost to the downtime of the rollover
+// of the rent roll for every year from 1 to the given number of years
+// (negative).
+func (rr RentRoll) DowntimeLoss (years int) []float64 {
+    downtime := make([]float64, years)
+    for _, cash_flow := range rr.LeaseCashFlows(years) {
+        downtime[cash_flow.Year - 1] += cash_flow.DowntimeLoss
+    }
+    for i := range downtime {
+        downtime[i] = utils.Round2(downtime[i])
+    }
+    return downtime
+}
+
+// LeasingCosts returns the expected tenant improvements and leasing
+// commissions of the rollover of the rent roll for every year from 1 to the
+// given number of years (negative).
+func (rr RentRoll) LeasingCosts (years int) (tenantImprovements []float64, leasingCommissions []float64) {
+    tenantImprovements = make([]float64, years)
+    leasingCommissions = make([]float64, years)
+    for _, cash_flow := range rr.LeaseCashFlows(years) {
+        tenantImprovements[cash_flow.Year - 1] += cash_flow.TenantImprovements
+        leasingCommissions[cash_flow.Year - 1] += cash_flow.LeasingCommissions
+    }
+    for i := range tenantImprovements {
+        tenantImprovements[i] = utils.Round2(tenantImprovements[i])
+        leasingCommissions[i] = utils.Round2(leasingCommissions[i])
+    }
+    return tenantImprovements, leasingCommissions
+}
+
+// LeasingCostsAmortization returns the expected amortization of the tenant
+// improvements and leasing commissions of the rollover of the rent roll for
+// every year from 1 to the given number of years (negative).
+func (rr RentRoll) LeasingCostsAmortization (years int) []float64 {
+    amortization := make([]float64, years)
+    for _, cash_flow := range rr.LeaseCashFlows(years) {
+        amortization[cash_flow.Year - 1] += cash_flow.LeasingCostsAmortization
+    }
+    for i := range amortization {
+        amortization[i] = utils.Round2(amortization[i])
+    }
+    return amortization
+}
+
+// WithRentRoll returns a copy of the ReturnOfInvestment with the revenue of
+// every year coming from the given rent roll, in place of the initial revenue
+// grown at the revenue growth of the deal. The tenant improvements and leasing
+// commissions of the rollover of the leases are paid from the cash flow of
+// every year, below the NOI. The initial revenue of the deal is set to the
+// revenue of the rent roll on the year 1, and the loan is sized on the NOI of
+// that year. The deal cannot have a revenue growth vector.
+func (roi ReturnOfInvestment) WithRentRoll (rr RentRoll) (ReturnOfInvestment, error) {
+    validated, err := rr.validated()
+    if err != nil {
+        return roi, fmt.Errorf("RentRoll internal error: %v", err)
+    }
+    if len(validated.Leases) == 0 {
+        return roi, fmt.Errorf("The rent roll must have at least one lease.")
+    }
+    if roi.dealMetrics.RevenueGrowth != nil {
+        return roi, fmt.Errorf("The revenue growth is not used with a rent roll.")
+    }
+    roi.rentRoll = &validated
+    roi.dealMetrics.InitRevenue = validated.Revenue(1)[0]
+    return roi.with_year_one_noi(), nil
+}
@@ -0,0 +1,169 @@
+package investment_analysis
+import (
+    "testing";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+)
+
+// testLeases are a lease in place at the adquisition, on its third year and
+// expiring on the year 3, and a lease starting on the month 7 with three
+// months of free rent, expiring on the year 6.
+var testLeases = []Lease{
+    {"Acme", "100", 10000, -23, 36, 30, 0.03, 0, 0.6},
+    {"Beta", "200", 5000, 7, 66, 32, 0.025, 3, 0.7},
+}
+
+func TestLeaseCashFlows(t *testing.T) {
+    rr, err := NewRentRoll(testLeases...)
+    if err != nil {
+        t.Errorf("NewRentRoll internal error: %v", err)
+        return
+    }
+    cash_flows := rr.LeaseCashFlows(11)
+    if len(cash_flows) != 22 {
+        t.Errorf("LeaseCashFlows got: %v rows, wanted: %v", len(cash_flows), 22)
+        return
+    }
+
+    var testCases = []struct {
+        name string
+        row int
+        want LeaseCashFlow
+    }{
+        // 10000 * 30 * 1.03^2
+        {"In place lease", 0, LeaseCashFlow{Tenant: "Acme", Suite: "100", Year: 1, ContractRent: 318270, FreeRent: 0, RenewalRent: 0, Revenue: 318270}},
+        {"Renewed at 60%", 3, LeaseCashFlow{Tenant: "Acme", Suite: "100", Year: 4, ContractRent: 0, FreeRent: 0, RenewalRent: 208669.33, Revenue: 208669.33}},
+        // the second renewal starts on the month 85.
+        {"Second renewal", 8, LeaseCashFlow{Tenant: "Acme", Suite: "100", Year: 9, ContractRent: 0, FreeRent: 0, RenewalRent: 145142.97, Revenue: 145142.97}},
+        // six months of rent, three of them free.
+        {"Lease up with free rent", 11, LeaseCashFlow{Tenant: "Beta", Suite: "200", Year: 1, ContractRent: 80000, FreeRent: -40000, RenewalRent: 0, Revenue: 40000}},
+        {"Expiry within the year", 16, LeaseCashFlow{Tenant: "Beta", Suite: "200", Year: 6, ContractRent: 88305.03, FreeRent: 0, RenewalRent: 63358.86, Revenue: 151663.89}},
+    }
+    for _, test := range testCases {
+        t.Run(test.name, func(t *testing.T) {
+            got := cash_flows[test.row]
+            if got.Tenant != test.want.Tenant || got.Suite != test.want.Suite || got.Year != test.want.Year {
+                t.Errorf("LeaseCashFlow got: %+v, wanted: %+v", got, test.want)
+                return
+            }
+            if !utils.Tolerance(got.ContractRent, test.want.ContractRent, 0.01) ||
+                !utils.Tolerance(got.FreeRent, test.want.FreeRent, 0.01) ||
+                !utils.Tolerance(got.RenewalRent, test.want.RenewalRent, 0.01) ||
+                !utils.Tolerance(got.Revenue, test.want.Revenue, 0.01) {
+                t.Errorf("LeaseCashFlow got: %+v, wanted: %+v", got, test.want)
+            }
+        })
+    }
+
+    revenue := rr.Revenue(11)
+    for year, want := range map[int]float64{1: 358270, 2: 489818.1, 6: 373041.19, 11: 274097.82} {
+        if !utils.Tolerance(revenue[year - 1], want, 0.01) {
+            t.Errorf("Revenue of the year %v got: %v, wanted: %v", year, revenue[year - 1], want)
+        }
+    }
+}
+
+func TestRentRollProjection(t *testing.T) {
+    roi, err := newTestROI(baseTestROI)
+    if err != nil {
+        t.Errorf("ReturnOfInvestment internal error: %v", err)
+        return
+    }
+    // twice the space of the test leases.
+    leases := make([]Lease, len(testLeases))
+    for i, lease := range testLeases {
+        lease.SquareFeet = 2 * lease.SquareFeet
+        leases[i] = lease
+    }
+    roi, err = roi.WithRentRoll(RentRoll{Leases: leases})
+    if err != nil {
+        t.Errorf("WithRentRoll internal error: %v", err)
+        return
+    }
+    if roi.dealMetrics.InitRevenue != 716540 || roi.loanMetrics.NOI != 416540 {
+        t.Errorf("InitRevenue and NOI got: %v %v, wanted: %v %v", roi.dealMetrics.InitRevenue, roi.loanMetrics.NOI, 716540, 416540)
+    }
+
+    projection, err := roi.Projection()
+    if err != nil {
+        t.Errorf("Projection internal error: %v", err)
+        return
+    }
+    for year, want := range map[int]float64{1: 716540, 2: 979636.2, 4: 757741.17, 10: 575328.3} {
+        if !utils.Tolerance(projection.Years[year - 1].Revenue, want, 0.01) {
+            t.Errorf("Revenue of the year %v got: %v, wanted: %v", year, projection.Years[year - 1].Revenue, want)
+        }
+    }
+    cash_flows := projection.CashFlows()
+    for year, want := range map[int]float64{1: 133890.61, 2: 325775.26, 9: -36742.86, 10: -1420744.55} {
+        if !utils.Tolerance(cash_flows[year], want, 0.1) {
+            t.Errorf("Year %v net_cash_flow got: %v, wanted: %v", year, cash_flows[year], want)
+        }
+    }
+    // the sale uses the revenue of the rent roll on the year 11.
+    if !utils.Tolerance(projection.Sale.SalePrice, 2462554.05, 0.1) {
+        t.Errorf("SalePrice got: %v, wanted: %v", projection.Sale.SalePrice, 2462554.05)
+    }
+
+    t.Run("Revenue growth is not used", func(t *testing.T) {
+        growth, _ := NewNormalDistribution(0.035, 0.01)
+        if _, err := roi.MonteCarlo(MonteCarlo{RevenueGrowth: &growth, Simulations: 10}); err == nil {
+            t.Errorf("MonteCarlo got no error, wanted an error")
+        }
+        if _, err := roi.with_input(RevenueGrowthInput, 0.02); err == nil {
+            t.Errorf("with_input got no error, wanted an error")
+        }
+        lease_up := []float64{0.10, 0.08, 0.05, 0.035, 0.035, 0.035, 0.035, 0.035, 0.035, 0.035}
+        if _, err := roi.WithGrowthVectors(lease_up, nil, nil); err == nil {
+            t.Errorf("WithGrowthVectors got no error, wanted an error")
+        }
+        vectors, err := newTestROI(baseTestROI)
+        if err != nil {
+            t.Errorf("ReturnOfInvestment internal error: %v", err)
+            return
+        }
+        vectors, err = vectors.WithGrowthVectors(lease_up, nil, nil)
+        if err != nil {
+            t.Errorf("WithGrowthVectors internal error: %v", err)
+            return
+        }
+        if _, err := vectors.WithRentRoll(RentRoll{Leases: leases}); err == nil {
+            t.Errorf("WithRentRoll got no error, wanted an error")
+        }
+        // the expenses and reserves still grow with a rent roll.
+        if _, err := roi.WithGrowthVectors(nil, lease_up, lease_up); err != nil {
+            t.Errorf("WithGrowthVectors internal error: %v", err)
+        }
+    })
+}
+
+func TestNewRentRoll(t *testing.T) {
+    var testCases = []struct {
+        name string
+        leases []Lease
+    }{
+        {"No square feet", []Lease{{"Acme", "100", 0, 1, 60, 30, 0.03, 0, 0.6}}},
+        {"Expiry before the start", []Lease{{"Acme", "100", 10000, 60, 1, 30, 0.03, 0, 0.6}}},
+        {"Negative rent", []Lease{{"Acme", "100", 10000, 1, 60, -30, 0.03, 0, 0.6}}},
+        {"Free rent longer than the lease", []Lease{{"Acme", "100", 10000, 1, 12, 30, 0.03, 13, 0.6}}},
+        {"Renewal probability above 1", []Lease{{"Acme", "100", 10000, 1, 60, 30, 0.03, 0, 1.2}}},
+        {"Overlapping leases of a suite", []Lease{testLeases[0], {"Beta", "100", 10000, 36, 96, 30, 0.03, 0, 0.6}}},
+    }
+    for _, test := range testCases {
+        t.Run(test.name, func(t *testing.T) {
+            if _, err := NewRentRoll(test.leases...); err == nil {
+                t.Errorf("NewRentRoll got no error, wanted an error")
+            }
+        })
+    }
+
+    t.Run("Empty rent roll", func(t *testing.T) {
+        roi, err := newTestROI(baseTestROI)
+        if err != nil {
+            t.Errorf("ReturnOfInvestment internal error: %v", err)
+            return
+        }
+        if _, err := roi.WithRentRoll(RentRoll{}); err == nil {
+            t.Errorf("WithRentRoll got no error, wanted an error")
+        }
+    })
+}
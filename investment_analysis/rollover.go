@@ -0,0 +1,274 @@
+// Rollover of the leases of the rent roll. When a lease expires the space is
+// either renewed or leased to a new tenant after some downtime, at the market
+// rent of the time, with the tenant improvements and leasing commissions of
+// the new lease. Both outcomes are blended by the renewal probability.
+
+package investment_analysis
+
+import (
+    "fmt";
+    "math";
+)
+
+// MarketLeasingProfile is a struct with the market leasing assumptions used
+// to roll over the leases of the rent roll.
+//
+// The MarketRent is the annual rent per square foot of a new lease at the
+// adquisition, and it grows by the MarketRentGrowth every year of the
+// projection, as the TenantImprovements per square foot do. The leases of
+// the rollover last TermMonths and their rent rises by the Escalation on
+// every anniversary. At every expiry the lease is renewed with the
+// RenewalProbability, if not the space is vacant for the DowntimeMonths
+// before a new tenant moves in. The FreeRentMonths, TenantImprovements and
+// LeasingCommissions (a share of the rent of the whole term) of the renewals
+// and the new leases are set apart.
+type MarketLeasingProfile struct {
+    Name                        string
+    MarketRent                  float64
+    MarketRentGrowth            float64
+    Escalation                  float64
+    TermMonths                  int
+    RenewalProbability          float64
+    DowntimeMonths              int
+    RenewalFreeRentMonths       int
+    NewFreeRentMonths           int
+    RenewalTenantImprovements   float64
+    NewTenantImprovements       float64
+    RenewalLeasingCommissions   float64
+    NewLeasingCommissions       float64
+}
+
+// NewMarketLeasingProfile returns a MarketLeasingProfile struct if the values
+// given are valid. If not, returns a default struct with the error.
+func NewMarketLeasingProfile(
+    name string,
+    marketRent float64,
+    marketRentGrowth float64,
+    escalation float64,
+    termMonths int,
+    renewalProbability float64,
+    downtimeMonths int,
+    renewalFreeRentMonths int,
+    newFreeRentMonths int,
+    renewalTenantImprovements float64,
+    newTenantImprovements float64,
+    renewalLeasingCommissions float64,
+    newLeasingCommissions float64,
+) (
+    MarketLeasingProfile,
+    error,
+) {
+    // Data Validation
+    if marketRent < 0 {
+        return MarketLeasingProfile{}, fmt.Errorf("The marketRent cannot be lower than 0.")
+    }
+    if marketRentGrowth <= -1 {
+        return MarketLeasingProfile{}, fmt.Errorf("The marketRentGrowth must be greater than -1.")
+    }
+    if escalation <= -1 {
+        return MarketLeasingProfile{}, fmt.Errorf("The escalation must be greater than -1.")
+    }
+    if termMonths <= 0 {
+        return MarketLeasingProfile{}, fmt.Errorf("The termMonths must be greater than 0.")
+    }
+    if renewalProbability < 0 || renewalProbability > 1 {
+        return MarketLeasingProfile{}, fmt.Errorf("The renewalProbability must be between 0 and 1.")
+    }
+    if downtimeMonths < 0 {
+        return MarketLeasingProfile{}, fmt.Errorf("The downtimeMonths cannot be lower than 0.")
+    }
+    if renewalFreeRentMonths < 0 || renewalFreeRentMonths > termMonths {
+        return MarketLeasingProfile{}, fmt.Errorf("The renewalFreeRentMonths must be between 0 and the termMonths.")
+    }
+    if newFreeRentMonths < 0 || newFreeRentMonths > termMonths {
+        return MarketLeasingProfile{}, fmt.Errorf("The newFreeRentMonths must be between 0 and the termMonths.")
+    }
+    if renewalTenantImprovements < 0 || newTenantImprovements < 0 {
+        return MarketLeasingProfile{}, fmt.Errorf("The tenant improvements cannot be lower than 0.")
+    }
+    if renewalLeasingCommissions < 0 || renewalLeasingCommissions > 1 {
+        return MarketLeasingProfile{}, fmt.Errorf("The renewalLeasingCommissions must be between 0 and 1.")
+    }
+    if newLeasingCommissions < 0 || newLeasingCommissions > 1 {
+        return MarketLeasingProfile{}, fmt.Errorf("The newLeasingCommissions must be between 0 and 1.")
+    }
+    // Struct Creation
+    profile := MarketLeasingProfile{
+        Name: name,
+        MarketRent: marketRent,
+        MarketRentGrowth: marketRentGrowth,
+        Escalation: escalation,
+        TermMonths: termMonths,
+        RenewalProbability: renewalProbability,
+        DowntimeMonths: downtimeMonths,
+        RenewalFreeRentMonths: renewalFreeRentMonths,
+        NewFreeRentMonths: newFreeRentMonths,
+        RenewalTenantImprovements: renewalTenantImprovements,
+        NewTenantImprovements: newTenantImprovements,
+        RenewalLeasingCommissions: renewalLeasingCommissions,
+        NewLeasingCommissions: newLeasingCommissions,
+    }
+    return profile, nil
+}
+
+// validated returns the MarketLeasingProfile checked by its constructor.
+func (profile MarketLeasingProfile) validated () (MarketLeasingProfile, error) {
+    return NewMarketLeasingProfile(
+        profile.Name,
+        profile.MarketRent,
+        profile.MarketRentGrowth,
+        profile.Escalation,
+        profile.TermMonths,
+        profile.RenewalProbability,
+        profile.DowntimeMonths,
+        profile.RenewalFreeRentMonths,
+        profile.NewFreeRentMonths,
+        profile.RenewalTenantImprovements,
+        profile.NewTenantImprovements,
+        profile.RenewalLeasingCommissions,
+        profile.NewLeasingCommissions,
+    )
+}
+
+// market_factor returns the growth of the market since the adquisition on the
+// year of the projection of the given month.
+func (profile MarketLeasingProfile) market_factor (month int) float64 {
+    return math.Pow(1 + profile.MarketRentGrowth, float64((month - 1) / 12))
+}
+
+// WithLeaseRollover returns a copy of the RentRoll with the lease at the given
+// index of the Leases rolled over with the given MarketLeasingProfile at its
+// expiry, in place of being renewed on the same terms. The RenewalProbability
+// of the profile is used instead of the one of the lease.
+func (rr RentRoll) WithLeaseRollover (lease int, profile MarketLeasingProfile) (RentRoll, error) {
+    if lease < 0 || lease >= len(rr.Leases) {
+        return rr, fmt.Errorf("The rent roll has no lease %d.", lease)
+    }
+    validated, err := profile.validated()
+    if err != nil {
+        return rr, fmt.Errorf("NewMarketLeasingProfile internal error: %v", err)
+    }
+    rollovers := make(map[int]MarketLeasingProfile, len(rr.rollovers) + 1)
+    for i, p := range rr.rollovers {
+        rollovers[i] = p
+    }
+    rollovers[lease] = validated
+    rr.rollovers = rollovers
+    return rr, nil
+}
+
+// WithRollover returns a copy of the RentRoll with every lease that has no
+// MarketLeasingProfile rolled over with the given one.
+func (rr RentRoll) WithRollover (profile MarketLeasingProfile) (RentRoll, error) {
+    for i := range rr.Leases {
+        if _, ok := rr.rollovers[i]; ok {
+            continue
+        }
+        rolled, err := rr.WithLeaseRollover(i, profile)
+        if err != nil {
+            return rr, fmt.Errorf("WithLeaseRollover internal error: %v", err)
+        }
+        rr = rolled
+    }
+    return rr, nil
+}
+
+// rollover_year has the expected values of the rollover of a lease on a year
+// of the projection.
+type rollover_year struct {
+    rent                    float64
+    downtime_loss           float64
+    free_rent               float64
+    tenant_improvements     float64
+    leasing_commissions     float64
+    amortization            float64
+}
+
+// rollover returns the expected values of the rollover of the lease with the
+// given MarketLeasingProfile for every year from 1 to the given number of
+// years.
+//
+// Every month on which a lease of the rollover can start carries the chance of
+// the space being rolled over on it. The renewal starts a new lease on that
+// month, and the new tenant after the downtime, both at the market rent of
+// the time, and the chance is carried to the month after their expiry. The
+// rent of the space during the downtime, at the market rent, is taken as a
+// loss.
+func (lease Lease) rollover (years int, profile MarketLeasingProfile) []rollover_year {
+    horizon := 12 * years
+    flows := make([]rollover_year, years)
+
+    // chance of the space being rolled over on every month of the horizon.
+    chance := make([]float64, horizon + 1)
+    first := lease.ExpiryMonth + 1
+    if first < 1 {
+        first = 1
+    }
+    if first > horizon {
+        return flows
+    }
+    chance[first] = 1
+
+    // new_lease adds a lease of the rollover starting on the given month with
+    // the given chance.
+    new_lease := func (start int, weight float64, free_rent_months int, tenant_improvements float64, leasing_commissions float64) {
+        if start > horizon || weight == 0 {
+            return
+        }
+        starting_rent := lease.SquareFeet * profile.MarketRent * profile.market_factor(start)
+        term_rent := 0.0
+        for i := 0; i < profile.TermMonths; i++ {
+            rent := starting_rent * math.Pow(1 + profile.Escalation, float64(i / 12)) / 12
+            term_rent += rent
+            month := start + i
+            if month > horizon {
+                continue
+            }
+            flows[(month - 1) / 12].rent += weight * rent
+            if i < free_rent_months {
+                flows[(month - 1) / 12].free_rent -= weight * rent
+            }
+        }
+        year := (start - 1) / 12
+        leasing_costs := weight * (lease.SquareFeet * tenant_improvements * profile.market_factor(start) + leasing_commissions * term_rent)
+        flows[year].tenant_improvements -= weight * lease.SquareFeet * tenant_improvements * profile.market_factor(start)
+        flows[year].leasing_commissions -= weight * leasing_commissions * term_rent
+        // the leasing costs are amortized over the term of the lease.
+        for i := 0; i < profile.TermMonths && start + i <= horizon; i++ {
+            flows[(start + i - 1) / 12].amortization -= leasing_costs / float64(profile.TermMonths)
+        }
+        if next := start + profile.TermMonths; next <= horizon {
+            chance[next] += weight
+        }
+    }
+
+    for month := first; month <= horizon; month++ {
+        weight := chance[month]
+        if weight == 0 {
+            continue
+        }
+        new_lease(
+            month,
+            weight * profile.RenewalProbability,
+            profile.RenewalFreeRentMonths,
+            profile.RenewalTenantImprovements,
+            profile.RenewalLeasingCommissions,
+        )
+        // the space is vacant during the downtime, the market rent of those
+        // months is lost.
+        vacant := weight * (1 - profile.RenewalProbability)
+        for i := 0; i < profile.DowntimeMonths && month + i <= horizon; i++ {
+            rent := vacant * lease.SquareFeet * profile.MarketRent * profile.market_factor(month + i) / 12
+            flows[(month + i - 1) / 12].rent += rent
+            flows[(month + i - 1) / 12].downtime_loss -= rent
+        }
+        new_lease(
+            month + profile.DowntimeMonths,
+            vacant,
+            profile.NewFreeRentMonths,
+            profile.NewTenantImprovements,
+            profile.NewLeasingCommissions,
+        )
+    }
+    return flows
+}
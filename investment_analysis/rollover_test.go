@@ -0,0 +1,274 @@
+package investment_analysis
+import (
+    "testing";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+)
+
+// testProfile is a market leasing profile of an office building, five year
+// leases with a 65% chance of renewal and nine months of downtime.
+var testProfile = MarketLeasingProfile{
+    Name: "Office",
+    MarketRent: 33,
+    MarketRentGrowth: 0.03,
+    Escalation: 0.03,
+    TermMonths: 60,
+    RenewalProbability: 0.65,
+    DowntimeMonths: 9,
+    RenewalFreeRentMonths: 2,
+    NewFreeRentMonths: 6,
+    RenewalTenantImprovements: 10,
+    NewTenantImprovements: 40,
+    RenewalLeasingCommissions: 0.03,
+    NewLeasingCommissions: 0.06,
+}
+
+func TestRollover(t *testing.T) {
+    rr, err := NewRentRoll(testLeases[0])
+    if err != nil {
+        t.Errorf("NewRentRoll internal error: %v", err)
+        return
+    }
+    rr, err = rr.WithRollover(testProfile)
+    if err != nil {
+        t.Errorf("WithRollover internal error: %v", err)
+        return
+    }
+    cash_flows := rr.LeaseCashFlows(11)
+
+    var testCases = []struct {
+        name string
+        year int
+        want LeaseCashFlow
+    }{
+        {"Lease term", 3, LeaseCashFlow{ContractRent: 337652.64, Revenue: 337652.64}},
+        // the space at 33 * 1.03^3 a square foot, 35% of it vacant for nine
+        // months, with the free rent, tenant improvements and leasing
+        // commissions of the renewal and of the new tenant.
+        {"First rollover", 4, LeaseCashFlow{RolloverRent: 360599.91, DowntimeLoss: -94657.48, RolloverFreeRent: -70617.48, Revenue: 195324.95, TenantImprovements: -224009.04, LeasingCommissions: -77536.19, LeasingCostsAmortization: -31331.19}},
+        // the leasing costs of the year 4 amortized over the five years of
+        // the leases.
+        {"Free rent of the new tenant", 5, LeaseCashFlow{RolloverRent: 368578.18, RolloverFreeRent: -31552.49, Revenue: 337025.69, LeasingCostsAmortization: -60309.05}},
+        {"Second rollover of the renewal", 9, LeaseCashFlow{RolloverRent: 414837.99, DowntimeLoss: -84129.37, RolloverFreeRent: -69062.72, Revenue: 261645.9, TenantImprovements: -197616.13, LeasingCommissions: -73573.11, LeasingCostsAmortization: -54785.11}},
+        // the lease of the new tenant started after the downtime rolls over
+        // a year later.
+        {"Second rollover of the new tenant", 10, LeaseCashFlow{RolloverRent: 426295.53, DowntimeLoss: -26372.73, RolloverFreeRent: -50148.42, Revenue: 349774.38, TenantImprovements: -63933.89, LeasingCommissions: -16801.97, LeasingCostsAmortization: -62311.43}},
+    }
+    for _, test := range testCases {
+        t.Run(test.name, func(t *testing.T) {
+            got := cash_flows[test.year - 1]
+            want := test.want
+            if got.RenewalRent != 0 ||
+                !utils.Tolerance(got.ContractRent, want.ContractRent, 0.01) ||
+                !utils.Tolerance(got.RolloverRent, want.RolloverRent, 0.01) ||
+                !utils.Tolerance(got.DowntimeLoss, want.DowntimeLoss, 0.01) ||
+                !utils.Tolerance(got.RolloverFreeRent, want.RolloverFreeRent, 0.01) ||
+                !utils.Tolerance(got.Revenue, want.Revenue, 0.01) ||
+                !utils.Tolerance(got.TenantImprovements, want.TenantImprovements, 0.01) ||
+                !utils.Tolerance(got.LeasingCommissions, want.LeasingCommissions, 0.01) ||
+                !utils.Tolerance(got.LeasingCostsAmortization, want.LeasingCostsAmortization, 0.01) {
+                t.Errorf("LeaseCashFlow got: %+v, wanted: %+v", got, want)
+            }
+        })
+    }
+
+    t.Run("Renewal at market without costs", func(t *testing.T) {
+        profile := MarketLeasingProfile{MarketRent: 33, TermMonths: 60, RenewalProbability: 1}
+        got := testLeases[0].lease_cash_flows(4, &profile)[3]
+        if got.Revenue != 330000 || got.DowntimeLoss != 0 || got.TenantImprovements != 0 {
+            t.Errorf("LeaseCashFlow got: %+v, wanted a revenue of %v", got, 330000)
+        }
+    })
+}
+
+func TestRolloverProjection(t *testing.T) {
+    roi, err := newTestROI(baseTestROI)
+    if err != nil {
+        t.Errorf("ReturnOfInvestment internal error: %v", err)
+        return
+    }
+    // twice the space of the test leases, only the first one has the
+    // profile.
+    leases := make([]Lease, len(testLeases))
+    for i, lease := range testLeases {
+        lease.SquareFeet = 2 * lease.SquareFeet
+        leases[i] = lease
+    }
+    rr, err := RentRoll{Leases: leases}.WithLeaseRollover(0, testProfile)
+    if err != nil {
+        t.Errorf("WithLeaseRollover internal error: %v", err)
+        return
+    }
+    roi, err = roi.WithRentRoll(rr)
+    if err != nil {
+        t.Errorf("WithRentRoll internal error: %v", err)
+        return
+    }
+    projection, err := roi.Projection()
+    if err != nil {
+        t.Errorf("Projection internal error: %v", err)
+        return
+    }
+
+    year := projection.Years[3]
+    if !utils.Tolerance(year.Revenue, 731052.41, 0.01) ||
+        !utils.Tolerance(year.TenantImprovements, -448018.07, 0.01) ||
+        !utils.Tolerance(year.LeasingCommissions, -155072.39, 0.01) {
+        t.Errorf("Year 4 got: %+v, wanted revenue %v, tenant improvements %v and leasing commissions %v", year, 731052.41, -448018.07, -155072.39)
+    }
+    // the leasing costs are amortized over the five years of the leases of
+    // the rollover, on top of the depreciation of the building.
+    for year, want := range map[int]float64{3: 0, 4: -62662.37, 5: -120618.09, 9: -109570.22, 10: -124622.87} {
+        if !utils.Tolerance(projection.Years[year - 1].DepreciationExpense, -168518.52 + want, 0.01) {
+            t.Errorf("Year %v DepreciationExpense got: %v, wanted: %v", year, projection.Years[year - 1].DepreciationExpense, -168518.52 + want)
+        }
+    }
+    // the leasing costs are paid below the NOI, and the ones spent up to the
+    // sale are part of the cost of the project.
+    cash_flows := projection.CashFlows()
+    for year, want := range map[int]float64{3: 341028.64, 4: -460212.7, 9: -376974.4, 10: 6218566.27} {
+        if !utils.Tolerance(cash_flows[year], want, 0.1) {
+            t.Errorf("Year %v net_cash_flow got: %v, wanted: %v", year, cash_flows[year], want)
+        }
+    }
+    metrics, err := return_metrics(projection)
+    if err != nil {
+        t.Errorf("return_metrics internal error: %v", err)
+        return
+    }
+    if !utils.Tolerance(metrics.LeveredIRR, 0.1597, 0.0001) {
+        t.Errorf("LeveredIRR got: %v, wanted: %v", metrics.LeveredIRR, 0.1597)
+    }
+}
+
+func TestRentRollWithRollover(t *testing.T) {
+    own := testProfile
+    own.Name = "Retail"
+    retail, err := RentRoll{Leases: testLeases}.WithLeaseRollover(0, own)
+    if err != nil {
+        t.Errorf("WithLeaseRollover internal error: %v", err)
+        return
+    }
+    rr, err := retail.WithRollover(testProfile)
+    if err != nil {
+        t.Errorf("WithRollover internal error: %v", err)
+        return
+    }
+    if rr.rollovers[0].Name != "Retail" || rr.rollovers[1].Name != "Office" {
+        t.Errorf("Rollover got: %+v, wanted: Retail Office", rr.rollovers)
+    }
+    if _, ok := retail.rollovers[1]; ok {
+        t.Errorf("WithRollover changed the rent roll given")
+    }
+    // the profiles are kept when the rent roll is set on the deal.
+    validated, err := rr.validated()
+    if err != nil || len(validated.rollovers) != 2 {
+        t.Errorf("validated got: %+v, error: %v, wanted two profiles", validated.rollovers, err)
+    }
+    if _, err := rr.WithLeaseRollover(2, testProfile); err == nil {
+        t.Errorf("WithLeaseRollover got no error, wanted an error for a lease out of the rent roll")
+    }
+
+    var testCases = []struct {
+        name string
+        change func (*MarketLeasingProfile)
+    }{
+        {"Negative market rent", func (p *MarketLeasingProfile) { p.MarketRent = -1 }},
+        {"No term", func (p *MarketLeasingProfile) { p.TermMonths = 0 }},
+        {"Renewal probability above 1", func (p *MarketLeasingProfile) { p.RenewalProbability = 1.5 }},
+        {"Negative downtime", func (p *MarketLeasingProfile) { p.DowntimeMonths = -1 }},
+        {"Free rent longer than the term", func (p *MarketLeasingProfile) { p.NewFreeRentMonths = 61 }},
+        {"Negative tenant improvements", func (p *MarketLeasingProfile) { p.RenewalTenantImprovements = -1 }},
+        {"Leasing commissions above 1", func (p *MarketLeasingProfile) { p.NewLeasingCommissions = 1.5 }},
+    }
+    for _, test := range testCases {
+        t.Run(test.name, func(t *testing.T) {
+            profile := testProfile
+            test.change(&profile)
+            if _, err := (RentRoll{Leases: testLeases}).WithLeaseRollover(0, profile); err == nil {
+                t.Errorf("WithLeaseRollover got no error, wanted an error")
+            }
+            rr := RentRoll{Leases: testLeases, rollovers: map[int]MarketLeasingProfile{0: profile}}
+            if _, err := rr.validated(); err == nil {
+                t.Errorf("validated got no error, wanted an error")
+            }
+        })
+    }
+}
+
+func TestRolloverOperatingStatement(t *testing.T) {
+    roi, err := newTestROI(baseTestROI)
+    if err != nil {
+        t.Errorf("ReturnOfInvestment internal error: %v", err)
+        return
+    }
+    // the rent roll of TestRolloverProjection.
+    leases := make([]Lease, len(testLeases))
+    for i, lease := range testLeases {
+        lease.SquareFeet = 2 * lease.SquareFeet
+        leases[i] = lease
+    }
+    rr, err := RentRoll{Leases: leases}.WithLeaseRollover(0, testProfile)
+    if err != nil {
+        t.Errorf("WithLeaseRollover internal error: %v", err)
+        return
+    }
+    statement, err := NewOperatingStatement(0.05, 0, 0)
+    if err != nil {
+        t.Errorf("NewOperatingStatement internal error: %v", err)
+        return
+    }
+    oe, err := NewOperatingExpenses(ExpenseItem{"Utilities", -45000, 0.03, 0, true, 0})
+    if err != nil {
+        t.Errorf("NewOperatingExpenses internal error: %v", err)
+        return
+    }
+    roi, err = roi.WithRentRoll(rr)
+    if err != nil {
+        t.Errorf("WithRentRoll internal error: %v", err)
+        return
+    }
+    roi, err = roi.WithOperatingStatement(statement)
+    if err != nil {
+        t.Errorf("WithOperatingStatement internal error: %v", err)
+        return
+    }
+    roi, err = roi.WithOperatingExpenses(oe)
+    if err != nil {
+        t.Errorf("WithOperatingExpenses internal error: %v", err)
+        return
+    }
+    projection, err := roi.Projection()
+    if err != nil {
+        t.Errorf("Projection internal error: %v", err)
+        return
+    }
+    // the 5% of general vacancy takes the downtime of the rollover as part
+    // of it, on the years with downtime above 5% of the rent with all the
+    // space leased there is no general vacancy left. The utilities are taken
+    // at the occupancy left by the downtime, reported on its own line.
+    var testCases = []struct {
+        year int
+        downtime float64
+        vacancy float64
+        revenue float64
+        utilities float64
+    }{
+        {3, 0, -50370.26, 957035.03, -45353.48},
+        // 189314.95 of downtime over 920367.36 of rent with all the space
+        // leased.
+        {4, -189314.95, 0, 731052.41, -39058.14},
+        {5, 0, -51148.2, 971815.75, -48115.5},
+        // 52745.46 of downtime, above the 51431.4 of general vacancy.
+        {10, -52745.46, 0, 975882.53, -55704.05},
+    }
+    for _, test := range testCases {
+        year := projection.Years[test.year - 1]
+        utilities := projection.Expenses[test.year - 1]
+        if !utils.Tolerance(year.DowntimeLoss, test.downtime, 0.01) ||
+            !utils.Tolerance(year.Vacancy, test.vacancy, 0.01) ||
+            !utils.Tolerance(year.Revenue, test.revenue, 0.01) ||
+            !utils.Tolerance(utilities.Amount, test.utilities, 0.01) {
+            t.Errorf("Year %v got: downtime %v, vacancy %v, revenue %v, utilities %v, wanted: %v, %v, %v, %v", test.year, year.DowntimeLoss, year.Vacancy, year.Revenue, utilities.Amount, test.downtime, test.vacancy, test.revenue, test.utilities)
+        }
+    }
+}
@@ -0,0 +1,306 @@
+// Sensitivity tables of the deal. Two inputs of the deal are moved over a
+// list of values each, and the return metrics are calculated for every pair
+// of values.
+
+package investment_analysis
+
+import (
+    "fmt";
+    "math";
+    "runtime";
+    "strconv";
+    "sync";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+)
+
+// MinimumDSCRTarget is the lowest DSCR of the senior loan along the
+// projection, only used by the sensitivity tables.
+const MinimumDSCRTarget ReturnTarget = "minimum_dscr"
+
+// SensitivityInput is the name of an input of the deal that can be moved in
+// a sensitivity table.
+type SensitivityInput string
+
+const (
+    ExitCapRateInput        SensitivityInput = "exit_cap_rate"
+    CostOfSaleInput         SensitivityInput = "cost_of_sale"
+    InterestRateInput       SensitivityInput = "interest_rate"
+    PurchasePriceInput      SensitivityInput = "purchase_price"
+    RevenueGrowthInput      SensitivityInput = "revenue_growth"
+    ExpenseGrowthInput      SensitivityInput = "expense_growth"
+)
+
+// SensitivityDimension is an input of the deal with the values it takes in
+// the sensitivity table.
+type SensitivityDimension struct {
+    Input       SensitivityInput
+    Values      []float64
+}
+
+// SensitivityCell is the outcome of the deal for a pair of values of the
+// sensitivity table. Err is set if the deal could not be evaluated with
+// those values, with every metric at 0.
+type SensitivityCell struct {
+    RowValue        float64
+    ColumnValue     float64
+    LeveredIRR      float64
+    EquityMultiple  float64
+    MinimumDSCR     float64
+    Err             error
+}
+
+// SensitivityGrid is the sensitivity table of the deal. Cells has a row for
+// every value of the Rows dimension and a column for every value of the
+// Columns dimension.
+type SensitivityGrid struct {
+    Rows        SensitivityDimension
+    Columns     SensitivityDimension
+    Cells       [][]SensitivityCell
+}
+
+// with_input returns a copy of the ReturnOfInvestment with the given input
+// set to the value. The loan is sized again when the input changes it.
+func (roi ReturnOfInvestment) with_input (input SensitivityInput, value float64) (ReturnOfInvestment, error) {
+    switch input {
+    case ExitCapRateInput, CostOfSaleInput:
+        sale := roi.saleMetrics
+        if input == ExitCapRateInput {
+            sale.ExitCapRate = value
+        } else {
+            sale.CostOfSale = value
+        }
+        saleTerms, err := NewSaleTerms(sale.ExitCapRate, sale.CostOfSale, sale.SaleYear)
+        if err != nil {
+            return roi, fmt.Errorf("NewSaleTerms internal error: %v", err)
+        }
+        roi.saleMetrics = saleTerms
+    case InterestRateInput:
+        // the rate of a floating rate loan comes from its index and spread.
+        if roi.loanMetrics.FloatingRate != nil {
+            return roi, fmt.Errorf("The interest rate is not used with a floating rate loan.")
+        }
+        if value < 0 || value > 1 {
+            return roi, fmt.Errorf("The interest rate of a loan must be between 0 and 1.")
+        }
+        roi.loanMetrics.Rate = value
+    case PurchasePriceInput:
+        return roi.with_purchase_price(int(math.Round(value)))
+    case RevenueGrowthInput, ExpenseGrowthInput:
+        // the value is the growth rate of every year, it replaces the growth
+        // vector of the input if the deal has one.
+        if input == RevenueGrowthInput && roi.rentRoll != nil {
+            return roi, fmt.Errorf("The revenue growth is not used with a rent roll.")
+        }
+        if input == ExpenseGrowthInput && roi.operatingExpenses != nil {
+            return roi, fmt.Errorf("The expense growth is not used with operating expense items.")
+        }
+        deal := roi.dealMetrics
+        if input == RevenueGrowthInput {
+            deal.ProjRevenueGrowth = value
+            deal.RevenueGrowth = nil
+        } else {
+            deal.ProjOperatingExpensesGrowth = value
+            deal.OperatingExpensesGrowth = nil
+        }
+        dealMetrics, err := NewDealInformation(
+            deal.PurchasePrice,
+            deal.ClosingAndRenovations,
+            deal.GoingInCapRate,
+            deal.InitRevenue,
+            deal.InitOperatingExpenses,
+            deal.InitCapitalReserves,
+            deal.ProjRevenueGrowth,
+            deal.ProjOperatingExpensesGrowth,
+            deal.ProjCapitalReservesGrowth,
+        )
+        if err != nil {
+            return roi, fmt.Errorf("NewDealInformation internal error: %v", err)
+        }
+        dealMetrics.RevenueGrowth = deal.RevenueGrowth
+        dealMetrics.OperatingExpensesGrowth = deal.OperatingExpensesGrowth
+        dealMetrics.CapitalReservesGrowth = deal.CapitalReservesGrowth
+        roi.dealMetrics = dealMetrics
+    default:
+        return roi, fmt.Errorf("Unknown sensitivity input: %v.", input)
+    }
+    return roi, nil
+}
+
+// minimum_dscr returns the lowest DSCR of the years of the projection, the
+// NOI over the annual debt service of the year, without the payoff of the
+// loan. The years without debt service are left out, and if no year has debt
+// service the DSCR is 0 and ok is false.
+func minimum_dscr (projection Projection) (minimum float64, ok bool) {
+    for _, year := range projection.Years {
+        debt_service := - year.AnnualDebtService
+        if debt_service <= 0 {
+            continue
+        }
+        dscr := year.NOI / debt_service
+        if !ok || dscr < minimum {
+            minimum = dscr
+        }
+        ok = true
+    }
+    return utils.Round4(minimum), ok
+}
+
+// sensitivity_cell returns the SensitivityCell of the deal with the given
+// values of the dimensions.
+func (roi ReturnOfInvestment) sensitivity_cell (
+    rows SensitivityDimension,
+    rowValue float64,
+    columns SensitivityDimension,
+    columnValue float64,
+) SensitivityCell {
+    cell := SensitivityCell{RowValue: rowValue, ColumnValue: columnValue}
+    candidate, err := roi.with_input(rows.Input, rowValue)
+    if err != nil {
+        cell.Err = fmt.Errorf("with_input internal error: %v", err)
+        return cell
+    }
+    candidate, err = candidate.with_input(columns.Input, columnValue)
+    if err != nil {
+        cell.Err = fmt.Errorf("with_input internal error: %v", err)
+        return cell
+    }
+    projection, err := candidate.Projection()
+    if err != nil {
+        cell.Err = fmt.Errorf("Projection internal error: %v", err)
+        return cell
+    }
+    metrics, err := return_metrics(projection)
+    if err != nil {
+        cell.Err = fmt.Errorf("return_metrics internal error: %v", err)
+        return cell
+    }
+    cell.LeveredIRR = metrics.LeveredIRR
+    cell.EquityMultiple = metrics.EquityMultiple
+    cell.MinimumDSCR, _ = minimum_dscr(projection)
+    return cell
+}
+
+// Sensitivity returns the SensitivityGrid of the deal over the values of the
+// rows and the columns dimensions. Every cell is evaluated on its own copy of
+// the ReturnOfInvestment, in parallel, with at most the given number of
+// workers. A zero number of workers uses one worker per CPU.
+func (roi ReturnOfInvestment) Sensitivity (
+    rows SensitivityDimension,
+    columns SensitivityDimension,
+    workers int,
+) (
+    SensitivityGrid,
+    error,
+) {
+    grid := SensitivityGrid{Rows: rows, Columns: columns}
+
+    // Data Validation
+    for _, dimension := range []SensitivityDimension{rows, columns} {
+        switch dimension.Input {
+        case ExitCapRateInput, CostOfSaleInput, InterestRateInput,
+            PurchasePriceInput, RevenueGrowthInput, ExpenseGrowthInput:
+        default:
+            return grid, fmt.Errorf("Unknown sensitivity input: %v.", dimension.Input)
+        }
+        if len(dimension.Values) == 0 {
+            return grid, fmt.Errorf("The %v dimension must have at least one value.", dimension.Input)
+        }
+    }
+    if rows.Input == columns.Input {
+        return grid, fmt.Errorf("The rows and the columns must be different inputs.")
+    }
+    if (rows.Input == InterestRateInput || columns.Input == InterestRateInput) && roi.loanMetrics.FloatingRate != nil {
+        return grid, fmt.Errorf("The interest rate is not used with a floating rate loan.")
+    }
+    if workers < 0 {
+        return grid, fmt.Errorf("The number of workers cannot be lower than 0.")
+    }
+    if workers == 0 {
+        workers = runtime.NumCPU()
+    }
+
+    grid.Cells = make([][]SensitivityCell, len(rows.Values))
+    for i := range grid.Cells {
+        grid.Cells[i] = make([]SensitivityCell, len(columns.Values))
+    }
+
+    // every worker writes only to the cells it takes from the jobs channel.
+    type job struct {
+        row     int
+        column  int
+    }
+    jobs := make(chan job)
+    var wg sync.WaitGroup
+    for w := 0; w < workers; w++ {
+        wg.Add(1)
+        go func () {
+            defer wg.Done()
+            for j := range jobs {
+                grid.Cells[j.row][j.column] = roi.sensitivity_cell(
+                    rows,
+                    rows.Values[j.row],
+                    columns,
+                    columns.Values[j.column],
+                )
+            }
+        }()
+    }
+    for i := range rows.Values {
+        for j := range columns.Values {
+            jobs <- job{i, j}
+        }
+    }
+    close(jobs)
+    wg.Wait()
+    return grid, nil
+}
+
+// Values returns the given metric (LeveredIRR, EquityMultiple or
+// MinimumDSCR) of every cell of the grid.
+func (grid SensitivityGrid) Values (metric ReturnTarget) ([][]float64, error) {
+    values := make([][]float64, len(grid.Cells))
+    for i, row := range grid.Cells {
+        values[i] = make([]float64, len(row))
+        for j, cell := range row {
+            switch metric {
+            case LeveredIRRTarget:
+                values[i][j] = cell.LeveredIRR
+            case EquityMultipleTarget:
+                values[i][j] = cell.EquityMultiple
+            case MinimumDSCRTarget:
+                values[i][j] = cell.MinimumDSCR
+            default:
+                return nil, fmt.Errorf("The metric must be LeveredIRRTarget, EquityMultipleTarget or MinimumDSCRTarget.")
+            }
+        }
+    }
+    return values, nil
+}
+
+// Table returns the given metric of the grid as rows of text, ready to be
+// rendered. The first row has the names of the inputs and the values of the
+// columns, and every other row starts with its value. The cells that could
+// not be evaluated are shown as "error".
+func (grid SensitivityGrid) Table (metric ReturnTarget) ([][]string, error) {
+    values, err := grid.Values(metric)
+    if err != nil {
+        return nil, fmt.Errorf("Values internal error: %v", err)
+    }
+    header := []string{fmt.Sprintf("%v \\ %v", grid.Rows.Input, grid.Columns.Input)}
+    for _, value := range grid.Columns.Values {
+        header = append(header, strconv.FormatFloat(value, 'f', -1, 64))
+    }
+    table := [][]string{header}
+    for i, row := range values {
+        line := []string{strconv.FormatFloat(grid.Rows.Values[i], 'f', -1, 64)}
+        for j, value := range row {
+            if grid.Cells[i][j].Err != nil {
+                line = append(line, "error")
+                continue
+            }
+            line = append(line, strconv.FormatFloat(value, 'f', 4, 64))
+        }
+        table = append(table, line)
+    }
+    return table, nil
+}
@@ -0,0 +1,214 @@
+package investment_analysis
+import (
+    "testing";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+    ls "github.com/jacobitosuperstar/go-cre-loan-calculations/loan_sizer";
+)
+
+func TestSensitivity(t *testing.T) {
+    roi, err := newTestROI(baseTestROI)
+    if err != nil {
+        t.Errorf("ReturnOfInvestment internal error: %v", err)
+        return
+    }
+    rows := SensitivityDimension{ExitCapRateInput, []float64{0.060, 0.065, 0.070}}
+    columns := SensitivityDimension{InterestRateInput, []float64{0.040, 0.045, 0.050}}
+
+    grid, err := roi.Sensitivity(rows, columns, 4)
+    if err != nil {
+        t.Errorf("Sensitivity internal error: %v", err)
+        return
+    }
+
+    var testCases = []struct {
+        name string
+        row int
+        column int
+        wantIRR float64
+        wantEquityMultiple float64
+        wantMinimumDSCR float64
+    }{
+        // the base deal, same metrics as TestReturnMetrics. The lowest DSCR
+        // is on the year 1, the fixed rate loan charges the amortizing
+        // payment from the first year, IO period included.
+        {"Base deal", 1, 1, 0.1223, 2.6714, 1.3872},
+        {"Low exit cap rate and low interest rate", 0, 0, 0.1384, 3.0212, 1.4727},
+        {"High exit cap rate and high interest rate", 2, 2, 0.1062, 2.3609, 1.3092},
+        {"Exit cap rate does not move the DSCR", 2, 1, 0.1113, 2.4312, 1.3872},
+    }
+    for _, test := range testCases {
+        t.Run(test.name, func(t *testing.T) {
+            cell := grid.Cells[test.row][test.column]
+            if cell.Err != nil {
+                t.Errorf("Cell internal error: %v", cell.Err)
+                return
+            }
+            if cell.RowValue != rows.Values[test.row] || cell.ColumnValue != columns.Values[test.column] {
+                t.Errorf("Cell values got: %v %v, wanted: %v %v", cell.RowValue, cell.ColumnValue, rows.Values[test.row], columns.Values[test.column])
+            }
+            if !utils.Tolerance(cell.LeveredIRR, test.wantIRR, 0.01) {
+                t.Errorf("LeveredIRR got: %v, wanted: %v", cell.LeveredIRR, test.wantIRR)
+            }
+            if !utils.Tolerance(cell.EquityMultiple, test.wantEquityMultiple, 0.01) {
+                t.Errorf("EquityMultiple got: %v, wanted: %v", cell.EquityMultiple, test.wantEquityMultiple)
+            }
+            if !utils.Tolerance(cell.MinimumDSCR, test.wantMinimumDSCR, 0.01) {
+                t.Errorf("MinimumDSCR got: %v, wanted: %v", cell.MinimumDSCR, test.wantMinimumDSCR)
+            }
+        })
+    }
+
+    t.Run("Sale after the maturity of the loan", func(t *testing.T) {
+        late_sale, err := roi.WithHoldPastMaturity().with_sale_year(12)
+        if err != nil {
+            t.Errorf("with_sale_year internal error: %v", err)
+            return
+        }
+        late_grid, err := late_sale.Sensitivity(rows, columns, 4)
+        if err != nil {
+            t.Errorf("Sensitivity internal error: %v", err)
+            return
+        }
+        // the payoff of the loan on the year 10 is not debt service, and the
+        // years after the maturity have none.
+        for i := range grid.Cells {
+            for j := range grid.Cells[i] {
+                if late_grid.Cells[i][j].MinimumDSCR != grid.Cells[i][j].MinimumDSCR {
+                    t.Errorf("Cell %v %v MinimumDSCR got: %v, wanted: %v", i, j, late_grid.Cells[i][j].MinimumDSCR, grid.Cells[i][j].MinimumDSCR)
+                }
+            }
+        }
+    })
+
+    t.Run("Same grid with a single worker", func(t *testing.T) {
+        serial, err := roi.Sensitivity(rows, columns, 1)
+        if err != nil {
+            t.Errorf("Sensitivity internal error: %v", err)
+            return
+        }
+        for i := range grid.Cells {
+            for j := range grid.Cells[i] {
+                if serial.Cells[i][j] != grid.Cells[i][j] {
+                    t.Errorf("Cell %v %v got: %+v, wanted: %+v", i, j, serial.Cells[i][j], grid.Cells[i][j])
+                }
+            }
+        }
+    })
+
+    t.Run("The deal is not changed", func(t *testing.T) {
+        got, err := roi.ReturnMetrics()
+        if err != nil {
+            t.Errorf("ReturnMetrics internal error: %v", err)
+            return
+        }
+        if got.LeveredIRR != grid.Cells[1][1].LeveredIRR {
+            t.Errorf("LeveredIRR got: %v, wanted: %v", got.LeveredIRR, grid.Cells[1][1].LeveredIRR)
+        }
+    })
+
+    t.Run("Purchase price and revenue growth", func(t *testing.T) {
+        got, err := roi.Sensitivity(
+            SensitivityDimension{PurchasePriceInput, []float64{6000000, 6500000, 7000000}},
+            SensitivityDimension{RevenueGrowthInput, []float64{0.025, 0.035}},
+            0,
+        )
+        if err != nil {
+            t.Errorf("Sensitivity internal error: %v", err)
+            return
+        }
+        if !utils.Tolerance(got.Cells[1][1].LeveredIRR, 0.1223, 0.01) {
+            t.Errorf("Base LeveredIRR got: %v, wanted: %v", got.Cells[1][1].LeveredIRR, 0.1223)
+        }
+        for i := range got.Cells {
+            if got.Cells[i][0].LeveredIRR >= got.Cells[i][1].LeveredIRR {
+                t.Errorf("Row %v LeveredIRR got: %v >= %v, wanted lower with less growth", i, got.Cells[i][0].LeveredIRR, got.Cells[i][1].LeveredIRR)
+            }
+            if i > 0 && got.Cells[i][1].LeveredIRR >= got.Cells[i-1][1].LeveredIRR {
+                t.Errorf("Row %v LeveredIRR got: %v >= %v, wanted lower with a higher price", i, got.Cells[i][1].LeveredIRR, got.Cells[i-1][1].LeveredIRR)
+            }
+        }
+    })
+
+    t.Run("Invalid cell", func(t *testing.T) {
+        got, err := roi.Sensitivity(
+            SensitivityDimension{ExitCapRateInput, []float64{0, 0.065}},
+            SensitivityDimension{ExpenseGrowthInput, []float64{0.025}},
+            2,
+        )
+        if err != nil {
+            t.Errorf("Sensitivity internal error: %v", err)
+            return
+        }
+        if got.Cells[0][0].Err == nil {
+            t.Errorf("Cell with a 0 exit cap rate got no error, wanted an error")
+        }
+        if got.Cells[1][0].Err != nil {
+            t.Errorf("Cell internal error: %v", got.Cells[1][0].Err)
+        }
+        table, err := got.Table(LeveredIRRTarget)
+        if err != nil {
+            t.Errorf("Table internal error: %v", err)
+            return
+        }
+        want := [][]string{
+            {"exit_cap_rate \\ expense_growth", "0.025"},
+            {"0", "error"},
+            {"0.065", "0.1223"},
+        }
+        for i := range want {
+            for j := range want[i] {
+                if table[i][j] != want[i][j] {
+                    t.Errorf("Table %v %v got: %v, wanted: %v", i, j, table[i][j], want[i][j])
+                }
+            }
+        }
+    })
+
+    var errorCases = []struct {
+        name string
+        rows SensitivityDimension
+        columns SensitivityDimension
+        workers int
+    }{
+        {"Unknown input", SensitivityDimension{"vacancy", []float64{0.05}}, columns, 1},
+        {"No values", rows, SensitivityDimension{InterestRateInput, nil}, 1},
+        {"Same input", rows, rows, 1},
+        {"Negative workers", rows, columns, -1},
+    }
+    for _, test := range errorCases {
+        t.Run(test.name, func(t *testing.T) {
+            if _, err := roi.Sensitivity(test.rows, test.columns, test.workers); err == nil {
+                t.Errorf("Sensitivity got no error, wanted an error")
+            }
+        })
+    }
+
+    t.Run("Interest rate of a floating rate loan", func(t *testing.T) {
+        floating, err := roi.WithFloatingRate(ls.FloatingRate{
+            ForwardCurve: []float64{0.040, 0.050, 0.060},
+            Spread: 0.02,
+        })
+        if err != nil {
+            t.Errorf("WithFloatingRate internal error: %v", err)
+            return
+        }
+        if _, err := floating.Sensitivity(rows, columns, 1); err == nil {
+            t.Errorf("Sensitivity got no error, wanted an error")
+        }
+        if _, err := floating.with_input(InterestRateInput, 0.05); err == nil {
+            t.Errorf("with_input got no error, wanted an error")
+        }
+    })
+
+    t.Run("Interest rate out of range", func(t *testing.T) {
+        if _, err := roi.with_input(InterestRateInput, 1.5); err == nil {
+            t.Errorf("with_input got no error, wanted an error")
+        }
+    })
+
+    t.Run("Unknown metric", func(t *testing.T) {
+        if _, err := grid.Values(ProfitTarget); err == nil {
+            t.Errorf("Values got no error, wanted an error")
+        }
+    })
+}
@@ -0,0 +1,340 @@
+// Equity waterfall of the deal. The net cash flows of the projection are
+// split between the limited partners (LP) and the general partner (GP), with
+// the GP receiving a promote over its share of the equity once the LP reaches
+// each hurdle.
+
+package investment_analysis
+
+import (
+    "fmt";
+    "math";
+    ff "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/financial_formulas";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+)
+
+// WaterfallStyle is the way the hurdles of the waterfall are measured.
+type WaterfallStyle string
+
+const (
+    EuropeanWaterfall   WaterfallStyle = "european"
+    AmericanWaterfall   WaterfallStyle = "american"
+)
+
+// HurdleType is the metric of the LP that a hurdle is measured on.
+type HurdleType string
+
+const (
+    IRRHurdle               HurdleType = "irr"
+    EquityMultipleHurdle    HurdleType = "equity_multiple"
+)
+
+// Hurdle is a tier of the waterfall. Once the LP reaches the Rate (an IRR or
+// an equity multiple), the GP receives the Promote of the distributions above
+// it, and its share of the equity of the rest.
+type Hurdle struct {
+    Type        HurdleType
+    Rate        float64
+    Promote     float64
+}
+
+// Waterfall is a struct with the terms of the equity waterfall.
+//
+// LPShare is the share of the equity contributed by the LP, the rest is
+// contributed by the GP. The Hurdles are applied in order, below the first
+// hurdle the distributions are split by the equity shares. On a European
+// waterfall the LP gets back its capital and the return of every hurdle before
+// the GP is promoted. On an American waterfall the distributions before the
+// last year only need to pay the accrued return of the IRR hurdles to promote
+// the GP, the capital and the equity multiple hurdles are met at the last
+// year. CatchUp is the share of the distributions that goes to the GP, after
+// the first hurdle, until the GP has its promote of the profits, a zero value
+// means no catch up. When CompoundPreferred is false the return of the IRR
+// hurdles accrues only on the capital and not on the unpaid return.
+type Waterfall struct {
+    LPShare             float64
+    Hurdles             []Hurdle
+    Style               WaterfallStyle
+    CatchUp             float64
+    CompoundPreferred   bool
+}
+
+// NewWaterfall returns a Waterfall struct if the values given are valid. If
+// not, returns a default struct with the error.
+func NewWaterfall(
+    lpShare float64,
+    hurdles []Hurdle,
+    style WaterfallStyle,
+    catchUp float64,
+    compoundPreferred bool,
+) (
+    Waterfall,
+    error,
+) {
+    // Data Validation
+    if lpShare <= 0 || lpShare > 1 {
+        return Waterfall{}, fmt.Errorf("The lpShare must be greater than 0 and up to 1.")
+    }
+    if style != EuropeanWaterfall && style != AmericanWaterfall {
+        return Waterfall{}, fmt.Errorf("The style must be EuropeanWaterfall or AmericanWaterfall.")
+    }
+    last_rate := map[HurdleType]float64{}
+    for _, hurdle := range hurdles {
+        switch hurdle.Type {
+        case IRRHurdle:
+            if hurdle.Rate < 0 {
+                return Waterfall{}, fmt.Errorf("The rate of an IRR hurdle cannot be lower than 0.")
+            }
+        case EquityMultipleHurdle:
+            if hurdle.Rate < 1 {
+                return Waterfall{}, fmt.Errorf("The rate of an equity multiple hurdle cannot be lower than 1.")
+            }
+        default:
+            return Waterfall{}, fmt.Errorf("The type of a hurdle must be IRRHurdle or EquityMultipleHurdle.")
+        }
+        if rate, ok := last_rate[hurdle.Type]; ok && hurdle.Rate <= rate {
+            return Waterfall{}, fmt.Errorf("The hurdles of the same type must have increasing rates.")
+        }
+        last_rate[hurdle.Type] = hurdle.Rate
+        if hurdle.Promote < 0 || hurdle.Promote >= 1 {
+            return Waterfall{}, fmt.Errorf("The promote of a hurdle must be between 0 and 1, 1 excluded.")
+        }
+    }
+    if catchUp < 0 || catchUp > 1 {
+        return Waterfall{}, fmt.Errorf("The catchUp must be between 0 and 1.")
+    }
+    if catchUp > 0 {
+        if len(hurdles) == 0 {
+            return Waterfall{}, fmt.Errorf("A catch up needs at least one hurdle.")
+        }
+        gp_share := hurdles[0].Promote + (1 - hurdles[0].Promote) * (1 - lpShare)
+        if catchUp <= gp_share {
+            return Waterfall{}, fmt.Errorf("The catchUp must be greater than the share of the GP after the first hurdle.")
+        }
+    }
+    // Struct Creation
+    waterfall := Waterfall{
+        LPShare: lpShare,
+        Hurdles: hurdles,
+        Style: style,
+        CatchUp: catchUp,
+        CompoundPreferred: compoundPreferred,
+    }
+    return waterfall, nil
+}
+
+// PartnerResult is a struct with the cash flows of a partner of the
+// waterfall, with its IRR, equity multiple and profit. The IRR and the
+// equity multiple are 0 if the partner contributed no equity.
+type PartnerResult struct {
+    CashFlows       []float64
+    Contributions   float64
+    Distributions   float64
+    IRR             float64
+    EquityMultiple  float64
+    Profit          float64
+}
+
+// WaterfallResult is a struct with the outcome of the waterfall. The Promote
+// is the money received by the GP above its share of the equity.
+type WaterfallResult struct {
+    LP          PartnerResult
+    GP          PartnerResult
+    Promote     float64
+}
+
+// hurdle_balance is what the LP is owed to reach an IRR hurdle, split into
+// the capital and the accrued return.
+type hurdle_balance struct {
+    capital     float64
+    accrued     float64
+}
+
+// waterfall_state keeps the balances of the partners along the waterfall.
+type waterfall_state struct {
+    balances            []hurdle_balance
+    lp_contributions    float64
+    lp_distributions    float64
+    gp_contributions    float64
+    gp_distributions    float64
+}
+
+// accrue adds the return of a year to the IRR hurdles.
+func (w Waterfall) accrue (state *waterfall_state) {
+    for k, hurdle := range w.Hurdles {
+        if hurdle.Type != IRRHurdle {
+            continue
+        }
+        balance := &state.balances[k]
+        if w.CompoundPreferred {
+            balance.accrued += (balance.capital + balance.accrued) * hurdle.Rate
+        } else {
+            balance.accrued += balance.capital * hurdle.Rate
+        }
+    }
+}
+
+// pay_lp records a distribution to the LP, it pays the accrued return of
+// every IRR hurdle first and then its capital.
+func (state *waterfall_state) pay_lp (amount float64) {
+    state.lp_distributions += amount
+    for k := range state.balances {
+        balance := &state.balances[k]
+        paid_return := math.Min(amount, balance.accrued)
+        balance.accrued -= paid_return
+        balance.capital -= amount - paid_return
+    }
+}
+
+// lp_need returns the money the LP still needs to reach the hurdle k. Before
+// the last year of an American waterfall only the accrued return of the IRR
+// hurdles is needed.
+func (w Waterfall) lp_need (state *waterfall_state, k int, last bool) float64 {
+    hurdle := w.Hurdles[k]
+    american := w.Style == AmericanWaterfall && !last
+    switch hurdle.Type {
+    case IRRHurdle:
+        balance := state.balances[k]
+        if american {
+            return math.Max(balance.accrued, 0)
+        }
+        return math.Max(balance.capital + balance.accrued, 0)
+    case EquityMultipleHurdle:
+        if american {
+            return 0
+        }
+        return math.Max(hurdle.Rate * state.lp_contributions - state.lp_distributions, 0)
+    }
+    return 0
+}
+
+// lp_split returns the share of the LP of the distributions below the hurdle
+// k, and above every hurdle when k is the number of hurdles.
+func (w Waterfall) lp_split (k int) float64 {
+    if k == 0 {
+        return w.LPShare
+    }
+    return (1 - w.Hurdles[k - 1].Promote) * w.LPShare
+}
+
+// catch_up returns the money, of the given distribution, that is needed for
+// the GP to catch up with its promote of the profits after the first hurdle.
+func (w Waterfall) catch_up (state *waterfall_state) float64 {
+    gp_share := 1 - w.lp_split(1)
+    profit := state.lp_distributions + state.gp_distributions - state.lp_contributions - state.gp_contributions
+    catch_up := (state.gp_contributions + gp_share * profit - state.gp_distributions) / (w.CatchUp - gp_share)
+    return math.Max(catch_up, 0)
+}
+
+// distribute splits a positive cash flow between the partners, tier by tier.
+// Returns the money given to the LP and to the GP.
+func (w Waterfall) distribute (state *waterfall_state, cashFlow float64, last bool) (lp float64, gp float64) {
+    remaining := cashFlow
+    pay := func (amount float64, lp_share float64) {
+        lp_amount := amount * lp_share
+        state.pay_lp(lp_amount)
+        state.gp_distributions += amount - lp_amount
+        lp += lp_amount
+        gp += amount - lp_amount
+        remaining -= amount
+    }
+    for k := 0; k <= len(w.Hurdles) && remaining > 0; k++ {
+        lp_share := w.lp_split(k)
+        amount := remaining
+        if k < len(w.Hurdles) {
+            amount = math.Min(remaining, w.lp_need(state, k, last) / lp_share)
+        }
+        pay(amount, lp_share)
+        if k == 0 && w.CatchUp > 0 && remaining > 0 {
+            pay(math.Min(remaining, w.catch_up(state)), 1 - w.CatchUp)
+        }
+    }
+    return lp, gp
+}
+
+// partner_result returns the PartnerResult of the given cash flows.
+func partner_result (cashFlows []float64, contributions float64, distributions float64) (PartnerResult, error) {
+    result := PartnerResult{
+        CashFlows: cashFlows,
+        Contributions: utils.Round2(contributions),
+        Distributions: utils.Round2(distributions),
+        Profit: utils.Round2(distributions - contributions),
+    }
+    if contributions <= 0 {
+        return result, nil
+    }
+    irr, err := ff.InternalRateOfReturn(cashFlows)
+    if err != nil {
+        return result, fmt.Errorf("InternalRateOfReturn internal error: %v", err)
+    }
+    result.IRR = irr
+    result.EquityMultiple = utils.Round4(distributions / contributions)
+    return result, nil
+}
+
+// Distribute returns the WaterfallResult of the given yearly net cash flows,
+// starting at year 0. Negative cash flows are contributions of equity, split
+// by the equity shares, and positive ones are distributions.
+func (w Waterfall) Distribute (cashFlows []float64) (WaterfallResult, error) {
+    var result WaterfallResult
+
+    state := &waterfall_state{balances: make([]hurdle_balance, len(w.Hurdles))}
+    lp_cash_flows := make([]float64, len(cashFlows))
+    gp_cash_flows := make([]float64, len(cashFlows))
+    for year, cash_flow := range cashFlows {
+        if year > 0 {
+            w.accrue(state)
+        }
+        if cash_flow < 0 {
+            lp_contribution := - cash_flow * w.LPShare
+            state.lp_contributions += lp_contribution
+            state.gp_contributions += - cash_flow - lp_contribution
+            for k := range state.balances {
+                state.balances[k].capital += lp_contribution
+            }
+            lp_cash_flows[year] = utils.Round2(- lp_contribution)
+            gp_cash_flows[year] = utils.Round2(cash_flow + lp_contribution)
+            continue
+        }
+        lp, gp := w.distribute(state, cash_flow, year == len(cashFlows) - 1)
+        lp_cash_flows[year] = utils.Round2(lp)
+        gp_cash_flows[year] = utils.Round2(gp)
+    }
+
+    lp, err := partner_result(lp_cash_flows, state.lp_contributions, state.lp_distributions)
+    if err != nil {
+        return result, fmt.Errorf("LP partner_result internal error: %v", err)
+    }
+    gp, err := partner_result(gp_cash_flows, state.gp_contributions, state.gp_distributions)
+    if err != nil {
+        return result, fmt.Errorf("GP partner_result internal error: %v", err)
+    }
+    result.LP = lp
+    result.GP = gp
+    // the GP would have received its share of the equity of the profits.
+    profit := state.lp_distributions + state.gp_distributions - state.lp_contributions - state.gp_contributions
+    result.Promote = utils.Round2(gp.Profit - (1 - w.LPShare) * profit)
+    return result, nil
+}
+
+// DistributeProjection returns the WaterfallResult of the output of the
+// NetCashFlowProjection, using the "net_cash_flow" of every row.
+func (w Waterfall) DistributeProjection (netCashFlowProjection []map[string]interface{}) (WaterfallResult, error) {
+    cash_flows := make([]float64, len(netCashFlowProjection))
+    for i, row := range netCashFlowProjection {
+        net_cash_flow, ok := row["net_cash_flow"].(float64)
+        if !ok {
+            return WaterfallResult{}, fmt.Errorf("The row %d of the projection has no net_cash_flow.", i)
+        }
+        cash_flows[i] = net_cash_flow
+    }
+    return w.Distribute(cash_flows)
+}
+
+// Waterfall returns the WaterfallResult of the net cash flows of the Deal.
+func (roi ReturnOfInvestment) Waterfall (w Waterfall) (WaterfallResult, error) {
+    projection, err := roi.Projection()
+    if err != nil {
+        return WaterfallResult{}, fmt.Errorf("Projection internal error: %v", err)
+    }
+    return w.Distribute(projection.CashFlows())
+}
@@ -0,0 +1,172 @@
+package investment_analysis
+import (
+    "testing";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+)
+
+func TestWaterfall(t *testing.T) {
+    var testCases = []struct {
+        name string
+        waterfall Waterfall
+        cashFlows []float64
+        wantLP []float64
+        wantGP []float64
+        wantPromote float64
+    }{
+        {
+            name: "European with two IRR hurdles",
+            waterfall: Waterfall{
+                LPShare: 0.90,
+                Hurdles: []Hurdle{{IRRHurdle, 0.08, 0.20}, {IRRHurdle, 0.15, 0.30}},
+                Style: EuropeanWaterfall,
+                CompoundPreferred: true,
+            },
+            cashFlows: []float64{-1000, 0, 0, 1500},
+            wantLP: []float64{-900, 0, 0, 1306.75},
+            wantGP: []float64{-100, 0, 0, 193.25},
+            wantPromote: 43.25,
+        },
+        {
+            name: "LP exactly at the hurdle",
+            waterfall: Waterfall{
+                LPShare: 0.90,
+                Hurdles: []Hurdle{{IRRHurdle, 0.08, 0.20}},
+                Style: EuropeanWaterfall,
+                CompoundPreferred: true,
+            },
+            cashFlows: []float64{-1000, 80, 80, 1080},
+            wantLP: []float64{-900, 72, 72, 972},
+            wantGP: []float64{-100, 8, 8, 108},
+            wantPromote: 0,
+        },
+        {
+            name: "Full catch up",
+            waterfall: Waterfall{
+                LPShare: 1,
+                Hurdles: []Hurdle{{IRRHurdle, 0.08, 0.20}},
+                Style: EuropeanWaterfall,
+                CatchUp: 1,
+                CompoundPreferred: true,
+            },
+            cashFlows: []float64{-1000, 0, 0, 1500},
+            wantLP: []float64{-1000, 0, 0, 1400},
+            wantGP: []float64{0, 0, 0, 100},
+            wantPromote: 100,
+        },
+        {
+            name: "European with simple preferred return",
+            waterfall: Waterfall{
+                LPShare: 1,
+                Hurdles: []Hurdle{{IRRHurdle, 0.08, 0.20}},
+                Style: EuropeanWaterfall,
+            },
+            cashFlows: []float64{-1000, 150, 150, 1150},
+            wantLP: []float64{-1000, 150, 150, 1104.55},
+            wantGP: []float64{0, 0, 0, 45.45},
+            wantPromote: 45.45,
+        },
+        {
+            name: "American with simple preferred return",
+            waterfall: Waterfall{
+                LPShare: 1,
+                Hurdles: []Hurdle{{IRRHurdle, 0.08, 0.20}},
+                Style: AmericanWaterfall,
+            },
+            cashFlows: []float64{-1000, 150, 150, 1150},
+            wantLP: []float64{-1000, 136, 135.10, 1111.03},
+            wantGP: []float64{0, 14, 14.90, 38.97},
+            wantPromote: 67.86,
+        },
+        {
+            name: "Equity multiple hurdle",
+            waterfall: Waterfall{
+                LPShare: 1,
+                Hurdles: []Hurdle{{EquityMultipleHurdle, 1.5, 0.30}},
+                Style: EuropeanWaterfall,
+            },
+            cashFlows: []float64{-1000, 0, 2000},
+            wantLP: []float64{-1000, 0, 1850},
+            wantGP: []float64{0, 0, 150},
+            wantPromote: 150,
+        },
+    }
+
+    for _, test := range testCases {
+        t.Run(test.name, func(t *testing.T) {
+            got, err := test.waterfall.Distribute(test.cashFlows)
+            if err != nil {
+                t.Errorf("Distribute internal error: %v", err)
+                return
+            }
+            for i := range test.cashFlows {
+                if !utils.Tolerance(got.LP.CashFlows[i], test.wantLP[i], 0.01) {
+                    t.Errorf("LP year %d got: %g, wanted: %g", i, got.LP.CashFlows[i], test.wantLP[i])
+                }
+                if !utils.Tolerance(got.GP.CashFlows[i], test.wantGP[i], 0.01) {
+                    t.Errorf("GP year %d got: %g, wanted: %g", i, got.GP.CashFlows[i], test.wantGP[i])
+                }
+            }
+            if !utils.Tolerance(got.Promote, test.wantPromote, 0.01) {
+                t.Errorf("Promote got: %g, wanted: %g", got.Promote, test.wantPromote)
+            }
+        })
+    }
+
+    t.Run("Partner metrics", func(t *testing.T) {
+        got, err := testCases[0].waterfall.Distribute(testCases[0].cashFlows)
+        if err != nil {
+            t.Errorf("Distribute internal error: %v", err)
+            return
+        }
+        // (1306.75 / 900)^(1/3) - 1
+        if got.LP.IRR != 0.1324 || got.LP.EquityMultiple != 1.4519 {
+            t.Errorf("LP got: %v %v, wanted: 0.1324 1.4519", got.LP.IRR, got.LP.EquityMultiple)
+        }
+        if got.GP.EquityMultiple != 1.9325 {
+            t.Errorf("GP EquityMultiple got: %v, wanted: 1.9325", got.GP.EquityMultiple)
+        }
+    })
+
+    t.Run("Projection of the deal", func(t *testing.T) {
+        roi, err := newTestROI(baseTestROI)
+        if err != nil {
+            t.Errorf("ReturnOfInvestment internal error: %v", err)
+            return
+        }
+        ncfp, err := roi.NetCashFlowProjection()
+        if err != nil {
+            t.Errorf("NetCashFlowProjection internal error: %v", err)
+            return
+        }
+        got, err := testCases[0].waterfall.DistributeProjection(ncfp)
+        if err != nil {
+            t.Errorf("DistributeProjection internal error: %v", err)
+            return
+        }
+        for i, row := range ncfp {
+            total := got.LP.CashFlows[i] + got.GP.CashFlows[i]
+            if !utils.Tolerance(total, row["net_cash_flow"].(float64), 0.02) {
+                t.Errorf("year %d LP + GP got: %g, wanted: %g", i, total, row["net_cash_flow"])
+            }
+        }
+        if got.Promote <= 0 {
+            t.Errorf("Promote got: %g, wanted a promote", got.Promote)
+        }
+    })
+
+    t.Run("Invalid values", func(t *testing.T) {
+        hurdles := []Hurdle{{IRRHurdle, 0.08, 0.20}}
+        if _, err := NewWaterfall(0, hurdles, EuropeanWaterfall, 0, true); err == nil {
+            t.Errorf("expected an error for a zero lpShare")
+        }
+        if _, err := NewWaterfall(0.9, hurdles, "asian", 0, true); err == nil {
+            t.Errorf("expected an error for an unknown style")
+        }
+        if _, err := NewWaterfall(0.9, []Hurdle{{IRRHurdle, 0.12, 0.2}, {IRRHurdle, 0.08, 0.3}}, EuropeanWaterfall, 0, true); err == nil {
+            t.Errorf("expected an error for decreasing hurdles")
+        }
+        if _, err := NewWaterfall(0.9, hurdles, EuropeanWaterfall, 0.2, true); err == nil {
+            t.Errorf("expected an error for a catch up below the share of the GP")
+        }
+    })
+}
@@ -0,0 +1,326 @@
+// Construction loans. The loan is funded month by month against the draw
+// schedule of the project, after the equity of the borrower, and at
+// stabilization it is paid off by a permanent loan.
+
+package loan_sizer
+
+import (
+    "fmt"
+    "math"
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+)
+
+// Maximum number of passes used to size a construction loan whose interest is
+// capitalized, as the interest reserve is part of the cost it funds.
+const constructionMaxIterations = 100
+
+// LoanPhase is the phase of the loan on which a period of the combined
+// schedule happens.
+type LoanPhase string
+
+const (
+    ConstructionPhase   LoanPhase = "construction"
+    PermanentPhase      LoanPhase = "permanent"
+)
+
+// ConstructionLoan creates a struct that has all the information of a
+// construction loan.
+//
+// DrawSchedule has the cost of the project spent on every month, starting at
+// month 1. Rate is the annual rate of the loan, accrued monthly on the drawn
+// balance. The commitment of the loan is the lowest of MaxLTC times the total
+// cost and MaxLTV times the StabilizedValue. When InterestReserve is true the
+// interest is capitalized, it is a cost of the project funded by the loan,
+// if not it is paid every month by the borrower. At the ConversionMonth the
+// loan is paid off by the Permanent loan, sized on the StabilizedValue.
+type ConstructionLoan struct {
+    DrawSchedule        []float64
+    Rate                float64
+    MaxLTC              float64
+    MaxLTV              float64
+    StabilizedValue     int
+    InterestReserve     bool
+    ConversionMonth     int
+    Permanent           LoanSizer
+}
+
+// ConstructionPeriod is a month of the construction loan. The Cost of the
+// month is funded first with the EquityDraw and then with the LoanDraw. The
+// Interest is accrued on the balance at the start of the month, and the
+// InterestReserveDraw is the part of it that was capitalized.
+type ConstructionPeriod struct {
+    Month                   int
+    Cost                    float64
+    EquityDraw              float64
+    LoanDraw                float64
+    Interest                float64
+    InterestReserveDraw     float64
+    Balance                 float64
+}
+
+// LoanPeriod is a period of the combined schedule of the construction and the
+// permanent loan. Month is the month, since the closing of the construction
+// loan, at the end of which the period happens. Draws and the Balance are
+// positive, Interest and Principal payments are negative.
+type LoanPeriod struct {
+    Month       int
+    Phase       LoanPhase
+    Draw        float64
+    Interest    float64
+    Principal   float64
+    Balance     float64
+}
+
+// ConstructionSchedule is a struct with the outcome of the construction loan.
+// The ConversionPaydown is the money the borrower puts at the conversion when
+// the permanent loan is lower than the balance of the construction loan.
+type ConstructionSchedule struct {
+    TotalCost               float64
+    Commitment              float64
+    BindingConstraint       SizingConstraint
+    Equity                  float64
+    InterestReserve         float64
+    InterestPaid            float64
+    BalanceAtConversion     float64
+    PermanentLoanAmount     float64
+    ConversionPaydown       float64
+    Construction            []ConstructionPeriod
+    Periods                 []LoanPeriod
+}
+
+// Constructor
+
+// NewConstructionLoan returns a ConstructionLoan struct if the values given
+// are valid. If not, returns a default struct with the error.
+func NewConstructionLoan(
+    drawSchedule []float64,
+    interestRate float64,
+    maxLTC float64,
+    maxLTV float64,
+    stabilizedValue int,
+    interestReserve bool,
+    conversionMonth int,
+    permanent LoanSizer,
+) (
+    ConstructionLoan,
+    error,
+) {
+    // Data Validation
+    total_cost := 0.0
+    for _, draw := range drawSchedule {
+        if draw < 0 {
+            return ConstructionLoan{}, fmt.Errorf("The draws of the drawSchedule cannot be lower than 0.")
+        }
+        total_cost += draw
+    }
+    if total_cost <= 0 {
+        return ConstructionLoan{}, fmt.Errorf("The drawSchedule must have a cost greater than 0.")
+    }
+    if interestRate < 0 || interestRate > 1 {
+        return ConstructionLoan{}, fmt.Errorf("The interest rate of a loan must be between 0 and 1.")
+    }
+    if maxLTC < 0 || maxLTC > 1 {
+        return ConstructionLoan{}, fmt.Errorf("The loan to cost ratio must be between 0 and 1.")
+    }
+    if maxLTV < 0 || maxLTV > 1 {
+        return ConstructionLoan{}, fmt.Errorf("The loan to value ratio must be between 0 and 1.")
+    }
+    if stabilizedValue <= 0 {
+        return ConstructionLoan{}, fmt.Errorf("The stabilizedValue must be greater than 0.")
+    }
+    if conversionMonth < len(drawSchedule) {
+        return ConstructionLoan{}, fmt.Errorf("The conversionMonth cannot be before the last draw.")
+    }
+    if permanent.Term <= 0 {
+        return ConstructionLoan{}, fmt.Errorf("The permanent loan must have a term greater than 0.")
+    }
+    // Struct Creation
+    cl := ConstructionLoan{
+        DrawSchedule: drawSchedule,
+        Rate: interestRate,
+        MaxLTC: maxLTC,
+        MaxLTV: maxLTV,
+        StabilizedValue: stabilizedValue,
+        InterestReserve: interestReserve,
+        ConversionMonth: conversionMonth,
+        Permanent: permanent,
+    }
+    return cl, nil
+}
+
+// Internal
+
+// hard_cost returns the cost of the draw schedule.
+func (cl ConstructionLoan) hard_cost () float64 {
+    hard_cost := 0.0
+    for _, draw := range cl.DrawSchedule {
+        hard_cost += draw
+    }
+    return utils.Round2(hard_cost)
+}
+
+// commitment returns the maximum amount of the loan for the given total cost
+// of the project, with the constraint that sets it.
+func (cl ConstructionLoan) commitment (totalCost float64) loan_candidate {
+    ltc := loan_candidate{LTCConstraint, math.Floor(cl.MaxLTC * totalCost)}
+    ltv := loan_candidate{LTVConstraint, math.Floor(cl.MaxLTV * float64(cl.StabilizedValue))}
+    if ltv.amount < ltc.amount {
+        return ltv
+    }
+    return ltc
+}
+
+// draw_periods returns the months of the construction loan for the given
+// total cost of the project. The equity (total cost minus the commitment)
+// funds the costs first, and the loan funds the rest.
+func (cl ConstructionLoan) draw_periods (totalCost float64) []ConstructionPeriod {
+    monthly_rate := cl.Rate / 12
+    equity := math.Max(totalCost - cl.commitment(totalCost).amount, 0)
+    periods := make([]ConstructionPeriod, cl.ConversionMonth)
+    balance := 0.0
+    for i := range periods {
+        period := ConstructionPeriod{Month: i + 1}
+        period.Interest = utils.Round2(balance * monthly_rate)
+        if i < len(cl.DrawSchedule) {
+            period.Cost = cl.DrawSchedule[i]
+        }
+        cost := period.Cost
+        if cl.InterestReserve {
+            period.InterestReserveDraw = period.Interest
+            cost += period.Interest
+        }
+        period.EquityDraw = utils.Round2(math.Min(cost, equity))
+        equity = utils.Round2(equity - period.EquityDraw)
+        period.LoanDraw = utils.Round2(cost - period.EquityDraw)
+        balance = utils.Round2(balance + period.LoanDraw)
+        period.Balance = balance
+        periods[i] = period
+    }
+    return periods
+}
+
+// External
+
+// PermanentLoan returns the permanent loan at the conversion, sized on the
+// StabilizedValue. The permanent loan is never greater than the balance of
+// the construction loan it pays off.
+func (cl ConstructionLoan) PermanentLoan () (LoanSizer, error) {
+    schedule, err := cl.construction_schedule()
+    if err != nil {
+        return cl.Permanent, fmt.Errorf("construction_schedule internal error: %v", err)
+    }
+    return cl.permanent_loan(schedule.BalanceAtConversion), nil
+}
+
+// permanent_loan returns the permanent loan that pays off the given balance.
+func (cl ConstructionLoan) permanent_loan (balance float64) LoanSizer {
+    permanent := cl.Permanent
+    permanent.PropertyValue = cl.StabilizedValue
+    payoff := int(math.Ceil(balance))
+    if permanent.RequestedLoanAmount == 0 || payoff < permanent.RequestedLoanAmount {
+        permanent.RequestedLoanAmount = payoff
+    }
+    return permanent
+}
+
+// construction_schedule returns the ConstructionSchedule without the
+// permanent loan. When the interest is capitalized, the total cost and the
+// interest reserve are solved together, as the commitment depends on the
+// total cost and the interest on the commitment.
+func (cl ConstructionLoan) construction_schedule () (ConstructionSchedule, error) {
+    var schedule ConstructionSchedule
+
+    hard_cost := cl.hard_cost()
+    total_cost := hard_cost
+    periods := cl.draw_periods(total_cost)
+    if cl.InterestReserve {
+        converged := false
+        for i := 0; i < constructionMaxIterations; i++ {
+            interest_reserve := 0.0
+            for _, period := range periods {
+                interest_reserve += period.InterestReserveDraw
+            }
+            next_total_cost := utils.Round2(hard_cost + interest_reserve)
+            if math.Abs(next_total_cost - total_cost) < 0.01 {
+                converged = true
+                break
+            }
+            total_cost = next_total_cost
+            periods = cl.draw_periods(total_cost)
+        }
+        if !converged {
+            return schedule, fmt.Errorf("The interest reserve of the construction loan did not converge.")
+        }
+    }
+
+    commitment := cl.commitment(total_cost)
+    schedule.TotalCost = total_cost
+    schedule.Commitment = commitment.amount
+    schedule.BindingConstraint = commitment.constraint
+    schedule.Construction = periods
+    for _, period := range periods {
+        schedule.Equity += period.EquityDraw
+        schedule.InterestReserve += period.InterestReserveDraw
+        schedule.InterestPaid += period.Interest - period.InterestReserveDraw
+    }
+    schedule.Equity = utils.Round2(schedule.Equity)
+    schedule.InterestReserve = utils.Round2(schedule.InterestReserve)
+    schedule.InterestPaid = utils.Round2(schedule.InterestPaid)
+    if len(periods) > 0 {
+        schedule.BalanceAtConversion = periods[len(periods) - 1].Balance
+    }
+    return schedule, nil
+}
+
+// Schedule returns the ConstructionSchedule of the loan, with the combined
+// schedule of the construction loan, month by month, followed by the payment
+// periods of the permanent loan that pays it off at the ConversionMonth.
+func (cl ConstructionLoan) Schedule () (ConstructionSchedule, error) {
+    schedule, err := cl.construction_schedule()
+    if err != nil {
+        return schedule, fmt.Errorf("construction_schedule internal error: %v", err)
+    }
+
+    permanent := cl.permanent_loan(schedule.BalanceAtConversion)
+    permanent_loan_amount, err := permanent.MaximumLoanAmount()
+    if err != nil {
+        return schedule, fmt.Errorf("MaximumLoanAmount internal error: %v", err)
+    }
+    permanent_loan_amount = math.Min(permanent_loan_amount, schedule.BalanceAtConversion)
+    schedule.PermanentLoanAmount = permanent_loan_amount
+    schedule.ConversionPaydown = utils.Round2(schedule.BalanceAtConversion - permanent_loan_amount)
+
+    for _, period := range schedule.Construction {
+        schedule.Periods = append(
+            schedule.Periods,
+            LoanPeriod{
+                Month: period.Month,
+                Phase: ConstructionPhase,
+                Draw: period.LoanDraw,
+                Interest: - period.Interest,
+                Balance: period.Balance,
+            },
+        )
+    }
+
+    ppmt, ipmt, err := permanent.PaymentDistribution()
+    if err != nil {
+        return schedule, fmt.Errorf("PaymentDistribution internal error: %v", err)
+    }
+    months_per_period := 12 / permanent.periods_per_year()
+    balance := permanent_loan_amount
+    for i := range ppmt {
+        balance = utils.Round2(balance + ppmt[i])
+        schedule.Periods = append(
+            schedule.Periods,
+            LoanPeriod{
+                Month: cl.ConversionMonth + (i + 1) * months_per_period,
+                Phase: PermanentPhase,
+                Interest: ipmt[i],
+                Principal: ppmt[i],
+                Balance: balance,
+            },
+        )
+    }
+    return schedule, nil
+}
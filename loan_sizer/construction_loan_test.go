@@ -0,0 +1,140 @@
+// Testing the construction loan
+
+package loan_sizer
+import (
+    "testing";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+)
+
+func TestConstructionLoan(t *testing.T){
+    permanent, err := NewLoanSizer(0.75, 1.25, 30, 10, 0, 0.06, 0, 150000, 0, 0)
+    if err != nil {
+        t.Errorf("NewLoanSizer internal error: %v", err)
+        return
+    }
+    draws := []float64{400000, 300000, 300000}
+
+    t.Run("Interest paid by the borrower", func(t *testing.T) {
+        cl, err := NewConstructionLoan(draws, 0.12, 0.60, 0.70, 2000000, false, 4, permanent)
+        if err != nil {
+            t.Errorf("NewConstructionLoan internal error: %v", err)
+            return
+        }
+        schedule, err := cl.Schedule()
+        if err != nil {
+            t.Errorf("Schedule internal error: %v", err)
+            return
+        }
+        var testCases = []struct {
+            name string
+            got float64
+            want float64
+        }{
+            {"TotalCost", schedule.TotalCost, 1000000},
+            {"Commitment", schedule.Commitment, 600000},
+            {"Equity", schedule.Equity, 400000},
+            {"InterestReserve", schedule.InterestReserve, 0},
+            {"InterestPaid", schedule.InterestPaid, 9000},
+            {"BalanceAtConversion", schedule.BalanceAtConversion, 600000},
+            {"PermanentLoanAmount", schedule.PermanentLoanAmount, 600000},
+            {"ConversionPaydown", schedule.ConversionPaydown, 0},
+            {"Month 3 Interest", schedule.Construction[2].Interest, 3000},
+            {"Month 3 LoanDraw", schedule.Construction[2].LoanDraw, 300000},
+        }
+        for _, test := range testCases {
+            if !utils.Tolerance(test.got, test.want, TOL) {
+                t.Errorf("%s got: %g, wanted: %g", test.name, test.got, test.want)
+            }
+        }
+        if schedule.BindingConstraint != LTCConstraint {
+            t.Errorf("BindingConstraint got: %s, wanted: %s", schedule.BindingConstraint, LTCConstraint)
+        }
+        // 4 construction months and 10 annual payments of the permanent loan.
+        if len(schedule.Periods) != 14 {
+            t.Errorf("Periods got: %d, wanted: 14", len(schedule.Periods))
+        }
+        last := schedule.Periods[len(schedule.Periods) - 1]
+        if last.Phase != PermanentPhase || last.Month != 124 {
+            t.Errorf("last period got: %s %d, wanted: %s 124", last.Phase, last.Month, PermanentPhase)
+        }
+    })
+
+    t.Run("Interest reserve", func(t *testing.T) {
+        cl, err := NewConstructionLoan(draws, 0.12, 0.60, 0.70, 2000000, true, 4, permanent)
+        if err != nil {
+            t.Errorf("NewConstructionLoan internal error: %v", err)
+            return
+        }
+        schedule, err := cl.Schedule()
+        if err != nil {
+            t.Errorf("Schedule internal error: %v", err)
+            return
+        }
+        var testCases = []struct {
+            name string
+            got float64
+            want float64
+        }{
+            {"TotalCost", schedule.TotalCost, 1008957.96},
+            {"Commitment", schedule.Commitment, 605374},
+            {"InterestReserve", schedule.InterestReserve, 8957.96},
+            {"InterestPaid", schedule.InterestPaid, 0},
+            {"BalanceAtConversion", schedule.BalanceAtConversion, 605374},
+            {"Month 2 EquityDraw", schedule.Construction[1].EquityDraw, 3583.96},
+        }
+        for _, test := range testCases {
+            if !utils.Tolerance(test.got, test.want, TOL) {
+                t.Errorf("%s got: %g, wanted: %g", test.name, test.got, test.want)
+            }
+        }
+    })
+
+    t.Run("Conversion paydown", func(t *testing.T) {
+        small_permanent := permanent
+        small_permanent.NOI = 40000
+        cl, err := NewConstructionLoan(draws, 0.12, 0.60, 0.70, 2000000, false, 4, small_permanent)
+        if err != nil {
+            t.Errorf("NewConstructionLoan internal error: %v", err)
+            return
+        }
+        schedule, err := cl.Schedule()
+        if err != nil {
+            t.Errorf("Schedule internal error: %v", err)
+            return
+        }
+        if !utils.Tolerance(schedule.PermanentLoanAmount, 440474, TOL) {
+            t.Errorf("PermanentLoanAmount got: %g, wanted: 440474", schedule.PermanentLoanAmount)
+        }
+        if !utils.Tolerance(schedule.ConversionPaydown, 159526, TOL) {
+            t.Errorf("ConversionPaydown got: %g, wanted: 159526", schedule.ConversionPaydown)
+        }
+    })
+
+    t.Run("LTV binding", func(t *testing.T) {
+        cl, err := NewConstructionLoan(draws, 0.12, 0.60, 0.25, 2000000, false, 4, permanent)
+        if err != nil {
+            t.Errorf("NewConstructionLoan internal error: %v", err)
+            return
+        }
+        schedule, err := cl.Schedule()
+        if err != nil {
+            t.Errorf("Schedule internal error: %v", err)
+            return
+        }
+        if schedule.BindingConstraint != LTVConstraint || schedule.Commitment != 500000 {
+            t.Errorf("got: %s %g, wanted: %s 500000", schedule.BindingConstraint, schedule.Commitment, LTVConstraint)
+        }
+    })
+
+    t.Run("Invalid values", func(t *testing.T) {
+        if _, err := NewConstructionLoan([]float64{-1}, 0.12, 0.6, 0.7, 2000000, false, 4, permanent); err == nil {
+            t.Errorf("expected an error for a negative draw")
+        }
+        if _, err := NewConstructionLoan(draws, 0.12, 1.6, 0.7, 2000000, false, 4, permanent); err == nil {
+            t.Errorf("expected an error for a loan to cost above 1")
+        }
+        if _, err := NewConstructionLoan(draws, 0.12, 0.6, 0.7, 2000000, false, 2, permanent); err == nil {
+            t.Errorf("expected an error for a conversion before the last draw")
+        }
+    })
+}
@@ -0,0 +1,163 @@
+// Floating rate loans. The rate of each payment period comes from a forward
+// curve of the index (SOFR, for example) plus the spread of the loan.
+
+package loan_sizer
+
+import (
+    "fmt"
+    "math"
+    ff "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/financial_formulas";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+)
+
+// FloatingRate is a struct with the terms of a floating rate loan.
+//
+// ForwardCurve has the annual index rate of every payment period of the term,
+// if it is shorter than the term its last value is held. The Floor is the
+// minimum index rate the loan pays. CapStrike is the strike of the interest
+// rate cap over the index, and StressRate is the all in rate the lender uses
+// to size the DSCR. Zero values mean no floor, no cap and no stress rate.
+//
+// The loan always pays the floored index plus the spread, the cap is a hedge
+// of the borrower and does not change the interest of the loan.
+type FloatingRate struct {
+    ForwardCurve    []float64
+    Spread          float64
+    Floor           float64
+    CapStrike       float64
+    StressRate      float64
+}
+
+// NewFloatingRate returns a FloatingRate struct if the values given are
+// valid. If not, returns a default struct with the error.
+func NewFloatingRate(
+    forwardCurve []float64,
+    spread float64,
+    floor float64,
+    capStrike float64,
+    stressRate float64,
+) (
+    FloatingRate,
+    error,
+) {
+    // Data Validation
+    if len(forwardCurve) == 0 {
+        return FloatingRate{}, fmt.Errorf("The forwardCurve must have at least one value.")
+    }
+    for _, index := range forwardCurve {
+        if index < -1 || index > 1 {
+            return FloatingRate{}, fmt.Errorf("The index rates of the forwardCurve must be between -1 and 1.")
+        }
+    }
+    if spread < 0 || spread > 1 {
+        return FloatingRate{}, fmt.Errorf("The spread must be between 0 and 1.")
+    }
+    if floor < 0 || floor > 1 {
+        return FloatingRate{}, fmt.Errorf("The floor must be between 0 and 1.")
+    }
+    if capStrike < 0 || capStrike > 1 {
+        return FloatingRate{}, fmt.Errorf("The capStrike must be between 0 and 1.")
+    }
+    if capStrike > 0 && capStrike < floor {
+        return FloatingRate{}, fmt.Errorf("The capStrike cannot be lower than the floor.")
+    }
+    if stressRate < 0 || stressRate > 1 {
+        return FloatingRate{}, fmt.Errorf("The stressRate must be between 0 and 1.")
+    }
+    // Struct Creation
+    fr := FloatingRate{
+        ForwardCurve: forwardCurve,
+        Spread: spread,
+        Floor: floor,
+        CapStrike: capStrike,
+        StressRate: stressRate,
+    }
+    return fr, nil
+}
+
+// Index returns the annual index rate of the given payment period, starting
+// at 0.
+func (fr FloatingRate) Index (period int) float64 {
+    if period >= len(fr.ForwardCurve) {
+        return fr.ForwardCurve[len(fr.ForwardCurve) - 1]
+    }
+    return fr.ForwardCurve[period]
+}
+
+// Rate returns the annual all in rate that the loan pays on the given payment
+// period, the floored index plus the spread.
+func (fr FloatingRate) Rate (period int) float64 {
+    return math.Max(fr.Index(period), fr.Floor) + fr.Spread
+}
+
+// SizingRate returns the annual rate used to size the DSCR of the loan. It is
+// the StressRate if given, the capped rate (CapStrike plus spread) if the
+// loan has a cap, and the highest rate of the forward curve if not.
+func (fr FloatingRate) SizingRate () float64 {
+    if fr.StressRate > 0 {
+        return fr.StressRate
+    }
+    if fr.CapStrike > 0 {
+        return math.Max(fr.CapStrike, fr.Floor) + fr.Spread
+    }
+    sizing_rate := fr.Rate(0)
+    for period := range fr.ForwardCurve {
+        sizing_rate = math.Max(sizing_rate, fr.Rate(period))
+    }
+    return sizing_rate
+}
+
+// WithFloatingRate returns a copy of the LoanSizer as a floating rate loan.
+// The Rate of the LoanSizer is not used by a floating rate loan.
+func (ls LoanSizer) WithFloatingRate (fr FloatingRate) (LoanSizer, error) {
+    validated, err := NewFloatingRate(fr.ForwardCurve, fr.Spread, fr.Floor, fr.CapStrike, fr.StressRate)
+    if err != nil {
+        return ls, fmt.Errorf("NewFloatingRate internal error: %v", err)
+    }
+    ls.FloatingRate = &validated
+    return ls, nil
+}
+
+// PeriodRates returns the annual rate the loan pays on every payment period of
+// the term.
+func (ls LoanSizer) PeriodRates () []float64 {
+    rates := make([]float64, ls.term_periods())
+    for period := range rates {
+        if ls.FloatingRate == nil {
+            rates[period] = ls.Rate
+        } else {
+            rates[period] = ls.FloatingRate.Rate(period)
+        }
+    }
+    return rates
+}
+
+// floating_payment_distribution returns the principal and interest payments
+// of every period of the term of a floating rate loan. After the IO period,
+// the payment of each period is calculated again with the rate of the period
+// and the remaining amortization.
+func (ls LoanSizer) floating_payment_distribution (mla float64) (
+    ppmt []float64,
+    ipmt []float64,
+    err error,
+) {
+    periods_per_year := float64(ls.periods_per_year())
+    ppmt = make([]float64, ls.term_periods())
+    ipmt = make([]float64, ls.term_periods())
+    capital := mla
+    for period, rate := range ls.PeriodRates() {
+        period_rate := rate / periods_per_year
+        ipmt[period] = utils.Round2(- capital * period_rate)
+        if period < ls.io_periods() {
+            continue
+        }
+        remaining_periods := ls.amortization_periods() - (period - ls.io_periods())
+        pmt, err := ff.Payment(period_rate, remaining_periods, capital, 0, 0)
+        if err != nil {
+            return ppmt, ipmt, fmt.Errorf("Payment internal error: %v", err)
+        }
+        ppmt[period] = utils.Round2(pmt - ipmt[period])
+        capital = utils.Round2(capital + ppmt[period])
+    }
+    return ppmt, ipmt, nil
+}
@@ -0,0 +1,177 @@
+// Testing the floating rate loans
+
+package loan_sizer
+import (
+    "testing";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+)
+
+func TestFloatingRate(t *testing.T){
+    fr := FloatingRate{
+        ForwardCurve: []float64{0.04, 0.05, 0.06},
+        Spread: 0.03,
+        Floor: 0.045,
+        CapStrike: 0.055,
+    }
+
+    t.Run("Period rates", func(t *testing.T) {
+        want := []float64{0.075, 0.08, 0.09, 0.09, 0.09}
+        for period, rate := range want {
+            if got := fr.Rate(period); !utils.Tolerance(got, rate, 1e-9) {
+                t.Errorf("Rate of period %v got: %g, wanted: %g", period, got, rate)
+            }
+        }
+    })
+
+    t.Run("Sizing rate", func(t *testing.T) {
+        if got := fr.SizingRate(); !utils.Tolerance(got, 0.085, 1e-9) {
+            t.Errorf("SizingRate with cap got: %g, wanted: %g", got, 0.085)
+        }
+        stressed := fr
+        stressed.StressRate = 0.10
+        if got := stressed.SizingRate(); got != 0.10 {
+            t.Errorf("SizingRate with stress rate got: %g, wanted: %g", got, 0.10)
+        }
+        uncapped := fr
+        uncapped.CapStrike = 0
+        if got := uncapped.SizingRate(); !utils.Tolerance(got, 0.09, 1e-9) {
+            t.Errorf("SizingRate without cap got: %g, wanted: %g", got, 0.09)
+        }
+    })
+
+    t.Run("Invalid values", func(t *testing.T) {
+        invalid := []FloatingRate{
+            {ForwardCurve: []float64{}, Spread: 0.03},
+            {ForwardCurve: []float64{0.04}, Spread: -0.03},
+            {ForwardCurve: []float64{0.04}, Spread: 0.03, Floor: 0.05, CapStrike: 0.04},
+            {ForwardCurve: []float64{0.04}, Spread: 0.03, StressRate: 2},
+        }
+        for _, test := range invalid {
+            if _, err := (LoanSizer{}).WithFloatingRate(test); err == nil {
+                t.Errorf("expected an error for: %+v", test)
+            }
+        }
+    })
+}
+
+func TestFloatingRateLoan(t *testing.T){
+    var testCases = []struct {
+        name string
+        ls LoanSizer
+        fr FloatingRate
+        wantMaximumLoanAmount float64
+        wantEndofTermBalloonPayment float64
+        wantIpmt []float64
+        wantPpmt []float64
+        wantDebtService []float64
+    }{
+        {
+            name: "Interest only bridge loan",
+            ls: LoanSizer{
+                MaxLTV: 0.80,
+                MinDSCR: 1.25,
+                Amortization: 30,
+                Term: 3,
+                IOPeriod: 3,
+                PropertyValue: 2000000,
+                NOI: 200000,
+                RequestedLoanAmount: 1000000,
+            },
+            fr: FloatingRate{
+                ForwardCurve: []float64{0.04, 0.05, 0.06},
+                Spread: 0.03,
+                Floor: 0.045,
+                CapStrike: 0.055,
+            },
+            wantMaximumLoanAmount: 1000000,
+            wantEndofTermBalloonPayment: 1000000,
+            wantIpmt: []float64{-75000, -80000, -90000},
+            wantPpmt: []float64{0, 0, 0},
+            wantDebtService: []float64{-75000, -80000, -90000},
+        },
+        {
+            name: "DSCR sized at the capped rate",
+            ls: LoanSizer{
+                MaxLTV: 0.80,
+                MinDSCR: 1.25,
+                Amortization: 30,
+                Term: 3,
+                IOPeriod: 3,
+                PropertyValue: 2000000,
+                NOI: 100000,
+                RequestedLoanAmount: 1000000,
+            },
+            fr: FloatingRate{
+                ForwardCurve: []float64{0.04, 0.05, 0.06},
+                Spread: 0.03,
+                Floor: 0.045,
+                CapStrike: 0.055,
+            },
+            wantMaximumLoanAmount: 859747,
+            wantEndofTermBalloonPayment: 859747,
+            wantIpmt: []float64{-64481.03, -68779.76, -77377.23},
+            wantPpmt: []float64{0, 0, 0},
+            wantDebtService: []float64{-64481.03, -68779.76, -77377.23},
+        },
+        {
+            name: "Amortizing loan",
+            ls: LoanSizer{
+                MaxLTV: 0.80,
+                MinDSCR: 1.25,
+                Amortization: 2,
+                Term: 2,
+                IOPeriod: 0,
+                PropertyValue: 200000,
+                NOI: 200000,
+                RequestedLoanAmount: 100000,
+            },
+            fr: FloatingRate{
+                ForwardCurve: []float64{0.05, 0.07},
+            },
+            wantMaximumLoanAmount: 100000,
+            wantEndofTermBalloonPayment: 0,
+            wantIpmt: []float64{-5000, -3585.37},
+            wantPpmt: []float64{-48780.49, -51219.51},
+            wantDebtService: []float64{-53780.49, -54804.88},
+        },
+    }
+
+    for _, test := range testCases {
+        t.Run(test.name, func(t *testing.T) {
+            ls, err := test.ls.WithFloatingRate(test.fr)
+            if err != nil {
+                t.Errorf("WithFloatingRate internal error: %v", err)
+                return
+            }
+            mla, err := ls.MaximumLoanAmount()
+            if err != nil || !utils.Tolerance(mla, test.wantMaximumLoanAmount, TOL) {
+                t.Errorf("MaximumLoanAmount got: %g, wanted: %g, error: %v", mla, test.wantMaximumLoanAmount, err)
+            }
+            balloon, err := ls.EndofTermBalloonPayment()
+            if err != nil || !utils.Tolerance(balloon, test.wantEndofTermBalloonPayment, TOL) {
+                t.Errorf("EndofTermBalloonPayment got: %g, wanted: %g, error: %v", balloon, test.wantEndofTermBalloonPayment, err)
+            }
+            ppmt, ipmt, err := ls.PaymentDistribution()
+            if err != nil {
+                t.Errorf("PaymentDistribution internal error: %v", err)
+                return
+            }
+            debt_service, err := ls.AnnualDebtService()
+            if err != nil {
+                t.Errorf("AnnualDebtService internal error: %v", err)
+                return
+            }
+            for i := range test.wantIpmt {
+                if !utils.Tolerance(ipmt[i], test.wantIpmt[i], TOL) {
+                    t.Errorf("PaymentDistribution ipmt got: %g, wanted: %g", ipmt[i], test.wantIpmt[i])
+                }
+                if !utils.Tolerance(ppmt[i], test.wantPpmt[i], TOL) {
+                    t.Errorf("PaymentDistribution ppmt got: %g, wanted: %g", ppmt[i], test.wantPpmt[i])
+                }
+                if !utils.Tolerance(debt_service[i], test.wantDebtService[i], TOL) {
+                    t.Errorf("AnnualDebtService got: %g, wanted: %g", debt_service[i], test.wantDebtService[i])
+                }
+            }
+        })
+    }
+}
@@ -8,19 +8,58 @@
 // [X] loan io period payment
 // [X] balloon payment
 // [X] payments distribution
+// [X] minimum noi
+// [X] payment frequency
+// [X] debt yield
+// [X] binding constraint
+// [X] sizing result
+// [X] floating rate
+// [X] rate cap
+// [X] prepayment penalty
+// [X] construction loan
+// [X] loan to cost
 
 package loan_sizer
 
 import (
     "fmt"
     "math"
-    "sort"
     ff "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/financial_formulas";
     utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
 )
 
+// PaymentFrequency is the number of loan payments done within a year.
+type PaymentFrequency int
+
+const (
+    Annual      PaymentFrequency = 1
+    Quarterly   PaymentFrequency = 4
+    Monthly     PaymentFrequency = 12
+)
+
+// SizingConstraint is the name of a restriction that sets the size of the
+// loan.
+type SizingConstraint string
+
+const (
+    LTVConstraint           SizingConstraint = "ltv"
+    DSCRConstraint          SizingConstraint = "dscr"
+    DebtYieldConstraint     SizingConstraint = "debt_yield"
+    RequestedConstraint     SizingConstraint = "requested"
+    LTCConstraint           SizingConstraint = "ltc"
+)
+
 // LoanSizer creates a struct that has all the information regarding the loan
-// information.
+// information. The Amortization, Term and IOPeriod are given in years and the
+// Rate is the annual rate, the PaymentFrequency sets how many payments are
+// done within each year. A zero PaymentFrequency is taken as Annual. The
+// MinDebtYield (NOI / loan amount) is only applied when it is greater than 0,
+// and so is the MaxLTC (loan amount / ProjectCost). The ProjectCost is the
+// total cost of the project (purchase price plus closing and renovations), a
+// zero ProjectCost is taken as the PropertyValue.
+// When the FloatingRate is set, the loan is a floating rate loan and the Rate
+// is not used, and the RateCap is the interest rate cap bought for it. The
+// Prepayment is the cost structure of paying the loan before its term ends.
 type LoanSizer struct {
     MaxLTV              float64
     MinDSCR             float64
@@ -32,6 +71,13 @@ type LoanSizer struct {
     NOI                 float64
     RequestedLoanAmount int
     LoanOriginationFees float64
+    PaymentFrequency    PaymentFrequency
+    MinDebtYield        float64
+    MaxLTC              float64
+    ProjectCost         int
+    FloatingRate        *FloatingRate
+    RateCap             *RateCap
+    Prepayment          *Prepayment
 }
 
 // Constructor
@@ -93,14 +139,95 @@ func NewLoanSizer(
         NOI: noi,
         RequestedLoanAmount: requestedLoanAmount,
         LoanOriginationFees: loanOriginationFees,
+        PaymentFrequency: Annual,
     }
     return ls, nil
 }
 
+// WithPaymentFrequency returns a copy of the LoanSizer with the given payment
+// frequency. If the frequency is not Annual, Quarterly or Monthly, returns
+// the LoanSizer unchanged with the error.
+func (ls LoanSizer) WithPaymentFrequency (frequency PaymentFrequency) (LoanSizer, error) {
+    if frequency != Annual && frequency != Quarterly && frequency != Monthly {
+        return ls, fmt.Errorf("The payment frequency must be Annual, Quarterly or Monthly.")
+    }
+    ls.PaymentFrequency = frequency
+    return ls, nil
+}
+
+// WithMinDebtYield returns a copy of the LoanSizer with the given minimum debt
+// yield as a sizing constraint. A zero value removes the constraint.
+func (ls LoanSizer) WithMinDebtYield (minDebtYield float64) (LoanSizer, error) {
+    if minDebtYield < 0 || minDebtYield > 1 {
+        return ls, fmt.Errorf("The minDebtYield must be between 0 and 1.")
+    }
+    ls.MinDebtYield = minDebtYield
+    return ls, nil
+}
+
+// WithMaxLTC returns a copy of the LoanSizer with the given maximum loan to
+// cost ratio as a sizing constraint. A zero value removes the constraint.
+func (ls LoanSizer) WithMaxLTC (maxLTC float64) (LoanSizer, error) {
+    if maxLTC < 0 || maxLTC > 1 {
+        return ls, fmt.Errorf("The loan to cost ratio must be between 0 and 1.")
+    }
+    ls.MaxLTC = maxLTC
+    return ls, nil
+}
+
+// WithProjectCost returns a copy of the LoanSizer with the given total cost
+// of the project, used by the loan to cost constraint.
+func (ls LoanSizer) WithProjectCost (projectCost int) (LoanSizer, error) {
+    if projectCost < 0 {
+        return ls, fmt.Errorf("The projectCost cannot have a value below zero.")
+    }
+    ls.ProjectCost = projectCost
+    return ls, nil
+}
+
 // Calculation methods
 
 // Internal
 
+// periods_per_year returns the number of payments within a year.
+func (ls LoanSizer) periods_per_year () int {
+    if ls.PaymentFrequency <= 0 {
+        return int(Annual)
+    }
+    return int(ls.PaymentFrequency)
+}
+
+// period_rate returns the interest rate of each payment period.
+func (ls LoanSizer) period_rate () float64 {
+    return ls.Rate / float64(ls.periods_per_year())
+}
+
+// sizing_period_rate returns the interest rate of each payment period used
+// to size the loan and its constant payments. For a floating rate loan it is
+// the SizingRate of the FloatingRate.
+func (ls LoanSizer) sizing_period_rate () float64 {
+    if ls.FloatingRate != nil {
+        return ls.FloatingRate.SizingRate() / float64(ls.periods_per_year())
+    }
+    return ls.period_rate()
+}
+
+// amortization_periods returns the number of payment periods of the
+// amortization.
+func (ls LoanSizer) amortization_periods () int {
+    return ls.Amortization * ls.periods_per_year()
+}
+
+// term_periods returns the number of payment periods of the term.
+func (ls LoanSizer) term_periods () int {
+    return ls.Term * ls.periods_per_year()
+}
+
+// io_periods returns the number of payment periods of the IO period.
+func (ls LoanSizer) io_periods () int {
+    return ls.IOPeriod * ls.periods_per_year()
+}
+
 // max_ltv_loan_amount returns the maximum loan amount given the maximum loan
 // to value ratio
 func (ls LoanSizer) max_ltv_loan_amount () float64 {
@@ -111,84 +238,213 @@ func (ls LoanSizer) max_ltv_loan_amount () float64 {
 // max_mindscr_loan_amount returns the maximum loan amount given the minimum
 // dscr
 func (ls LoanSizer) max_mindscr_loan_amount () (float64, error) {
-    // monthly_rate := ls.Rate / 12
-    // amoritzation_months := ls.Amortization * 12
-    payment := - ls.NOI / ls.MinDSCR
-    dscr_mla, err := ff.PresentValue(ls.Rate, ls.Amortization, payment, 0, 0)
+    payment := - ls.NOI / ls.MinDSCR / float64(ls.periods_per_year())
+    dscr_mla, err := ff.PresentValue(ls.sizing_period_rate(), ls.amortization_periods(), payment, 0, 0)
     if err != nil {
         return 0.0, fmt.Errorf("PresentValue internal error: %v", err)
     }
     return math.Floor(dscr_mla), err
 }
 
+// max_debt_yield_loan_amount returns the maximum loan amount given the
+// minimum debt yield
+func (ls LoanSizer) max_debt_yield_loan_amount () float64 {
+    return math.Floor(ls.NOI / ls.MinDebtYield)
+}
+
+// project_cost returns the total cost of the project, the PropertyValue if
+// no ProjectCost was given.
+func (ls LoanSizer) project_cost () int {
+    if ls.ProjectCost == 0 {
+        return ls.PropertyValue
+    }
+    return ls.ProjectCost
+}
+
+// max_ltc_loan_amount returns the maximum loan amount given the maximum loan
+// to cost ratio
+func (ls LoanSizer) max_ltc_loan_amount () float64 {
+    return math.Floor(ls.MaxLTC * float64(ls.project_cost()))
+}
+
+// loan_candidate is the loan amount allowed by a sizing constraint.
+type loan_candidate struct {
+    constraint  SizingConstraint
+    amount      float64
+}
+
+// loan_candidates returns the loan amount allowed by every constraint of the
+// LoanSizer. The debt yield is only added if the MinDebtYield is set, and the
+// loan to cost if the MaxLTC is set.
+func (ls LoanSizer) loan_candidates () ([]loan_candidate, error) {
+    max_mindscr_loan_amount, err := ls.max_mindscr_loan_amount()
+    if err != nil {
+        return nil, fmt.Errorf("max_mindscr_loan_amount internal error: %v", err)
+    }
+    candidates := []loan_candidate{
+        {LTVConstraint, ls.max_ltv_loan_amount()},
+        {DSCRConstraint, max_mindscr_loan_amount},
+    }
+    if ls.MinDebtYield > 0 {
+        candidates = append(candidates, loan_candidate{DebtYieldConstraint, ls.max_debt_yield_loan_amount()})
+    }
+    if ls.MaxLTC > 0 {
+        candidates = append(candidates, loan_candidate{LTCConstraint, ls.max_ltc_loan_amount()})
+    }
+    candidates = append(candidates, loan_candidate{RequestedConstraint, float64(ls.RequestedLoanAmount)})
+    return candidates, nil
+}
+
+// binding_candidate returns the candidate with the lowest loan amount. On a
+// tie, the first constraint of the candidates list is returned.
+func (ls LoanSizer) binding_candidate () (loan_candidate, error) {
+    candidates, err := ls.loan_candidates()
+    if err != nil {
+        return loan_candidate{}, fmt.Errorf("loan_candidates internal error: %v", err)
+    }
+    binding := candidates[0]
+    for _, candidate := range candidates[1:] {
+        if candidate.amount < binding.amount {
+            binding = candidate
+        }
+    }
+    return binding, nil
+}
+
 // External
 
 // MaximumLoanAmount returns the maximum loan amount of a LoanSizer struct,
-// with the ltv and dscr restrictions. If the RequestedLoanAmount is lower than
-// the possibly higher loan amount, the RequestedLoanAmount will be returned.
+// with the ltv, dscr, debt yield and ltc restrictions. If the RequestedLoanAmount
+// is lower than the possibly higher loan amount, the RequestedLoanAmount will
+// be returned.
 func (ls LoanSizer) MaximumLoanAmount () (float64, error) {
-    max_mindscr_loan_amount, err := ls.max_mindscr_loan_amount()
+    binding, err := ls.binding_candidate()
+    if err != nil {
+        return 0, fmt.Errorf("binding_candidate internal error: %v", err)
+    }
+    return binding.amount, nil
+}
+
+// BindingConstraint returns the constraint that sets the MaximumLoanAmount.
+func (ls LoanSizer) BindingConstraint () (SizingConstraint, error) {
+    binding, err := ls.binding_candidate()
+    if err != nil {
+        return "", fmt.Errorf("binding_candidate internal error: %v", err)
+    }
+    return binding.constraint, nil
+}
+
+// SizingResult is a struct with the detail of how the loan was sized. The
+// Candidates are the loan amounts allowed by every constraint, the Headroom
+// is how much each candidate is above the final LoanAmount, and the LTV, LTC,
+// DSCR and DebtYield are the ratios at the final LoanAmount.
+type SizingResult struct {
+    LoanAmount          float64
+    BindingConstraint   SizingConstraint
+    Candidates          map[SizingConstraint]float64
+    Headroom            map[SizingConstraint]float64
+    LTV                 float64
+    LTC                 float64
+    DSCR                float64
+    DebtYield           float64
+}
+
+// Sizing returns the SizingResult of the LoanSizer. The DSCR is calculated
+// with the yearly loan payment of the amortization period.
+func (ls LoanSizer) Sizing () (SizingResult, error) {
+    var result SizingResult
+
+    candidates, err := ls.loan_candidates()
     if err != nil {
-        return 0, fmt.Errorf("max_mindscr_loan_amount internal error: %v", err)
+        return result, fmt.Errorf("loan_candidates internal error: %v", err)
     }
-    loan_values := [3]float64{
-        ls.max_ltv_loan_amount(),
-        max_mindscr_loan_amount,
-        float64(ls.RequestedLoanAmount),
+    binding, err := ls.binding_candidate()
+    if err != nil {
+        return result, fmt.Errorf("binding_candidate internal error: %v", err)
+    }
+    result.LoanAmount = binding.amount
+    result.BindingConstraint = binding.constraint
+    result.Candidates = make(map[SizingConstraint]float64, len(candidates))
+    result.Headroom = make(map[SizingConstraint]float64, len(candidates))
+    for _, candidate := range candidates {
+        result.Candidates[candidate.constraint] = candidate.amount
+        result.Headroom[candidate.constraint] = utils.Round2(candidate.amount - binding.amount)
+    }
+
+    if ls.PropertyValue > 0 {
+        result.LTV = utils.Round4(binding.amount / float64(ls.PropertyValue))
+    }
+    if ls.project_cost() > 0 {
+        result.LTC = utils.Round4(binding.amount / float64(ls.project_cost()))
     }
-    sort.Float64s(loan_values[:])
-    return loan_values[0], nil
+    if binding.amount > 0 {
+        result.DebtYield = utils.Round4(ls.NOI / binding.amount)
+        annual_loan_payment, err := ls.AnnualLoanPayment()
+        if err != nil {
+            return result, fmt.Errorf("AnnualLoanPayment internal error: %v", err)
+        }
+        if annual_loan_payment != 0 {
+            result.DSCR = utils.Round4(ls.NOI / - annual_loan_payment)
+        }
+    }
+    return result, nil
 }
 
-// IOLoanPayment returns the loan payments during the IO periods for the
-// maximum loan amount.
+// IOLoanPayment returns the loan payments of each period during the IO period
+// for the maximum loan amount. For a floating rate loan the payment is
+// calculated at the sizing rate.
 func (ls LoanSizer) IOLoanPayment () (float64, error){
     mla, err := ls.MaximumLoanAmount()
     if err != nil {
         return 0.0, fmt.Errorf("MaximumLoanAmount internal error: %v", err)
     }
-    return ff.IOPayment(ls.Rate, mla), nil
+    return ff.IOPayment(ls.sizing_period_rate(), mla), nil
 }
 
-// LoanPayment returns the periodic loan payments for the maximum loan amount.
+// LoanPayment returns the periodic loan payments for the maximum loan amount,
+// one for each period of the PaymentFrequency. For a floating rate loan the
+// payment is calculated at the sizing rate.
 func (ls LoanSizer) LoanPayment () (float64, error) {
     mla, err := ls.MaximumLoanAmount()
     if err != nil {
         return 0.0, fmt.Errorf("MaximumLoanAmount internal error: %v", err)
     }
-    loan_payment, err := ff.Payment(ls.Rate, ls.Amortization, mla, 0, 0)
+    loan_payment, err := ff.Payment(ls.sizing_period_rate(), ls.amortization_periods(), mla, 0, 0)
     if err != nil {
         return 0.0, fmt.Errorf("Payment internal error: %v", err)
     }
     return loan_payment, nil
 }
 
-// BallonPayment returns the balloon payment at the end of the term for the
-// maximum loan amount.
-func (ls *LoanSizer) EndofTermBalloonPayment () (float64, error) {
-    mla, err := ls.MaximumLoanAmount()
-    if err != nil {
-        return 0.0, fmt.Errorf("MaximumLoanAmount internal error: %v", err)
+// principal_payments returns the principal payments of every period of the
+// loan for the maximum loan amount, with no principal payments during the IO
+// period.
+func (ls LoanSizer) principal_payments (mla float64) ([]float64, error) {
+    if ls.FloatingRate != nil {
+        principal_payments, _, err := ls.floating_payment_distribution(mla)
+        if err != nil {
+            return principal_payments, fmt.Errorf("floating_payment_distribution internal error: %v", err)
+        }
+        return principal_payments, nil
     }
-    principal_payments, err := ff.PrincipalPayments(ls.Rate, ls.Amortization, mla, 0, 0)
+    principal_payments, err := ff.PrincipalPayments(ls.period_rate(), ls.amortization_periods(), mla, 0, 0)
     if err != nil {
-        return 0.0, fmt.Errorf("PrincipalPayments internal error: %v", err)
+        return principal_payments, fmt.Errorf("PrincipalPayments internal error: %v", err)
     }
 
     // here we create a 0s array and then append to it the principal payments
     // array that will represent the no principal payment while the IO period.
     if ls.IOPeriod > 0 {
-        io_period_ppmt := make([]float64, ls.IOPeriod)
+        io_period_ppmt := make([]float64, ls.io_periods())
         principal_payments = append(io_period_ppmt, principal_payments...)
     }
+    return principal_payments, nil
+}
 
-    capital := mla
-    // There is no need to create a new slice that will contain only the term
-    // as the iteration will iterate till the term value. Genious move!!.
-    for i:=0; i < ls.Term; i++ {
-        capital += principal_payments[i]
-    }
-    return utils.Round2(capital), nil
+// BallonPayment returns the balloon payment at the end of the term for the
+// maximum loan amount.
+func (ls *LoanSizer) EndofTermBalloonPayment () (float64, error) {
+    return ls.SaleYearBalloonPayment(ls.Term)
 }
 
 // SaleYearBallonPayment returns the balloon payment at year that the property
@@ -198,22 +454,15 @@ func (ls *LoanSizer) SaleYearBalloonPayment (saleYear int) (float64, error) {
     if err != nil {
         return 0.0, fmt.Errorf("MaximumLoanAmount internal error: %v", err)
     }
-    principal_payments, err := ff.PrincipalPayments(ls.Rate, ls.Amortization, mla, 0, 0)
+    principal_payments, err := ls.principal_payments(mla)
     if err != nil {
-        return 0.0, fmt.Errorf("PrincipalPayments internal error: %v", err)
-    }
-
-    // here we create a 0s array and then append to it the principal payments
-    // array that will represent the no principal payment while the IO period.
-    if ls.IOPeriod > 0 {
-        io_period_ppmt := make([]float64, ls.IOPeriod)
-        principal_payments = append(io_period_ppmt, principal_payments...)
+        return 0.0, fmt.Errorf("principal_payments internal error: %v", err)
     }
 
     capital := mla
     // There is no need to create a new slice that will contain only the term
     // as the iteration will iterate till the term value. Genious move!!.
-    for i:=0; i < saleYear; i++ {
+    for i:=0; i < saleYear * ls.periods_per_year(); i++ {
         capital += principal_payments[i]
     }
     return utils.Round2(capital), nil
@@ -221,7 +470,7 @@ func (ls *LoanSizer) SaleYearBalloonPayment (saleYear int) (float64, error) {
 
 // PaymentDistribution returns the slices of the different interest and
 // principal payments of the loan within the duration of the term for the
-// maximum loan amount.
+// maximum loan amount. There is a value for every payment period of the term.
 func (ls LoanSizer) PaymentDistribution () (
     ppmt []float64,
     ipmt []float64,
@@ -231,21 +480,28 @@ func (ls LoanSizer) PaymentDistribution () (
     if err != nil {
         return ppmt, ipmt, fmt.Errorf("MaximumLoanAmount internal error: %v", err)
     }
+    if ls.FloatingRate != nil {
+        ppmt, ipmt, err = ls.floating_payment_distribution(mla)
+        if err != nil {
+            return ppmt, ipmt, fmt.Errorf("floating_payment_distribution internal error: %v", err)
+        }
+        return ppmt, ipmt, nil
+    }
     // Principal Payments
-    ppmt, err = ff.PrincipalPayments(ls.Rate, ls.Amortization, mla, 0, 0)
+    ppmt, err = ff.PrincipalPayments(ls.period_rate(), ls.amortization_periods(), mla, 0, 0)
     if err != nil {
         return ppmt, ipmt, fmt.Errorf("PrincipalPayments internal error: %v", err)
     }
     // adding the IO period payments at the begining of the slice.
     if ls.IOPeriod > 0 {
-        io_period_ppmt := make([]float64, ls.IOPeriod)
+        io_period_ppmt := make([]float64, ls.io_periods())
         ppmt = append(io_period_ppmt, ppmt...)
     }
     // taking the slice with the size of the term.
-    ppmt = ppmt[:ls.Term]
+    ppmt = ppmt[:ls.term_periods()]
 
     // Interest Payments
-    ipmt, err = ff.InterestPayments(ls.Rate, ls.Amortization, mla, 0, 0)
+    ipmt, err = ff.InterestPayments(ls.period_rate(), ls.amortization_periods(), mla, 0, 0)
     if err != nil {
         return ppmt, ipmt, fmt.Errorf("InterestPayments internal error: %v", err)
     }
@@ -255,14 +511,166 @@ func (ls LoanSizer) PaymentDistribution () (
         if err != nil {
             return ppmt, ipmt, fmt.Errorf("IOLoanPayment internal error: %v", err)
         }
-        io_period_ipmt := make([]float64, ls.IOPeriod)
-        for i := 0; i < ls.IOPeriod; i++ {
+        io_period_ipmt := make([]float64, ls.io_periods())
+        for i := 0; i < ls.io_periods(); i++ {
             io_period_ipmt[i] = io_pmt
         }
         ipmt = append(io_period_ipmt, ipmt...)
     }
     // taking the slice with the size of the term.
-    ipmt = ipmt[:ls.Term]
+    ipmt = ipmt[:ls.term_periods()]
     // Returning everything
     return ppmt, ipmt, nil
 }
+
+// AnnualLoanPayment returns the loan payments done within a year for the
+// maximum loan amount.
+func (ls LoanSizer) AnnualLoanPayment () (float64, error) {
+    loan_payment, err := ls.LoanPayment()
+    if err != nil {
+        return 0.0, fmt.Errorf("LoanPayment internal error: %v", err)
+    }
+    return utils.Round2(loan_payment * float64(ls.periods_per_year())), nil
+}
+
+// AnnualDebtService returns the debt service of every year of the term. For
+// a fixed rate loan it is the AnnualLoanPayment on every year, IO period
+// included. For a floating rate loan it is the sum of the interest and
+// principal payments of the year.
+func (ls LoanSizer) AnnualDebtService () ([]float64, error) {
+    debt_service := make([]float64, ls.Term)
+    if ls.FloatingRate != nil {
+        ppmt, ipmt, err := ls.AnnualPaymentDistribution()
+        if err != nil {
+            return debt_service, fmt.Errorf("AnnualPaymentDistribution internal error: %v", err)
+        }
+        for year := range debt_service {
+            debt_service[year] = utils.Round2(ppmt[year] + ipmt[year])
+        }
+        return debt_service, nil
+    }
+    annual_loan_payment, err := ls.AnnualLoanPayment()
+    if err != nil {
+        return debt_service, fmt.Errorf("AnnualLoanPayment internal error: %v", err)
+    }
+    for year := range debt_service {
+        debt_service[year] = annual_loan_payment
+    }
+    return debt_service, nil
+}
+
+// AnnualPaymentDistribution returns the slices of the interest and principal
+// payments of the PaymentDistribution rolled up into yearly totals, one value
+// for every year of the term.
+func (ls LoanSizer) AnnualPaymentDistribution () (
+    ppmt []float64,
+    ipmt []float64,
+    err error,
+) {
+    period_ppmt, period_ipmt, err := ls.PaymentDistribution()
+    if err != nil {
+        return ppmt, ipmt, fmt.Errorf("PaymentDistribution internal error: %v", err)
+    }
+    periods_per_year := ls.periods_per_year()
+    ppmt = make([]float64, ls.Term)
+    ipmt = make([]float64, ls.Term)
+    for i := range period_ppmt {
+        year := i / periods_per_year
+        ppmt[year] = utils.Round2(ppmt[year] + period_ppmt[i])
+        ipmt[year] = utils.Round2(ipmt[year] + period_ipmt[i])
+    }
+    return ppmt, ipmt, nil
+}
+
+// NOIResult is a struct with the NOI values that make the loan go through.
+type NOIResult struct {
+    TargetLoanAmount    float64
+    MinimumNOI          float64
+    BreakEvenNOI        float64
+    IOBreakEvenNOI      float64
+}
+
+// with_noi returns a copy of the LoanSizer with a different NOI.
+func (ls LoanSizer) with_noi (noi float64) LoanSizer {
+    ls.NOI = noi
+    return ls
+}
+
+// MinimumNOI returns the yearly NOI values needed for the loan to go through,
+// given the PropertyValue and the RequestedLoanAmount.
+//
+// TargetLoanAmount is the amount the loan would have if the DSCR and debt
+// yield restrictions didn't exist, MinimumNOI is the smallest NOI (to the
+// cent) at which neither the MinDSCR nor the MinDebtYield constrain the loan
+// below the TargetLoanAmount.
+// BreakEvenNOI and IOBreakEvenNOI are the NOIs at which the DSCR is exactly
+// the MinDSCR for the actual loan payment and the actual IO period payment of
+// the maximum loan amount.
+func (ls LoanSizer) MinimumNOI () (NOIResult, error) {
+    var result NOIResult
+
+    target := math.Min(ls.max_ltv_loan_amount(), float64(ls.RequestedLoanAmount))
+    if ls.MaxLTC > 0 {
+        target = math.Min(target, ls.max_ltc_loan_amount())
+    }
+    result.TargetLoanAmount = target
+
+    // The DSCR and debt yield loan amounts grow with the NOI, so the NOI (in
+    // cents) is bracketed and then bisected.
+    meets_target := func (noi_cents int64) (bool, error) {
+        candidate := ls.with_noi(float64(noi_cents) / 100)
+        dscr_mla, err := candidate.max_mindscr_loan_amount()
+        if err != nil {
+            return false, fmt.Errorf("max_mindscr_loan_amount internal error: %v", err)
+        }
+        if ls.MinDebtYield > 0 && candidate.max_debt_yield_loan_amount() < target {
+            return false, nil
+        }
+        return dscr_mla >= target, nil
+    }
+    low, high := int64(0), int64(100)
+    for {
+        ok, err := meets_target(high)
+        if err != nil {
+            return result, err
+        }
+        if ok {
+            break
+        }
+        low, high = high, high * 2
+    }
+    ok, err := meets_target(low)
+    if err != nil {
+        return result, err
+    }
+    if ok {
+        high = low
+    }
+    for high - low > 1 {
+        mid := low + (high - low) / 2
+        ok, err := meets_target(mid)
+        if err != nil {
+            return result, err
+        }
+        if ok {
+            high = mid
+        } else {
+            low = mid
+        }
+    }
+    result.MinimumNOI = float64(high) / 100
+
+    // Break even NOIs for the actual payments of the loan.
+    loan_payment, err := ls.LoanPayment()
+    if err != nil {
+        return result, fmt.Errorf("LoanPayment internal error: %v", err)
+    }
+    io_loan_payment, err := ls.IOLoanPayment()
+    if err != nil {
+        return result, fmt.Errorf("IOLoanPayment internal error: %v", err)
+    }
+    periods_per_year := float64(ls.periods_per_year())
+    result.BreakEvenNOI = utils.Round2(- loan_payment * periods_per_year * ls.MinDSCR)
+    result.IOBreakEvenNOI = utils.Round2(- io_loan_payment * periods_per_year * ls.MinDSCR)
+    return result, nil
+}
@@ -280,3 +280,464 @@ func TestLoanSizerFunctionality(t *testing.T){
         })
     }
 }
+
+func TestMinimumNOI(t *testing.T){
+    var testCases = []struct {
+        name string
+        ls LoanSizer
+        want NOIResult
+    }{
+        {
+            name: "Zero Values",
+            ls: LoanSizer{
+                MaxLTV: 0.70,
+                MinDSCR: 1.40,
+                Amortization: 30,
+                Term: 10,
+                IOPeriod: 3,
+                Rate: 0.0045,
+                PropertyValue: 0,
+                NOI: 0,
+                RequestedLoanAmount: 0,
+                LoanOriginationFees: 0.001,
+            },
+            want: NOIResult{},
+        },
+        {
+            name: "Valid Inputs, LTV as target",
+            ls: LoanSizer{
+                MaxLTV: 0.70,
+                MinDSCR: 1.40,
+                Amortization: 30,
+                Term: 10,
+                IOPeriod: 3,
+                Rate: 0.0045,
+                PropertyValue: 1000,
+                NOI: 250,
+                RequestedLoanAmount: 900,
+                LoanOriginationFees: 0.001,
+            },
+            want: NOIResult{
+                TargetLoanAmount: 700,
+                MinimumNOI: 35.0,
+                BreakEvenNOI: 35.0,
+                IOBreakEvenNOI: 4.41,
+            },
+        },
+        {
+            name: "Valid Inputs, RequestedLoanAmount as target",
+            ls: LoanSizer{
+                MaxLTV: 0.70,
+                MinDSCR: 1.40,
+                Amortization: 30,
+                Term: 10,
+                IOPeriod: 3,
+                Rate: 0.0045,
+                PropertyValue: 1000,
+                NOI: 20,
+                RequestedLoanAmount: 400,
+                LoanOriginationFees: 0.001,
+            },
+            want: NOIResult{
+                TargetLoanAmount: 400,
+                MinimumNOI: 20.0,
+                BreakEvenNOI: 20.0,
+                IOBreakEvenNOI: 2.52,
+            },
+        },
+    }
+
+    for _, test := range testCases {
+        t.Run(test.name, func(t *testing.T) {
+            got, err := test.ls.MinimumNOI()
+            if err != nil {
+                t.Errorf("MinimumNOI error: %v", err)
+                return
+            }
+            if !utils.Tolerance(got.TargetLoanAmount, test.want.TargetLoanAmount, TOL) {
+                t.Errorf("TargetLoanAmount got: %g, wanted: %g", got.TargetLoanAmount, test.want.TargetLoanAmount)
+            }
+            if !utils.Tolerance(got.MinimumNOI, test.want.MinimumNOI, TOL) {
+                t.Errorf("MinimumNOI got: %g, wanted: %g", got.MinimumNOI, test.want.MinimumNOI)
+            }
+            if !utils.Tolerance(got.BreakEvenNOI, test.want.BreakEvenNOI, TOL) {
+                t.Errorf("BreakEvenNOI got: %g, wanted: %g", got.BreakEvenNOI, test.want.BreakEvenNOI)
+            }
+            if !utils.Tolerance(got.IOBreakEvenNOI, test.want.IOBreakEvenNOI, TOL) {
+                t.Errorf("IOBreakEvenNOI got: %g, wanted: %g", got.IOBreakEvenNOI, test.want.IOBreakEvenNOI)
+            }
+            // At the MinimumNOI the loan reaches the target amount, a cent
+            // less and the DSCR constrains it.
+            if got.TargetLoanAmount > 0 {
+                mla, _ := test.ls.with_noi(got.MinimumNOI).MaximumLoanAmount()
+                if mla != got.TargetLoanAmount {
+                    t.Errorf("MaximumLoanAmount at MinimumNOI got: %g, wanted: %g", mla, got.TargetLoanAmount)
+                }
+                mla, _ = test.ls.with_noi(got.MinimumNOI - 0.01).MaximumLoanAmount()
+                if mla >= got.TargetLoanAmount {
+                    t.Errorf("MaximumLoanAmount below MinimumNOI got: %g, wanted less than: %g", mla, got.TargetLoanAmount)
+                }
+            }
+        })
+    }
+}
+
+func TestPaymentFrequency(t *testing.T){
+    var testCases = []struct {
+        name string
+        ls LoanSizer
+        wantMaximumLoanAmount float64
+        wantLoanPayment float64
+        wantAnnualLoanPayment float64
+        wantEndofTermBalloonPayment float64
+        wantYearOneIpmt float64
+        wantYearOnePpmt float64
+    }{
+        {
+            name: "Monthly, RequestedLoanAmount as MLA",
+            ls: LoanSizer{
+                MaxLTV: 0.80,
+                MinDSCR: 1.25,
+                Amortization: 30,
+                Term: 10,
+                IOPeriod: 0,
+                Rate: 0.05,
+                PropertyValue: 1250000,
+                NOI: 100000,
+                RequestedLoanAmount: 1000000,
+                LoanOriginationFees: 0.01,
+                PaymentFrequency: Monthly,
+            },
+            wantMaximumLoanAmount: 1000000,
+            wantLoanPayment: -5368.22,
+            wantAnnualLoanPayment: -64418.64,
+            wantEndofTermBalloonPayment: 813420.03,
+            wantYearOneIpmt: -49664.93,
+            wantYearOnePpmt: -14753.71,
+        },
+        {
+            name: "Monthly, MinDSCR as MLA",
+            ls: LoanSizer{
+                MaxLTV: 0.80,
+                MinDSCR: 1.25,
+                Amortization: 30,
+                Term: 10,
+                IOPeriod: 0,
+                Rate: 0.05,
+                PropertyValue: 1250000,
+                NOI: 80000,
+                RequestedLoanAmount: 1250000,
+                LoanOriginationFees: 0.01,
+                PaymentFrequency: Monthly,
+            },
+            wantMaximumLoanAmount: 993501,
+            wantLoanPayment: -5333.33,
+            wantAnnualLoanPayment: -63999.96,
+            wantEndofTermBalloonPayment: 808133.87,
+            wantYearOneIpmt: -49342.17,
+            wantYearOnePpmt: -14657.79,
+        },
+        {
+            name: "Monthly with IO period",
+            ls: LoanSizer{
+                MaxLTV: 0.80,
+                MinDSCR: 1.25,
+                Amortization: 30,
+                Term: 10,
+                IOPeriod: 2,
+                Rate: 0.05,
+                PropertyValue: 1250000,
+                NOI: 100000,
+                RequestedLoanAmount: 1000000,
+                LoanOriginationFees: 0.01,
+                PaymentFrequency: Monthly,
+            },
+            wantMaximumLoanAmount: 1000000,
+            wantLoanPayment: -5368.22,
+            wantAnnualLoanPayment: -64418.64,
+            wantEndofTermBalloonPayment: 858528.46,
+            wantYearOneIpmt: -50000.04,
+            wantYearOnePpmt: 0,
+        },
+        {
+            name: "Quarterly",
+            ls: LoanSizer{
+                MaxLTV: 0.80,
+                MinDSCR: 1.25,
+                Amortization: 10,
+                Term: 10,
+                IOPeriod: 0,
+                Rate: 0.05,
+                PropertyValue: 1250000,
+                NOI: 300000,
+                RequestedLoanAmount: 1000000,
+                LoanOriginationFees: 0.01,
+                PaymentFrequency: Quarterly,
+            },
+            wantMaximumLoanAmount: 1000000,
+            wantLoanPayment: -31921.41,
+            wantAnnualLoanPayment: -127685.64,
+            wantEndofTermBalloonPayment: 0,
+            wantYearOneIpmt: -48531.22,
+            wantYearOnePpmt: -79154.42,
+        },
+    }
+
+    for _, test := range testCases {
+        t.Run(test.name, func(t *testing.T) {
+            mla, err := test.ls.MaximumLoanAmount()
+            if err != nil || !utils.Tolerance(mla, test.wantMaximumLoanAmount, TOL) {
+                t.Errorf("MaximumLoanAmount got: %g, wanted: %g, error: %v", mla, test.wantMaximumLoanAmount, err)
+            }
+            pmt, err := test.ls.LoanPayment()
+            if err != nil || !utils.Tolerance(pmt, test.wantLoanPayment, TOL) {
+                t.Errorf("LoanPayment got: %g, wanted: %g, error: %v", pmt, test.wantLoanPayment, err)
+            }
+            annual_pmt, err := test.ls.AnnualLoanPayment()
+            if err != nil || !utils.Tolerance(annual_pmt, test.wantAnnualLoanPayment, TOL) {
+                t.Errorf("AnnualLoanPayment got: %g, wanted: %g, error: %v", annual_pmt, test.wantAnnualLoanPayment, err)
+            }
+            balloon, err := test.ls.EndofTermBalloonPayment()
+            if err != nil || !utils.Tolerance(balloon, test.wantEndofTermBalloonPayment, TOL) {
+                t.Errorf("EndofTermBalloonPayment got: %g, wanted: %g, error: %v", balloon, test.wantEndofTermBalloonPayment, err)
+            }
+            ppmt, ipmt, err := test.ls.PaymentDistribution()
+            if err != nil {
+                t.Errorf("PaymentDistribution internal error: %v", err)
+                return
+            }
+            if len(ppmt) != test.ls.Term * int(test.ls.PaymentFrequency) || len(ipmt) != len(ppmt) {
+                t.Errorf("PaymentDistribution got: %v periods, wanted: %v", len(ppmt), test.ls.Term * int(test.ls.PaymentFrequency))
+            }
+            annual_ppmt, annual_ipmt, err := test.ls.AnnualPaymentDistribution()
+            if err != nil {
+                t.Errorf("AnnualPaymentDistribution internal error: %v", err)
+                return
+            }
+            if len(annual_ppmt) != test.ls.Term || len(annual_ipmt) != test.ls.Term {
+                t.Errorf("AnnualPaymentDistribution got: %v years, wanted: %v", len(annual_ppmt), test.ls.Term)
+                return
+            }
+            if !utils.Tolerance(annual_ipmt[0], test.wantYearOneIpmt, TOL) {
+                t.Errorf("AnnualPaymentDistribution ipmt got: %g, wanted: %g", annual_ipmt[0], test.wantYearOneIpmt)
+            }
+            if !utils.Tolerance(annual_ppmt[0], test.wantYearOnePpmt, TOL) {
+                t.Errorf("AnnualPaymentDistribution ppmt got: %g, wanted: %g", annual_ppmt[0], test.wantYearOnePpmt)
+            }
+        })
+    }
+
+    t.Run("Invalid frequency", func(t *testing.T) {
+        if _, err := (LoanSizer{}).WithPaymentFrequency(PaymentFrequency(7)); err == nil {
+            t.Errorf("expected an error for an invalid payment frequency")
+        }
+    })
+}
+
+func TestBindingConstraint(t *testing.T){
+    var testCases = []struct {
+        name string
+        ls LoanSizer
+        wantMaximumLoanAmount float64
+        wantConstraint SizingConstraint
+    }{
+        {
+            name: "LTV as binding constraint",
+            ls: LoanSizer{
+                MaxLTV: 0.70,
+                MinDSCR: 1.40,
+                Amortization: 30,
+                Term: 10,
+                IOPeriod: 3,
+                Rate: 0.0045,
+                PropertyValue: 1000,
+                NOI: 500,
+                RequestedLoanAmount: 900,
+            },
+            wantMaximumLoanAmount: 700,
+            wantConstraint: LTVConstraint,
+        },
+        {
+            name: "DSCR as binding constraint",
+            ls: LoanSizer{
+                MaxLTV: 0.70,
+                MinDSCR: 1.40,
+                Amortization: 30,
+                Term: 10,
+                IOPeriod: 3,
+                Rate: 0.0045,
+                PropertyValue: 1000,
+                NOI: 10,
+                RequestedLoanAmount: 900,
+            },
+            wantMaximumLoanAmount: 200,
+            wantConstraint: DSCRConstraint,
+        },
+        {
+            name: "Debt yield as binding constraint",
+            ls: LoanSizer{
+                MaxLTV: 0.70,
+                MinDSCR: 1.40,
+                Amortization: 30,
+                Term: 10,
+                IOPeriod: 3,
+                Rate: 0.0045,
+                PropertyValue: 1000,
+                NOI: 100,
+                RequestedLoanAmount: 900,
+                MinDebtYield: 0.20,
+            },
+            wantMaximumLoanAmount: 500,
+            wantConstraint: DebtYieldConstraint,
+        },
+        {
+            name: "Debt yield not binding",
+            ls: LoanSizer{
+                MaxLTV: 0.70,
+                MinDSCR: 1.40,
+                Amortization: 30,
+                Term: 10,
+                IOPeriod: 3,
+                Rate: 0.0045,
+                PropertyValue: 1000,
+                NOI: 100,
+                RequestedLoanAmount: 900,
+                MinDebtYield: 0.10,
+            },
+            wantMaximumLoanAmount: 700,
+            wantConstraint: LTVConstraint,
+        },
+        {
+            name: "RequestedLoanAmount as binding constraint",
+            ls: LoanSizer{
+                MaxLTV: 0.70,
+                MinDSCR: 1.40,
+                Amortization: 30,
+                Term: 10,
+                IOPeriod: 3,
+                Rate: 0.0045,
+                PropertyValue: 1000,
+                NOI: 400,
+                RequestedLoanAmount: 400,
+                MinDebtYield: 0.10,
+            },
+            wantMaximumLoanAmount: 400,
+            wantConstraint: RequestedConstraint,
+        },
+        {
+            name: "LTC as binding constraint",
+            ls: LoanSizer{
+                MaxLTV: 0.70,
+                MinDSCR: 1.40,
+                Amortization: 30,
+                Term: 10,
+                IOPeriod: 3,
+                Rate: 0.0045,
+                PropertyValue: 1000,
+                NOI: 500,
+                RequestedLoanAmount: 900,
+                MaxLTC: 0.60,
+                ProjectCost: 1100,
+            },
+            wantMaximumLoanAmount: 660,
+            wantConstraint: LTCConstraint,
+        },
+        {
+            name: "LTC over the property value",
+            ls: LoanSizer{
+                MaxLTV: 0.70,
+                MinDSCR: 1.40,
+                Amortization: 30,
+                Term: 10,
+                IOPeriod: 3,
+                Rate: 0.0045,
+                PropertyValue: 1000,
+                NOI: 500,
+                RequestedLoanAmount: 900,
+                MaxLTC: 0.65,
+            },
+            wantMaximumLoanAmount: 650,
+            wantConstraint: LTCConstraint,
+        },
+    }
+
+    for _, test := range testCases {
+        t.Run(test.name, func(t *testing.T) {
+            mla, err := test.ls.MaximumLoanAmount()
+            if err != nil || !utils.Tolerance(mla, test.wantMaximumLoanAmount, 1) {
+                t.Errorf("MaximumLoanAmount got: %g, wanted: %g, error: %v", mla, test.wantMaximumLoanAmount, err)
+            }
+            constraint, err := test.ls.BindingConstraint()
+            if err != nil || constraint != test.wantConstraint {
+                t.Errorf("BindingConstraint got: %v, wanted: %v, error: %v", constraint, test.wantConstraint, err)
+            }
+        })
+    }
+
+    t.Run("Invalid debt yield", func(t *testing.T) {
+        if _, err := (LoanSizer{}).WithMinDebtYield(-0.1); err == nil {
+            t.Errorf("expected an error for a negative debt yield")
+        }
+    })
+
+    t.Run("Invalid loan to cost", func(t *testing.T) {
+        if _, err := (LoanSizer{}).WithMaxLTC(1.1); err == nil {
+            t.Errorf("expected an error for a loan to cost above 1")
+        }
+        if _, err := (LoanSizer{}).WithProjectCost(-1); err == nil {
+            t.Errorf("expected an error for a negative project cost")
+        }
+    })
+}
+
+func TestSizing(t *testing.T){
+    ls := LoanSizer{
+        MaxLTV: 0.75,
+        MinDSCR: 1.25,
+        Amortization: 30,
+        Term: 10,
+        IOPeriod: 0,
+        Rate: 0.05,
+        PropertyValue: 1000000,
+        NOI: 70000,
+        RequestedLoanAmount: 800000,
+        MinDebtYield: 0.09,
+    }
+    got, err := ls.Sizing()
+    if err != nil {
+        t.Errorf("Sizing internal error: %v", err)
+        return
+    }
+    if got.LoanAmount != 750000 || got.BindingConstraint != LTVConstraint {
+        t.Errorf("Sizing got: %v (%v), wanted: %v (%v)", got.LoanAmount, got.BindingConstraint, 750000, LTVConstraint)
+    }
+    wantCandidates := map[SizingConstraint]float64{
+        LTVConstraint: 750000,
+        DSCRConstraint: 860857,
+        DebtYieldConstraint: 777777,
+        RequestedConstraint: 800000,
+    }
+    wantHeadroom := map[SizingConstraint]float64{
+        LTVConstraint: 0,
+        DSCRConstraint: 110857,
+        DebtYieldConstraint: 27777,
+        RequestedConstraint: 50000,
+    }
+    for constraint, want := range wantCandidates {
+        if !utils.Tolerance(got.Candidates[constraint], want, 1) {
+            t.Errorf("Candidates %v got: %g, wanted: %g", constraint, got.Candidates[constraint], want)
+        }
+        if !utils.Tolerance(got.Headroom[constraint], wantHeadroom[constraint], 1) {
+            t.Errorf("Headroom %v got: %g, wanted: %g", constraint, got.Headroom[constraint], wantHeadroom[constraint])
+        }
+    }
+    if got.LTV != 0.75 {
+        t.Errorf("LTV got: %g, wanted: %g", got.LTV, 0.75)
+    }
+    if got.DSCR != 1.4348 {
+        t.Errorf("DSCR got: %g, wanted: %g", got.DSCR, 1.4348)
+    }
+    if got.DebtYield != 0.0933 {
+        t.Errorf("DebtYield got: %g, wanted: %g", got.DebtYield, 0.0933)
+    }
+}
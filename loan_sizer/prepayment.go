@@ -0,0 +1,170 @@
+// Prepayment penalties. Paying the loan before the end of its term (when the
+// property is sold early) has a cost that depends on the prepayment structure
+// of the loan.
+
+package loan_sizer
+
+import (
+    "fmt"
+    "math"
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+)
+
+// PrepaymentType is the name of a prepayment structure.
+type PrepaymentType string
+
+const (
+    StepDown            PrepaymentType = "step_down"
+    YieldMaintenance    PrepaymentType = "yield_maintenance"
+    Defeasance          PrepaymentType = "defeasance"
+)
+
+// Prepayment is a struct with the prepayment structure of the loan.
+//
+// StepDown has the penalty, as a percentage of the outstanding balance, of
+// every year of the loan (5-4-3-2-1 is {0.05, 0.04, 0.03, 0.02, 0.01}), the
+// years after the last value have no penalty. YieldMaintenance and Defeasance
+// discount the remaining payments of the loan with the TreasuryYield. The
+// MinimumPenalty is the floor of the yield maintenance as a percentage of the
+// outstanding balance, and the DefeasanceFees are the fixed costs (legal,
+// accounting, successor borrower) of a defeasance.
+type Prepayment struct {
+    Type                PrepaymentType
+    StepDown            []float64
+    TreasuryYield       float64
+    MinimumPenalty      float64
+    DefeasanceFees      float64
+}
+
+// NewPrepayment returns a Prepayment struct if the values given are valid. If
+// not, returns a default struct with the error.
+func NewPrepayment(
+    prepaymentType PrepaymentType,
+    stepDown []float64,
+    treasuryYield float64,
+    minimumPenalty float64,
+    defeasanceFees float64,
+) (
+    Prepayment,
+    error,
+) {
+    // Data Validation
+    switch prepaymentType {
+    case StepDown:
+        if len(stepDown) == 0 {
+            return Prepayment{}, fmt.Errorf("A step down prepayment needs at least one step.")
+        }
+        for _, step := range stepDown {
+            if step < 0 || step > 1 {
+                return Prepayment{}, fmt.Errorf("The steps of the step down must be between 0 and 1.")
+            }
+        }
+    case YieldMaintenance, Defeasance:
+        if treasuryYield < 0 || treasuryYield > 1 {
+            return Prepayment{}, fmt.Errorf("The treasuryYield must be between 0 and 1.")
+        }
+    default:
+        return Prepayment{}, fmt.Errorf("The prepaymentType must be StepDown, YieldMaintenance or Defeasance.")
+    }
+    if minimumPenalty < 0 || minimumPenalty > 1 {
+        return Prepayment{}, fmt.Errorf("The minimumPenalty must be between 0 and 1.")
+    }
+    if defeasanceFees < 0 {
+        return Prepayment{}, fmt.Errorf("The defeasanceFees cannot be lower than 0.")
+    }
+    // Struct Creation
+    prepayment := Prepayment{
+        Type: prepaymentType,
+        StepDown: stepDown,
+        TreasuryYield: treasuryYield,
+        MinimumPenalty: minimumPenalty,
+        DefeasanceFees: defeasanceFees,
+    }
+    return prepayment, nil
+}
+
+// WithPrepayment returns a copy of the LoanSizer with the given prepayment
+// structure.
+func (ls LoanSizer) WithPrepayment (prepayment Prepayment) (LoanSizer, error) {
+    validated, err := NewPrepayment(
+        prepayment.Type,
+        prepayment.StepDown,
+        prepayment.TreasuryYield,
+        prepayment.MinimumPenalty,
+        prepayment.DefeasanceFees,
+    )
+    if err != nil {
+        return ls, fmt.Errorf("NewPrepayment internal error: %v", err)
+    }
+    ls.Prepayment = &validated
+    return ls, nil
+}
+
+// remaining_payments_value returns the value, at the end of the given year,
+// of the payments left of the loan (the balloon payment included) discounted
+// with the treasury yield.
+func (ls LoanSizer) remaining_payments_value (year int, treasuryYield float64) (float64, error) {
+    ppmt, ipmt, err := ls.PaymentDistribution()
+    if err != nil {
+        return 0.0, fmt.Errorf("PaymentDistribution internal error: %v", err)
+    }
+    balloon, err := ls.EndofTermBalloonPayment()
+    if err != nil {
+        return 0.0, fmt.Errorf("EndofTermBalloonPayment internal error: %v", err)
+    }
+    period_yield := treasuryYield / float64(ls.periods_per_year())
+    first_period := year * ls.periods_per_year()
+    value := 0.0
+    for period := first_period; period < ls.term_periods(); period++ {
+        payment := - (ppmt[period] + ipmt[period])
+        if period == ls.term_periods() - 1 {
+            payment += balloon
+        }
+        value += payment / math.Pow(1 + period_yield, float64(period - first_period + 1))
+    }
+    return value, nil
+}
+
+// PrepaymentPenalty returns the cost of paying the loan at the end of the
+// given year. There is no penalty if the loan has no prepayment structure or
+// if the year is the end of the term.
+//
+// The step down penalty is the step of the year times the outstanding
+// balance. The yield maintenance is the value of the remaining payments minus
+// the outstanding balance, never lower than the MinimumPenalty. The
+// defeasance is the cost of the treasury portfolio that replaces the
+// remaining payments, minus the outstanding balance, plus the fees.
+func (ls LoanSizer) PrepaymentPenalty (year int) (float64, error) {
+    if ls.Prepayment == nil || year >= ls.Term {
+        return 0.0, nil
+    }
+    if year <= 0 {
+        return 0.0, fmt.Errorf("The year of the prepayment must be greater than 0.")
+    }
+    balance, err := ls.SaleYearBalloonPayment(year)
+    if err != nil {
+        return 0.0, fmt.Errorf("SaleYearBalloonPayment internal error: %v", err)
+    }
+
+    switch ls.Prepayment.Type {
+    case StepDown:
+        if year > len(ls.Prepayment.StepDown) {
+            return 0.0, nil
+        }
+        return utils.Round2(ls.Prepayment.StepDown[year - 1] * balance), nil
+    case YieldMaintenance:
+        value, err := ls.remaining_payments_value(year, ls.Prepayment.TreasuryYield)
+        if err != nil {
+            return 0.0, fmt.Errorf("remaining_payments_value internal error: %v", err)
+        }
+        penalty := math.Max(value - balance, ls.Prepayment.MinimumPenalty * balance)
+        return utils.Round2(penalty), nil
+    case Defeasance:
+        value, err := ls.remaining_payments_value(year, ls.Prepayment.TreasuryYield)
+        if err != nil {
+            return 0.0, fmt.Errorf("remaining_payments_value internal error: %v", err)
+        }
+        return utils.Round2(value - balance + ls.Prepayment.DefeasanceFees), nil
+    }
+    return 0.0, nil
+}
@@ -0,0 +1,105 @@
+// Testing the prepayment penalties
+
+package loan_sizer
+import (
+    "testing";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+)
+
+func TestPrepaymentPenalty(t *testing.T){
+    loan := LoanSizer{
+        MaxLTV: 0.80,
+        MinDSCR: 1.25,
+        Amortization: 30,
+        Term: 5,
+        IOPeriod: 5,
+        Rate: 0.06,
+        PropertyValue: 2000000,
+        NOI: 300000,
+        RequestedLoanAmount: 1000000,
+    }
+
+    var testCases = []struct {
+        name string
+        prepayment Prepayment
+        year int
+        want float64
+    }{
+        {
+            name: "Step down",
+            prepayment: Prepayment{Type: StepDown, StepDown: []float64{0.05, 0.04, 0.03, 0.02, 0.01}},
+            year: 2,
+            want: 40000,
+        },
+        {
+            name: "Step down after the last step",
+            prepayment: Prepayment{Type: StepDown, StepDown: []float64{0.02, 0.01}},
+            year: 3,
+            want: 0,
+        },
+        {
+            name: "Step down at the end of the term",
+            prepayment: Prepayment{Type: StepDown, StepDown: []float64{0.05, 0.04, 0.03, 0.02, 0.01}},
+            year: 5,
+            want: 0,
+        },
+        {
+            name: "Yield maintenance",
+            prepayment: Prepayment{Type: YieldMaintenance, TreasuryYield: 0.04, MinimumPenalty: 0.01},
+            year: 3,
+            want: 37721.89,
+        },
+        {
+            name: "Yield maintenance at the minimum penalty",
+            prepayment: Prepayment{Type: YieldMaintenance, TreasuryYield: 0.07, MinimumPenalty: 0.01},
+            year: 3,
+            want: 10000,
+        },
+        {
+            name: "Defeasance",
+            prepayment: Prepayment{Type: Defeasance, TreasuryYield: 0.04, DefeasanceFees: 50000},
+            year: 3,
+            want: 87721.89,
+        },
+    }
+
+    for _, test := range testCases {
+        t.Run(test.name, func(t *testing.T) {
+            ls, err := loan.WithPrepayment(test.prepayment)
+            if err != nil {
+                t.Errorf("WithPrepayment internal error: %v", err)
+                return
+            }
+            got, err := ls.PrepaymentPenalty(test.year)
+            if err != nil {
+                t.Errorf("PrepaymentPenalty internal error: %v", err)
+                return
+            }
+            if !utils.Tolerance(got, test.want, TOL) {
+                t.Errorf("PrepaymentPenalty got: %g, wanted: %g", got, test.want)
+            }
+        })
+    }
+
+    t.Run("No prepayment structure", func(t *testing.T) {
+        got, err := loan.PrepaymentPenalty(2)
+        if err != nil || got != 0 {
+            t.Errorf("PrepaymentPenalty got: %g, wanted: 0, error: %v", got, err)
+        }
+    })
+
+    t.Run("Invalid values", func(t *testing.T) {
+        invalid := []Prepayment{
+            {Type: "lockout"},
+            {Type: StepDown},
+            {Type: StepDown, StepDown: []float64{1.5}},
+            {Type: YieldMaintenance, TreasuryYield: -0.01},
+            {Type: Defeasance, TreasuryYield: 0.04, DefeasanceFees: -1},
+        }
+        for _, test := range invalid {
+            if _, err := loan.WithPrepayment(test); err == nil {
+                t.Errorf("expected an error for: %+v", test)
+            }
+        }
+    })
+}
@@ -0,0 +1,171 @@
+// Interest rate caps. Floating rate lenders require the borrower to buy a cap
+// over the index of the loan, the cap has an upfront premium and pays back the
+// interest above its strike.
+
+package loan_sizer
+
+import (
+    "fmt"
+    "math"
+    ff "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/financial_formulas";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+)
+
+// RateCap is a struct with the terms of an interest rate cap over the index
+// of a floating rate loan. The Volatility is the Black volatility used to
+// price the caplets. The Term is given in years, a zero Term covers the whole
+// term of the loan and a zero Notional covers the maximum loan amount.
+type RateCap struct {
+    Strike          float64
+    Volatility      float64
+    Term            int
+    Notional        float64
+}
+
+// NewRateCap returns a RateCap struct if the values given are valid. If not,
+// returns a default struct with the error.
+func NewRateCap(
+    strike float64,
+    volatility float64,
+    term int,
+    notional float64,
+) (
+    RateCap,
+    error,
+) {
+    // Data Validation
+    if strike <= 0 || strike > 1 {
+        return RateCap{}, fmt.Errorf("The strike of the rate cap must be between 0 and 1.")
+    }
+    if volatility < 0 {
+        return RateCap{}, fmt.Errorf("The volatility of the rate cap cannot be lower than 0.")
+    }
+    if term < 0 {
+        return RateCap{}, fmt.Errorf("The term of the rate cap cannot be lower than 0.")
+    }
+    if notional < 0 {
+        return RateCap{}, fmt.Errorf("The notional of the rate cap cannot be lower than 0.")
+    }
+    // Struct Creation
+    rc := RateCap{
+        Strike: strike,
+        Volatility: volatility,
+        Term: term,
+        Notional: notional,
+    }
+    return rc, nil
+}
+
+// WithRateCap returns a copy of the LoanSizer with a rate cap bought over the
+// index of the loan. The loan must be a floating rate loan, and its CapStrike
+// is set to the Strike of the rate cap so the DSCR is sized at the capped
+// rate.
+func (ls LoanSizer) WithRateCap (rc RateCap) (LoanSizer, error) {
+    if ls.FloatingRate == nil {
+        return ls, fmt.Errorf("A rate cap can only be bought for a floating rate loan.")
+    }
+    validated, err := NewRateCap(rc.Strike, rc.Volatility, rc.Term, rc.Notional)
+    if err != nil {
+        return ls, fmt.Errorf("NewRateCap internal error: %v", err)
+    }
+    if validated.Term > ls.Term {
+        return ls, fmt.Errorf("The term of the rate cap cannot be greater than the term of the loan.")
+    }
+    floating_rate := *ls.FloatingRate
+    floating_rate.CapStrike = validated.Strike
+    ls.FloatingRate = &floating_rate
+    ls.RateCap = &validated
+    return ls, nil
+}
+
+// rate_cap_periods returns the number of payment periods covered by the rate
+// cap.
+func (ls LoanSizer) rate_cap_periods () int {
+    if ls.RateCap.Term == 0 {
+        return ls.term_periods()
+    }
+    return ls.RateCap.Term * ls.periods_per_year()
+}
+
+// rate_cap_notional returns the notional of the rate cap.
+func (ls LoanSizer) rate_cap_notional () (float64, error) {
+    if ls.RateCap.Notional > 0 {
+        return ls.RateCap.Notional, nil
+    }
+    mla, err := ls.MaximumLoanAmount()
+    if err != nil {
+        return 0.0, fmt.Errorf("MaximumLoanAmount internal error: %v", err)
+    }
+    return mla, nil
+}
+
+// RateCapPremium returns the upfront price of the rate cap, the sum of the
+// Black price of every caplet. Each caplet covers a payment period, fixes at
+// its start with the forward index of the period and is discounted with the
+// forward curve. If the loan has no rate cap, the premium is 0.
+func (ls LoanSizer) RateCapPremium () (float64, error) {
+    if ls.RateCap == nil {
+        return 0.0, nil
+    }
+    notional, err := ls.rate_cap_notional()
+    if err != nil {
+        return 0.0, fmt.Errorf("rate_cap_notional internal error: %v", err)
+    }
+    accrual := 1 / float64(ls.periods_per_year())
+    discount_factor := 1.0
+    premium := 0.0
+    for period := 0; period < ls.rate_cap_periods(); period++ {
+        forward := ls.FloatingRate.Index(period)
+        // the caplet is paid at the end of the period.
+        discount_factor = discount_factor / (1 + math.Max(forward, 0) * accrual)
+        caplet, err := ff.BlackCaplet(
+            forward,
+            ls.RateCap.Strike,
+            ls.RateCap.Volatility,
+            float64(period) * accrual,
+            accrual,
+            discount_factor,
+            notional,
+        )
+        if err != nil {
+            return 0.0, fmt.Errorf("BlackCaplet internal error: %v", err)
+        }
+        premium += caplet
+    }
+    return utils.Round2(premium), nil
+}
+
+// RateCapPayouts returns the money paid by the rate cap on every payment
+// period of the term of the loan, when the index of the forward curve is
+// above the strike. If the loan has no rate cap, every payout is 0.
+func (ls LoanSizer) RateCapPayouts () ([]float64, error) {
+    payouts := make([]float64, ls.term_periods())
+    if ls.RateCap == nil {
+        return payouts, nil
+    }
+    notional, err := ls.rate_cap_notional()
+    if err != nil {
+        return payouts, fmt.Errorf("rate_cap_notional internal error: %v", err)
+    }
+    accrual := 1 / float64(ls.periods_per_year())
+    for period := 0; period < ls.rate_cap_periods(); period++ {
+        excess := math.Max(ls.FloatingRate.Index(period) - ls.RateCap.Strike, 0)
+        payouts[period] = utils.Round2(notional * excess * accrual)
+    }
+    return payouts, nil
+}
+
+// AnnualRateCapPayouts returns the RateCapPayouts rolled up into yearly
+// totals, one value for every year of the term.
+func (ls LoanSizer) AnnualRateCapPayouts () ([]float64, error) {
+    annual_payouts := make([]float64, ls.Term)
+    payouts, err := ls.RateCapPayouts()
+    if err != nil {
+        return annual_payouts, fmt.Errorf("RateCapPayouts internal error: %v", err)
+    }
+    for period, payout := range payouts {
+        year := period / ls.periods_per_year()
+        annual_payouts[year] = utils.Round2(annual_payouts[year] + payout)
+    }
+    return annual_payouts, nil
+}
@@ -0,0 +1,116 @@
+// Testing the interest rate caps
+
+package loan_sizer
+import (
+    "testing";
+    utils "github.com/jacobitosuperstar/go-cre-loan-calculations/internal/utils";
+)
+
+func TestRateCap(t *testing.T){
+    loan := LoanSizer{
+        MaxLTV: 0.80,
+        MinDSCR: 1.25,
+        Amortization: 30,
+        Term: 2,
+        IOPeriod: 2,
+        PropertyValue: 2000000,
+        NOI: 300000,
+        RequestedLoanAmount: 1000000,
+    }
+    loan, err := loan.WithFloatingRate(FloatingRate{
+        ForwardCurve: []float64{0.05, 0.06},
+        Spread: 0.03,
+    })
+    if err != nil {
+        t.Errorf("WithFloatingRate internal error: %v", err)
+        return
+    }
+
+    t.Run("Fixed rate loan", func(t *testing.T) {
+        if _, err := (LoanSizer{Term: 2}).WithRateCap(RateCap{Strike: 0.05, Volatility: 0.2}); err == nil {
+            t.Errorf("expected an error for a rate cap over a fixed rate loan")
+        }
+    })
+
+    t.Run("Invalid values", func(t *testing.T) {
+        invalid := []RateCap{
+            {Strike: 0, Volatility: 0.2},
+            {Strike: 0.05, Volatility: -0.2},
+            {Strike: 0.05, Volatility: 0.2, Term: 3},
+        }
+        for _, test := range invalid {
+            if _, err := loan.WithRateCap(test); err == nil {
+                t.Errorf("expected an error for: %+v", test)
+            }
+        }
+    })
+
+    t.Run("No rate cap", func(t *testing.T) {
+        premium, err := loan.RateCapPremium()
+        if err != nil || premium != 0 {
+            t.Errorf("RateCapPremium got: %g, wanted: 0, error: %v", premium, err)
+        }
+    })
+
+    capped, err := loan.WithRateCap(RateCap{Strike: 0.05, Volatility: 0.2})
+    if err != nil {
+        t.Errorf("WithRateCap internal error: %v", err)
+        return
+    }
+
+    t.Run("Sizing at the capped rate", func(t *testing.T) {
+        if capped.FloatingRate.CapStrike != 0.05 {
+            t.Errorf("CapStrike got: %g, wanted: %g", capped.FloatingRate.CapStrike, 0.05)
+        }
+        if loan.FloatingRate.CapStrike != 0 {
+            t.Errorf("WithRateCap changed the FloatingRate of the original loan")
+        }
+    })
+
+    t.Run("Premium", func(t *testing.T) {
+        premium, err := capped.RateCapPremium()
+        if err != nil || !utils.Tolerance(premium, 9949.37, TOL) {
+            t.Errorf("RateCapPremium got: %g, wanted: %g, error: %v", premium, 9949.37, err)
+        }
+    })
+
+    t.Run("Payouts", func(t *testing.T) {
+        payouts, err := capped.AnnualRateCapPayouts()
+        if err != nil {
+            t.Errorf("AnnualRateCapPayouts internal error: %v", err)
+            return
+        }
+        want := []float64{0, 10000}
+        for i := range want {
+            if !utils.Tolerance(payouts[i], want[i], TOL) {
+                t.Errorf("AnnualRateCapPayouts got: %g, wanted: %g", payouts[i], want[i])
+            }
+        }
+    })
+
+    t.Run("Quarterly payouts rolled up", func(t *testing.T) {
+        quarterly, err := loan.WithPaymentFrequency(Quarterly)
+        if err != nil {
+            t.Errorf("WithPaymentFrequency internal error: %v", err)
+            return
+        }
+        quarterly, err = quarterly.WithRateCap(RateCap{Strike: 0.055, Volatility: 0.2, Term: 1})
+        if err != nil {
+            t.Errorf("WithRateCap internal error: %v", err)
+            return
+        }
+        payouts, err := quarterly.AnnualRateCapPayouts()
+        if err != nil {
+            t.Errorf("AnnualRateCapPayouts internal error: %v", err)
+            return
+        }
+        // The forward curve holds 0.06 from the second quarter on, but the
+        // cap only covers the first year.
+        want := []float64{3750, 0}
+        for i := range want {
+            if !utils.Tolerance(payouts[i], want[i], TOL) {
+                t.Errorf("AnnualRateCapPayouts got: %g, wanted: %g", payouts[i], want[i])
+            }
+        }
+    })
+}